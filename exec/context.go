@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+var (
+	// ErrCancelled is the cause of the context of a request stopped by
+	// RequestKeeper.Cancel.
+	ErrCancelled = errors.New("request cancelled")
+	// ErrTimedOut is the cause of the context of a request stopped by its
+	// timeout.
+	ErrTimedOut = errors.New("request timed out")
+)
+
+// ContextRequest is a Request that can be stopped: Run should return soon
+// after ctx is done.
+type ContextRequest interface {
+	Identifier
+	Logger() *zerolog.Logger
+	Run(ctx context.Context) (bool, []*util.Result)
+}
+
+// TimeoutRequest is implemented by requests with a timeout of their own,
+// which overrides the keeper's. A zero timeout leaves the keeper's.
+type TimeoutRequest interface {
+	Timeout() time.Duration
+}
+
+// FromRequest adapts req to a ContextRequest. Its Run can't be interrupted:
+// once ctx is done it is abandoned, left to finish in the background with
+// its results discarded.
+func FromRequest(req Request) ContextRequest {
+	return requestAdapter{req}
+}
+
+type requestAdapter struct {
+	Request
+}
+
+type runResult struct {
+	succeeded bool
+	results   []*util.Result
+}
+
+func (r requestAdapter) Run(ctx context.Context) (bool, []*util.Result) {
+	done := make(chan runResult, 1)
+	go func() {
+		succeeded, results := r.Request.Run()
+		done <- runResult{succeeded, results}
+	}()
+	select {
+	case res := <-done:
+		return res.succeeded, res.results
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+func (r requestAdapter) Timeout() time.Duration {
+	return timeoutOf(r.Request)
+}
+
+func timeoutOf(req interface{}) time.Duration {
+	if t, ok := req.(TimeoutRequest); ok {
+		return t.Timeout()
+	}
+	return 0
+}
+
+// stopStatus returns the status of a request whose context is done.
+func stopStatus(ctx context.Context) Status {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrTimedOut) || errors.Is(cause, context.DeadlineExceeded) {
+		return StatusTimedOut
+	}
+	return StatusCancelled
+}
@@ -0,0 +1,130 @@
+package exec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// sleepRequest runs for d unless its context is done first.
+type sleepRequest struct {
+	id      string
+	d       time.Duration
+	timeout time.Duration
+	started chan struct{}
+}
+
+func (r *sleepRequest) ID() string              { return r.id }
+func (r *sleepRequest) Name() string            { return "sleep" }
+func (r *sleepRequest) Logger() *zerolog.Logger { return nil }
+func (r *sleepRequest) Timeout() time.Duration  { return r.timeout }
+func (r *sleepRequest) Run(ctx context.Context) (bool, []*util.Result) {
+	if r.started != nil {
+		close(r.started)
+	}
+	select {
+	case <-time.After(r.d):
+		return true, nil
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+// blockingRequest is a Request without a context that runs for d.
+type blockingRequest struct {
+	id string
+	d  time.Duration
+}
+
+func (r *blockingRequest) ID() string              { return r.id }
+func (r *blockingRequest) Name() string            { return "blocking" }
+func (r *blockingRequest) Logger() *zerolog.Logger { return nil }
+func (r *blockingRequest) Run() (bool, []*util.Result) {
+	time.Sleep(r.d)
+	return true, []*util.Result{{Msg: "done"}}
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        ContextRequest
+		timeout    time.Duration
+		ctxTimeout time.Duration
+		want       Status
+		wantErr    string
+	}{
+		{name: "finishes", req: &sleepRequest{id: "1", d: time.Millisecond}, want: StatusOk},
+		{name: "keeper timeout", req: &sleepRequest{id: "2", d: time.Minute}, timeout: 10 * time.Millisecond, want: StatusTimedOut, wantErr: "request timed out"},
+		{name: "request timeout", req: &sleepRequest{id: "3", d: time.Minute, timeout: 10 * time.Millisecond}, timeout: time.Minute, want: StatusTimedOut, wantErr: "request timed out"},
+		{name: "context deadline", req: &sleepRequest{id: "4", d: time.Minute}, ctxTimeout: 10 * time.Millisecond, want: StatusTimedOut, wantErr: "deadline exceeded"},
+		{name: "adapter", req: FromRequest(&blockingRequest{id: "5", d: time.Millisecond}), want: StatusOk},
+		{name: "adapter timeout", req: FromRequest(&blockingRequest{id: "6", d: time.Second}), timeout: 10 * time.Millisecond, want: StatusTimedOut, wantErr: "request timed out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewInMemRequestKeeper()
+			k.Timeout = tt.timeout
+			if _, res := k.RegisterContext(tt.req); res != nil {
+				t.Fatal(res)
+			}
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+			k.RunContext(ctx, tt.req)
+
+			m, _ := k.GetMeta(tt.req.ID())
+			if m.GetStatus() != tt.want {
+				t.Errorf("status = %s, want %s", m.GetStatus(), tt.want)
+			}
+			results := m.GetResults()
+			if tt.wantErr != "" && (len(results) == 0 || !strings.Contains(results[len(results)-1].Msg, tt.wantErr)) {
+				t.Errorf("results = %v, want %q last", results, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	k := NewInMemRequestKeeper()
+	req := &sleepRequest{id: "1", d: time.Minute, started: make(chan struct{})}
+	if _, res := k.RegisterContext(req); res != nil {
+		t.Fatal(res)
+	}
+	if res := k.Cancel("1"); res == nil {
+		t.Error("Cancel() of a request not running should fail")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		k.RunContext(context.Background(), req)
+		close(done)
+	}()
+	<-req.started
+	if res := k.Cancel("1"); res != nil {
+		t.Fatal(res)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not cancelled")
+	}
+
+	m, _ := k.GetMeta("1")
+	if m.GetStatus() != StatusCancelled {
+		t.Errorf("status = %s, want %s", m.GetStatus(), StatusCancelled)
+	}
+	if res := k.Cancel("1"); res == nil {
+		t.Error("Cancel() of a finished request should fail")
+	}
+	if _, res := k.RegisterContext(req); res != nil {
+		t.Errorf("Register() of a cancelled request = %v", res)
+	}
+}
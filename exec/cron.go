@@ -0,0 +1,237 @@
+package exec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Schedule computes when a Task runs.
+type Schedule interface {
+	// Next returns the first run after t, in t's location, or the zero time
+	// if there is none.
+	Next(t time.Time) time.Time
+}
+
+// Every runs a Task at a fixed interval, counted from its previous run.
+type Every time.Duration
+
+func (d Every) Next(t time.Time) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(d))
+}
+
+func (d Every) String() string {
+	return "@every " + time.Duration(d).String()
+}
+
+// CronSchedule runs a Task at the times matching a cron expression, read in
+// the location of the time given to Next.
+type CronSchedule struct {
+	expr                     string
+	minute, hour, dom, month bits
+	dow                      bits
+	domAny, dowAny           bool
+}
+
+// bits has bit i set if the value i matches.
+type bits uint64
+
+func (b bits) has(i int) bool {
+	return b&(1<<uint(i)) != 0
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dowNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// ParseSchedule reads a cron expression, see ParseCron, or a fixed interval
+// written "@every <duration>", e.g. "@every 1h30m".
+func ParseSchedule(spec string) (Schedule, *util.Result) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, util.Error("ParseSchedule", err)
+		}
+		if d <= 0 {
+			return nil, util.MsgError("ParseSchedule", "interval must be positive")
+		}
+		return Every(d), nil
+	}
+	return ParseCron(spec)
+}
+
+// ParseCron reads a standard five-field cron expression: minute, hour, day
+// of month, month and day of week. Fields take *, values, ranges a-b, lists
+// and steps such as */15 or 1-5/2; months and days of week may be given by
+// their first three letters, and 7 is also Sunday. A task runs when the
+// month, hour and minute match and, if both day fields are restricted,
+// either of them does; otherwise both must match. As in Vixie cron, a day
+// field starting with *, such as */2, is not restricted, so "0 0 */2 * mon"
+// runs on Mondays that are odd days of the month. The descriptors
+// @yearly, @monthly, @weekly, @daily and @hourly are accepted too.
+func ParseCron(expr string) (*CronSchedule, *util.Result) {
+	spec := strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, util.MsgError("ParseCron", fmt.Sprintf("%q: expected 5 fields, got %d", expr, len(fields)))
+	}
+
+	s := &CronSchedule{expr: expr}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, util.Error("ParseCron", fmt.Errorf("%q: minute: %w", expr, err))
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, util.Error("ParseCron", fmt.Errorf("%q: hour: %w", expr, err))
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, util.Error("ParseCron", fmt.Errorf("%q: day of month: %w", expr, err))
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, util.Error("ParseCron", fmt.Errorf("%q: month: %w", expr, err))
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, dowNames); err != nil {
+		return nil, util.Error("ParseCron", fmt.Errorf("%q: day of week: %w", expr, err))
+	}
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domAny = strings.HasPrefix(fields[2], "*") || fields[2] == "?"
+	s.dowAny = strings.HasPrefix(fields[4], "*") || fields[4] == "?"
+	return s, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (bits, error) {
+	var b bits
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, z, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(a, min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(z, min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := cronValue(rng, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for i := lo; i <= hi; i += step {
+			b |= 1 << uint(i)
+		}
+	}
+	return b, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, min, max)
+	}
+	return v, nil
+}
+
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom.has(t.Day()), s.dow.has(int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first matching minute after t. Times skipped by a
+// daylight saving change don't match; times repeated by one match twice.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for !s.month.has(int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !s.hour.has(t.Hour()) {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if !next.After(t) {
+			// the hour is repeated by a daylight saving change
+			next = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		}
+		t = next
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !s.minute.has(t.Minute()) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
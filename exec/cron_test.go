@@ -0,0 +1,73 @@
+package exec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(loc *time.Location, s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		spec    string
+		from    time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "*/15 * * * *", from: at(time.UTC, "2026-10-17 10:07"), want: at(time.UTC, "2026-10-17 10:15")},
+		{spec: "*/15 * * * *", from: at(time.UTC, "2026-10-17 10:15"), want: at(time.UTC, "2026-10-17 10:30")},
+		{spec: "0 9 * * mon-fri", from: at(time.UTC, "2026-10-16 10:00"), want: at(time.UTC, "2026-10-19 09:00")},
+		{spec: "30 8 1,15 * *", from: at(time.UTC, "2026-10-15 09:00"), want: at(time.UTC, "2026-11-01 08:30")},
+		{spec: "0 0 13 * fri", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2026-10-23 00:00")},
+		{spec: "0 0 * * 7", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2026-10-18 00:00")},
+		// a day field starting with * does not restrict, so both must match
+		{spec: "0 0 */2 * tue", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2026-10-27 00:00")},
+		{spec: "0 0 13 * */2", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2026-12-13 00:00")},
+		{spec: "5/20 1-3/2 * JAN,jul *", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2027-01-01 01:05")},
+		{spec: "0 12 29 2 *", from: at(time.UTC, "2026-10-17 00:00"), want: at(time.UTC, "2028-02-29 12:00")},
+		{spec: "@hourly", from: at(time.UTC, "2026-10-17 10:59"), want: at(time.UTC, "2026-10-17 11:00")},
+		{spec: "@monthly", from: at(time.UTC, "2026-12-17 10:00"), want: at(time.UTC, "2027-01-01 00:00")},
+		{spec: "0 9 * * *", from: at(sydney, "2026-10-17 11:00"), want: at(sydney, "2026-10-18 09:00")},
+		// 02:30 doesn't exist in Berlin on 2026-03-29
+		{spec: "30 2 * * *", from: at(berlin, "2026-03-28 03:00"), want: at(berlin, "2026-03-30 02:30")},
+		{spec: "0 3 * * *", from: at(berlin, "2026-03-29 00:00"), want: at(berlin, "2026-03-29 03:00")},
+		{spec: "0 0 30 2 *", from: at(time.UTC, "2026-10-17 00:00")},
+		{spec: "@every 90m", from: at(time.UTC, "2026-10-17 10:07"), want: at(time.UTC, "2026-10-17 11:37")},
+		{spec: "@every 0s", wantErr: true},
+		{spec: "@every soon", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * foo *", wantErr: true},
+		{spec: "-1 * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, res := ParseSchedule(tt.spec)
+			if (res != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule() error = %v, wantErr %v", res, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
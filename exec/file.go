@@ -0,0 +1,288 @@
+package exec
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/loggers"
+	"github.com/soderasen-au/go-common/util"
+)
+
+const (
+	// MetaLogFile is the name of the log FileMetaKeeper keeps in its directory.
+	MetaLogFile = "metas.jsonl"
+
+	// DefaultCompactThreshold is the number of superseded records after which
+	// FileMetaKeeper compacts its log.
+	DefaultCompactThreshold = 1000
+)
+
+// FileMetaKeeper is a MetaKeeper that survives restarts. Every Set appends
+// the meta as one JSON line to MetaLogFile in its directory, and opening the
+// keeper replays the log, so the last record of each request wins.
+//
+// Requests still running or queued when the process stopped can never
+// finish, so they are recovered as failed. Metas are stored as RequestMeta.
+type FileMetaKeeper struct {
+	// Logger receives the errors of Set, which cannot return them.
+	Logger *zerolog.Logger
+	// CompactThreshold is the number of superseded records after which Set
+	// compacts the log; 0 turns automatic compaction off.
+	CompactThreshold int
+
+	mu         sync.Mutex
+	dir        string
+	file       *os.File
+	metas      map[string]*RequestMeta
+	superseded int
+}
+
+// NewFileMetaKeeper opens the keeper stored in dir, creating dir if needed,
+// recovers requests left running and compacts the log.
+func NewFileMetaKeeper(dir string) (*FileMetaKeeper, *util.Result) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, util.Error("MkdirAll", err)
+	}
+	k := &FileMetaKeeper{
+		Logger:           loggers.NullLogger,
+		CompactThreshold: DefaultCompactThreshold,
+		dir:              dir,
+		metas:            make(map[string]*RequestMeta),
+	}
+	if res := k.load(); res != nil {
+		return nil, res
+	}
+	k.recover()
+	if res := k.compact(); res != nil {
+		return nil, res
+	}
+	return k, nil
+}
+
+func (k *FileMetaKeeper) path() string {
+	return filepath.Join(k.dir, MetaLogFile)
+}
+
+// load replays the log. A line that does not decode, typically the last one
+// cut short by a crash, is skipped.
+func (k *FileMetaKeeper) load() *util.Result {
+	f, err := os.Open(k.path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return util.Error("Open", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		m := &RequestMeta{}
+		if err := json.Unmarshal(scanner.Bytes(), m); err != nil || m.RequestID == "" {
+			continue
+		}
+		k.metas[m.RequestID] = m
+	}
+	if err := scanner.Err(); err != nil {
+		return util.Error("ReadLog", err)
+	}
+	return nil
+}
+
+// recover fails the requests left running or queued by the previous process.
+func (k *FileMetaKeeper) recover() {
+	for _, m := range k.metas {
+		if m.Status != StautsRunning && m.Status != StatusQueued {
+			continue
+		}
+		m.Results = append(m.Results, util.MsgError("Recover", "request was still "+string(m.Status)+" when the keeper stopped"))
+		m.Status = StatusFailed
+		m.QueuePosition = 0
+	}
+}
+
+func (k *FileMetaKeeper) Get(reqId string) (Meta, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	m, ok := k.metas[reqId]
+	if !ok {
+		return nil, false
+	}
+	return m, true
+}
+
+// Set stores m and appends it to the log. The meta kept is m itself when it
+// is a *RequestMeta, so later changes to it are visible to Get but are only
+// written by the next Set or Transition.
+func (k *FileMetaKeeper) Set(m Meta) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.store(toRequestMeta(m))
+}
+
+func (k *FileMetaKeeper) SetIf(m Meta, cond func(cur Meta) bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	rm := toRequestMeta(m)
+	if cur, ok := k.metas[rm.ID()]; ok && !cond(cur) {
+		return false
+	}
+	k.store(rm)
+	return true
+}
+
+func (k *FileMetaKeeper) Transition(reqId string, from, to Status) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	m, ok := k.metas[reqId]
+	if !ok || !m.CompareAndSetStatus(from, to) {
+		return false
+	}
+	k.store(m)
+	return true
+}
+
+func toRequestMeta(m Meta) *RequestMeta {
+	if rm, ok := m.(*RequestMeta); ok {
+		return rm
+	}
+	return &RequestMeta{
+		RequestID: m.ID(),
+		FuncName:  m.Name(),
+		Results:   m.GetResults(),
+		Status:    m.GetStatus(),
+	}
+}
+
+// store keeps m and appends it to the log; the caller holds k.mu.
+func (k *FileMetaKeeper) store(m *RequestMeta) {
+	id := m.ID()
+	if _, ok := k.metas[id]; ok {
+		k.superseded++
+	}
+	k.metas[id] = m
+
+	if res := k.append(m); res != nil {
+		k.Logger.Error().Str("request", id).Err(res).Msg("FileMetaKeeper.Set")
+		return
+	}
+	if k.CompactThreshold > 0 && k.superseded >= k.CompactThreshold {
+		if res := k.compact(); res != nil {
+			k.Logger.Error().Err(res).Msg("FileMetaKeeper.Compact")
+		}
+	}
+}
+
+func (k *FileMetaKeeper) append(m *RequestMeta) *util.Result {
+	if k.file == nil {
+		return util.MsgError("Append", "keeper is closed")
+	}
+	line, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return util.Error("Marshal", err)
+	}
+	if _, err := k.file.Write(append(line, '\n')); err != nil {
+		return util.Error("Write", err)
+	}
+	if err := k.file.Sync(); err != nil {
+		return util.Error("Sync", err)
+	}
+	return nil
+}
+
+// Compact rewrites the log with only the latest record of each request.
+func (k *FileMetaKeeper) Compact() *util.Result {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.file == nil {
+		return util.MsgError("Compact", "keeper is closed")
+	}
+	return k.compact()
+}
+
+// compact writes the metas to a temporary file and renames it over the log,
+// so a crash leaves either the old or the new log, then syncs the directory
+// so that the rename itself survives one.
+func (k *FileMetaKeeper) compact() *util.Result {
+	tmp, err := os.CreateTemp(k.dir, MetaLogFile+".*.tmp")
+	if err != nil {
+		return util.Error("CreateTemp", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, m := range k.metas {
+		if err := enc.Encode(m.Snapshot()); err != nil {
+			tmp.Close()
+			return util.Error("Encode", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return util.Error("Flush", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return util.Error("Sync", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return util.Error("Close", err)
+	}
+
+	if k.file != nil {
+		k.file.Close()
+		k.file = nil
+	}
+	renameErr := os.Rename(tmp.Name(), k.path())
+	f, err := os.OpenFile(k.path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return util.Error("OpenFile", err)
+	}
+	k.file = f
+	if renameErr != nil {
+		return util.Error("Rename", renameErr)
+	}
+	k.superseded = 0
+	return syncDir(k.dir)
+}
+
+// syncDir flushes the entries of dir, such as a rename, to disk. Windows
+// can't sync a directory; there it does nothing.
+func syncDir(dir string) *util.Result {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return util.Error("OpenDir", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return util.Error("SyncDir", err)
+	}
+	return nil
+}
+
+// Close closes the log; a closed keeper still answers Get but Set no longer
+// persists.
+func (k *FileMetaKeeper) Close() *util.Result {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.file == nil {
+		return nil
+	}
+	err := k.file.Close()
+	k.file = nil
+	if err != nil {
+		return util.Error("Close", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+type testRequest struct {
+	id, name string
+	ok       bool
+	results  []*util.Result
+}
+
+func (r *testRequest) ID() string                  { return r.id }
+func (r *testRequest) Name() string                { return r.name }
+func (r *testRequest) Logger() *zerolog.Logger     { return nil }
+func (r *testRequest) Run() (bool, []*util.Result) { return r.ok, r.results }
+
+func countLines(t *testing.T, dir string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, MetaLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestFileMetaKeeper(t *testing.T) {
+	dir := t.TempDir()
+	k, res := NewFileMetaKeeper(dir)
+	if res != nil {
+		t.Fatal(res)
+	}
+	rk := NewRequestKeeper(k)
+	ok := &testRequest{id: "1", name: "ok", ok: true, results: []*util.Result{{Code: 0, Msg: "done"}}}
+	fail := &testRequest{id: "2", name: "fail", results: []*util.Result{util.MsgError("Run", "boom")}}
+	for _, req := range []Request{ok, fail} {
+		if _, res := rk.Register(req); res != nil {
+			t.Fatal(res)
+		}
+		rk.AsyncRun(req)
+	}
+	k.Set(&RequestMeta{RequestID: "3", FuncName: "stuck", Status: StautsRunning})
+	if res := k.Close(); res != nil {
+		t.Fatal(res)
+	}
+
+	k, res = NewFileMetaKeeper(dir)
+	if res != nil {
+		t.Fatal(res)
+	}
+	defer k.Close()
+	tests := []struct {
+		id      string
+		status  Status
+		lastMsg string
+	}{
+		{id: "1", status: StatusOk, lastMsg: "done"},
+		{id: "2", status: StatusFailed, lastMsg: "boom"},
+		{id: "3", status: StatusFailed, lastMsg: "request was still running when the keeper stopped"},
+	}
+	for _, tt := range tests {
+		m, ok := k.Get(tt.id)
+		if !ok {
+			t.Errorf("Get(%s) not found", tt.id)
+			continue
+		}
+		results := m.GetResults()
+		if m.GetStatus() != tt.status || len(results) == 0 || results[len(results)-1].Msg != tt.lastMsg {
+			t.Errorf("Get(%s) = %+v, want %s with %q", tt.id, m, tt.status, tt.lastMsg)
+		}
+	}
+	if got := countLines(t, dir); got != 3 {
+		t.Errorf("log has %d lines after opening, want 3", got)
+	}
+}
+
+func TestFileMetaKeeperCompact(t *testing.T) {
+	dir := t.TempDir()
+	k, res := NewFileMetaKeeper(dir)
+	if res != nil {
+		t.Fatal(res)
+	}
+	k.CompactThreshold = 0
+	m := &RequestMeta{RequestID: "1", FuncName: "f", Status: StatusReady}
+	for _, s := range []Status{StatusReady, StautsRunning, StatusOk} {
+		m.SetStatus(s)
+		k.Set(m)
+	}
+	if got := countLines(t, dir); got != 3 {
+		t.Errorf("log has %d lines, want 3", got)
+	}
+	if res := k.Compact(); res != nil {
+		t.Fatal(res)
+	}
+	if got := countLines(t, dir); got != 1 {
+		t.Errorf("log has %d lines after Compact, want 1", got)
+	}
+
+	k.CompactThreshold = 2
+	k.Set(m)
+	k.Set(m)
+	if got := countLines(t, dir); got != 1 {
+		t.Errorf("log has %d lines after reaching the threshold, want 1", got)
+	}
+	k.Close()
+	if res := k.Compact(); res == nil {
+		t.Error("Compact() of a closed keeper should fail")
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	dir := t.TempDir()
+	if res := syncDir(dir); res != nil {
+		t.Errorf("syncDir() = %v", res)
+	}
+	if runtime.GOOS != "windows" {
+		if res := syncDir(filepath.Join(dir, "missing")); res == nil {
+			t.Error("syncDir() of a missing directory should fail")
+		}
+	}
+}
+
+func TestFileMetaKeeperTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	log := `{"request_id":"1","func_name":"f","status":"succeeded"}` + "\n" + `{"request_id":"1","func_na`
+	if err := os.WriteFile(filepath.Join(dir, MetaLogFile), []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	k, res := NewFileMetaKeeper(dir)
+	if res != nil {
+		t.Fatal(res)
+	}
+	defer k.Close()
+	if m, ok := k.Get("1"); !ok || m.GetStatus() != StatusOk {
+		t.Errorf("Get(1) = %+v, %v, want succeeded", m, ok)
+	}
+}
@@ -1,11 +1,16 @@
 package exec
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/soderasen-au/go-common/loggers"
 	"github.com/soderasen-au/go-common/util"
 )
 
 type InMemMetaKeeper struct {
+	mu    sync.RWMutex
 	metas map[string]Meta
 }
 
@@ -16,34 +21,137 @@ func NewInMemMetaKeeper() *InMemMetaKeeper {
 }
 
 func (k *InMemMetaKeeper) Get(reqId string) (Meta, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
 	r, ok := k.metas[reqId]
 	return r, ok
 }
 
 func (k *InMemMetaKeeper) Set(m Meta) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
 	k.metas[m.ID()] = m
 }
 
+func (k *InMemMetaKeeper) SetIf(m Meta, cond func(cur Meta) bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cur, ok := k.metas[m.ID()]; ok && !cond(cur) {
+		return false
+	}
+	k.metas[m.ID()] = m
+	return true
+}
+
+func (k *InMemMetaKeeper) Transition(reqId string, from, to Status) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	m, ok := k.metas[reqId]
+	return ok && compareAndSetStatus(m, from, to)
+}
+
+// lockedMetaKeeper adds SetIf and Transition to a MetaKeeper without them;
+// they are single steps only among the callers of the same lockedMetaKeeper.
+type lockedMetaKeeper struct {
+	MetaKeeper
+	mu sync.Mutex
+}
+
+// atomicKeeper returns keeper as an AtomicMetaKeeper, wrapping it in a
+// lockedMetaKeeper if needed.
+func atomicKeeper(keeper MetaKeeper) AtomicMetaKeeper {
+	if k, ok := keeper.(AtomicMetaKeeper); ok {
+		return k
+	}
+	return &lockedMetaKeeper{MetaKeeper: keeper}
+}
+
+func (k *lockedMetaKeeper) Set(m Meta) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.MetaKeeper.Set(m)
+}
+
+func (k *lockedMetaKeeper) SetIf(m Meta, cond func(cur Meta) bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cur, ok := k.MetaKeeper.Get(m.ID()); ok && !cond(cur) {
+		return false
+	}
+	k.MetaKeeper.Set(m)
+	return true
+}
+
+func (k *lockedMetaKeeper) Transition(reqId string, from, to Status) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	m, ok := k.MetaKeeper.Get(reqId)
+	if !ok || !compareAndSetStatus(m, from, to) {
+		return false
+	}
+	k.MetaKeeper.Set(m)
+	return true
+}
+
 type InMemRequestKeeper struct {
-	keeper MetaKeeper
+	// Timeout stops requests running longer, retries included, as
+	// StatusTimedOut; 0 means no timeout. Requests implementing
+	// TimeoutRequest override it.
+	Timeout time.Duration
+
+	keeper  AtomicMetaKeeper
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+	retries map[string]RetryPolicy
 }
 
 func NewInMemRequestKeeper() *InMemRequestKeeper {
+	return NewRequestKeeper(NewInMemMetaKeeper())
+}
+
+// NewRequestKeeper returns a RequestKeeper storing its metas in keeper, e.g.
+// a FileMetaKeeper to keep them across restarts. A keeper that is not an
+// AtomicMetaKeeper must not be shared with other RequestKeepers.
+func NewRequestKeeper(keeper MetaKeeper) *InMemRequestKeeper {
 	k := new(InMemRequestKeeper)
-	k.keeper = NewInMemMetaKeeper()
+	k.keeper = atomicKeeper(keeper)
+	k.cancels = make(map[string]context.CancelCauseFunc)
+	k.retries = make(map[string]RetryPolicy)
 	return k
 }
 
+// SetRetryPolicy retries the failed requests named name by p.
+func (k *InMemRequestKeeper) SetRetryPolicy(name string, p RetryPolicy) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.retries[name] = p
+}
+
+func (k *InMemRequestKeeper) retryPolicy(name string) RetryPolicy {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.retries[name]
+}
+
 func (k *InMemRequestKeeper) Register(req Request) (*RequestMeta, *util.Result) {
-	if reqMeta, ok := k.keeper.Get(req.ID()); ok {
-		if reqMeta.GetStatus() == StautsRunning {
-			return nil, util.MsgError("UpdateMeta", "request is still running")
-		}
-	}
+	return k.register(req)
+}
+
+func (k *InMemRequestKeeper) RegisterContext(req ContextRequest) (*RequestMeta, *util.Result) {
+	return k.register(req)
+}
 
+func (k *InMemRequestKeeper) register(req Identifier) (*RequestMeta, *util.Result) {
 	reqMeta := &RequestMeta{}
 	reqMeta.Reset(req)
-	k.keeper.Set(reqMeta)
+	var cur Status
+	notPending := func(m Meta) bool {
+		cur = m.GetStatus()
+		return cur != StautsRunning && cur != StatusQueued
+	}
+	if !k.keeper.SetIf(reqMeta, notPending) {
+		return nil, util.MsgError("UpdateMeta", "request is still "+string(cur))
+	}
 	return reqMeta, nil
 }
 
@@ -53,6 +161,20 @@ func (k *InMemRequestKeeper) GetMeta(id string) (Meta, bool) {
 
 // caller should run in a co-routine
 func (k *InMemRequestKeeper) AsyncRun(req Request) {
+	k.RunContext(context.Background(), FromRequest(req))
+}
+
+// RunContext runs the registered request req, retrying it by the retry
+// policy of its name, and stopping it when ctx is done, its timeout passes
+// or it is cancelled; a stopped request ends as StatusCancelled or
+// StatusTimedOut with the cause added to its results.
+// caller should run in a co-routine
+func (k *InMemRequestKeeper) RunContext(ctx context.Context, req ContextRequest) {
+	k.run(ctx, req, StatusReady)
+}
+
+// run runs req if its status is from.
+func (k *InMemRequestKeeper) run(ctx context.Context, req ContextRequest, from Status) {
 	reqLogger := req.Logger()
 	if reqLogger == nil {
 		reqLogger = loggers.NullLogger
@@ -60,21 +182,99 @@ func (k *InMemRequestKeeper) AsyncRun(req Request) {
 	logger := reqLogger.With().Str("AsyncRun", req.ID()).Logger()
 
 	logger.Info().Msg("start")
-	if meta, ok := k.keeper.Get(req.ID()); ok {
-		if meta.GetStatus() != StatusReady {
+	if _, ok := k.keeper.Get(req.ID()); ok {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		timeout := timeoutOf(req)
+		if timeout <= 0 {
+			timeout = k.Timeout
+		}
+		if timeout > 0 {
+			var cancelTimeout context.CancelFunc
+			ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, ErrTimedOut)
+			defer cancelTimeout()
+		}
+
+		// the cancel func is in place as the request turns running, so a
+		// Cancel never finds it running but not cancellable
+		k.mu.Lock()
+		k.cancels[req.ID()] = cancel
+		if !k.keeper.Transition(req.ID(), from, StautsRunning) {
+			delete(k.cancels, req.ID())
+			k.mu.Unlock()
 			logger.Error().Msg("request is not ready to run. did you register it first? this request will be IGNORED!")
 			return
 		}
+		k.mu.Unlock()
+		defer func() {
+			k.mu.Lock()
+			delete(k.cancels, req.ID())
+			k.mu.Unlock()
+		}()
+		// a running request can't be registered again, so this is the meta
+		// just moved to running
+		meta, _ := k.keeper.Get(req.ID())
 
-		meta.SetStatus(StautsRunning)
-		succeeded, results := req.Run()
-		if !succeeded {
-			meta.SetStatus(StatusFailed)
-		} else {
-			meta.SetStatus(StatusOk)
+		policy := k.retryPolicy(req.Name())
+		var status Status
+		var results []*util.Result
+		for attempt := 1; ; attempt++ {
+			status, results = k.attempt(ctx, req, meta, attempt)
+			if status != StatusFailed || !policy.retries(attempt, results) {
+				break
+			}
+			backoff := policy.Backoff(attempt + 1)
+			logger.Warn().Int("attempt", attempt).Dur("backoff", backoff).Msg("retry")
+			k.keeper.Set(meta)
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-ctx.Done():
+				status = stopStatus(ctx)
+				results = append(results, util.Error("RunContext", context.Cause(ctx)))
+			}
+			break
+		}
+		if status == StatusCancelled || status == StatusTimedOut {
+			logger.Warn().Str("status", string(status)).Msg("stopped")
 		}
 		meta.SetResults(results)
+		k.keeper.Transition(req.ID(), StautsRunning, status)
 	} else {
 		logger.Error().Msg("can't find request meta. did you register it first? this request will be IGNORED!")
 	}
 }
+
+// attempt runs req once, recording it in meta.
+func (k *InMemRequestKeeper) attempt(ctx context.Context, req ContextRequest, meta Meta, number int) (Status, []*util.Result) {
+	a := &Attempt{Number: number, StartedAt: time.Now()}
+	succeeded, results := req.Run(ctx)
+	a.FinishedAt = time.Now()
+	a.Status = StatusOk
+	switch {
+	case ctx.Err() != nil:
+		a.Status = stopStatus(ctx)
+		results = append(results, util.Error("RunContext", context.Cause(ctx)))
+	case !succeeded:
+		a.Status = StatusFailed
+	}
+	a.Results = results
+	if m, ok := meta.(interface{ AddAttempt(a *Attempt) }); ok {
+		m.AddAttempt(a)
+	}
+	meta.SetResults(results)
+	return a.Status, results
+}
+
+// Cancel stops the running request id. Requests run through FromRequest are
+// only abandoned, see there.
+func (k *InMemRequestKeeper) Cancel(id string) *util.Result {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	cancel, ok := k.cancels[id]
+	if !ok {
+		return util.MsgError("Cancel", "request is not running")
+	}
+	cancel(ErrCancelled)
+	return nil
+}
@@ -0,0 +1,191 @@
+package exec
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// countingRequest counts its runs and blocks in Run until release is closed.
+type countingRequest struct {
+	id      string
+	runs    *int32
+	release chan struct{}
+}
+
+func (r *countingRequest) ID() string              { return r.id }
+func (r *countingRequest) Name() string            { return "counting" }
+func (r *countingRequest) Logger() *zerolog.Logger { return nil }
+func (r *countingRequest) Run() (bool, []*util.Result) {
+	atomic.AddInt32(r.runs, 1)
+	if r.release != nil {
+		<-r.release
+	}
+	return true, nil
+}
+
+// plainMetaKeeper is a MetaKeeper without SetIf and Transition, as written
+// before AtomicMetaKeeper.
+type plainMetaKeeper struct {
+	mu    sync.Mutex
+	metas map[string]Meta
+}
+
+func (k *plainMetaKeeper) Get(reqId string) (Meta, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	m, ok := k.metas[reqId]
+	return m, ok
+}
+
+func (k *plainMetaKeeper) Set(m Meta) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.metas[m.ID()] = m
+}
+
+var (
+	_ RequestKeeper        = (*InMemRequestKeeper)(nil)
+	_ ContextRequestKeeper = (*InMemRequestKeeper)(nil)
+	_ ContextRequestKeeper = (*QueuedRequestKeeper)(nil)
+	_ AtomicMetaKeeper     = (*InMemMetaKeeper)(nil)
+	_ AtomicMetaKeeper     = (*FileMetaKeeper)(nil)
+)
+
+func TestInMemRequestKeeperRegisterRace(t *testing.T) {
+	for name, keeper := range map[string]func() MetaKeeper{
+		"atomic": func() MetaKeeper { return NewInMemMetaKeeper() },
+		"plain":  func() MetaKeeper { return &plainMetaKeeper{metas: map[string]Meta{}} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			const n = 50
+			k := NewRequestKeeper(keeper())
+			var runs int32
+			release := make(chan struct{})
+			req := &countingRequest{id: "1", runs: &runs, release: release}
+			if _, res := k.Register(req); res != nil {
+				t.Fatal(res)
+			}
+
+			// only one of many concurrent runs of the same registration may start
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					k.AsyncRun(req)
+				}()
+			}
+			for {
+				if m, _ := k.GetMeta("1"); m.GetStatus() == StautsRunning {
+					break
+				}
+			}
+
+			// while it runs, every registration of it fails
+			var registered int32
+			var regWg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				regWg.Add(1)
+				go func() {
+					defer regWg.Done()
+					if _, res := k.Register(req); res == nil {
+						atomic.AddInt32(&registered, 1)
+					}
+				}()
+			}
+			regWg.Wait()
+			close(release)
+			wg.Wait()
+
+			if runs != 1 {
+				t.Errorf("request ran %d times, want 1", runs)
+			}
+			if registered != 0 {
+				t.Errorf("%d registrations succeeded while running, want 0", registered)
+			}
+			if m, _ := k.GetMeta("1"); m.GetStatus() != StatusOk {
+				t.Errorf("status = %s, want %s", m.GetStatus(), StatusOk)
+			}
+		})
+	}
+}
+
+func TestInMemRequestKeeperManyRequests(t *testing.T) {
+	const n = 200
+	for name, keeper := range map[string]func(t *testing.T) MetaKeeper{
+		"mem": func(t *testing.T) MetaKeeper { return NewInMemMetaKeeper() },
+		"file": func(t *testing.T) MetaKeeper {
+			k, res := NewFileMetaKeeper(t.TempDir())
+			if res != nil {
+				t.Fatal(res)
+			}
+			k.CompactThreshold = 64
+			t.Cleanup(func() { k.Close() })
+			return k
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			k := NewRequestKeeper(keeper(t))
+			var runs int32
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(2)
+				req := &countingRequest{id: fmt.Sprint(i % 20), runs: &runs}
+				go func() {
+					defer wg.Done()
+					if _, res := k.Register(req); res == nil {
+						k.AsyncRun(req)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					if m, ok := k.GetMeta(req.id); ok {
+						_ = m.GetStatus()
+						_ = m.GetResults()
+					}
+				}()
+			}
+			wg.Wait()
+			if runs == 0 {
+				t.Error("no request ran")
+			}
+			for i := 0; i < 20; i++ {
+				m, ok := k.GetMeta(fmt.Sprint(i))
+				if !ok {
+					t.Errorf("request %d has no meta", i)
+					continue
+				}
+				if s := m.GetStatus(); s != StatusOk && s != StatusReady {
+					t.Errorf("request %d status = %s", i, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestMetaCompareAndSetStatus(t *testing.T) {
+	const n = 100
+	m := &RequestMeta{RequestID: "1", Status: StatusReady}
+	var won int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.CompareAndSetStatus(StatusReady, StautsRunning) {
+				atomic.AddInt32(&won, 1)
+			}
+			_ = m.Snapshot()
+		}()
+	}
+	wg.Wait()
+	if won != 1 || m.GetStatus() != StautsRunning {
+		t.Errorf("%d goroutines won, status %s; want 1 and running", won, m.GetStatus())
+	}
+}
@@ -0,0 +1,245 @@
+package exec
+
+import (
+	"context"
+	"sync"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// QueuedRequestKeeper is a RequestKeeper running requests on a fixed number
+// of workers. AsyncRun and RunContext queue a registered request as
+// StatusQueued and return at once, so callers need no co-routine of their
+// own; requests are run in the order queued, except that a request whose
+// name is at its concurrency limit lets later ones pass.
+type QueuedRequestKeeper struct {
+	*InMemRequestKeeper
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*queuedRequest
+	limits  map[string]int
+	running map[string]int
+	// dispatched cancels the requests taken off the queue by a worker until
+	// it is done with them, so Cancel reaches them before run does.
+	dispatched map[string]context.CancelCauseFunc
+	closing    bool
+	workers    sync.WaitGroup
+	drained    chan struct{}
+	shutdown   sync.Once
+	stopCtx    context.Context
+	stop       context.CancelCauseFunc
+}
+
+type queuedRequest struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	req    ContextRequest
+}
+
+// NewQueuedRequestKeeper starts a keeper storing its metas in keeper and
+// running requests on workers workers, at least one.
+func NewQueuedRequestKeeper(keeper MetaKeeper, workers int) *QueuedRequestKeeper {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &QueuedRequestKeeper{
+		InMemRequestKeeper: NewRequestKeeper(keeper),
+		limits:             make(map[string]int),
+		running:            make(map[string]int),
+		dispatched:         make(map[string]context.CancelCauseFunc),
+		drained:            make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.stopCtx, q.stop = context.WithCancelCause(context.Background())
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	go func() {
+		q.workers.Wait()
+		close(q.drained)
+	}()
+	return q
+}
+
+// SetLimit allows at most n requests named name to run at once; n <= 0
+// removes the limit.
+func (q *QueuedRequestKeeper) SetLimit(name string, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if n <= 0 {
+		delete(q.limits, name)
+	} else {
+		q.limits[name] = n
+	}
+	q.cond.Broadcast()
+}
+
+// AsyncRun queues req; see Submit.
+func (q *QueuedRequestKeeper) AsyncRun(req Request) {
+	q.RunContext(context.Background(), FromRequest(req))
+}
+
+// RunContext queues req; see Submit.
+func (q *QueuedRequestKeeper) RunContext(ctx context.Context, req ContextRequest) {
+	if res := q.Submit(ctx, req); res != nil {
+		logger := req.Logger()
+		if logger != nil {
+			logger.Error().Str("AsyncRun", req.ID()).Err(res).Msg("request will be IGNORED!")
+		}
+	}
+}
+
+// Submit queues the registered request req to be run with ctx. It fails if
+// req is not ready or the keeper is shutting down.
+func (q *QueuedRequestKeeper) Submit(ctx context.Context, req ContextRequest) *util.Result {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return util.MsgError("Submit", "keeper is shutting down")
+	}
+	if !q.keeper.Transition(req.ID(), StatusReady, StatusQueued) {
+		return util.MsgError("Submit", "request is not ready to run. did you register it first?")
+	}
+	q.queue = append(q.queue, &queuedRequest{ctx: ctx, req: req})
+	q.cond.Broadcast()
+	return nil
+}
+
+// GetMeta returns the meta of request id; a RequestMeta is returned as a
+// Snapshot with its QueuePosition filled in, leaving the stored one as is.
+func (q *QueuedRequestKeeper) GetMeta(id string) (Meta, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m, ok := q.keeper.Get(id)
+	if rm, isRM := m.(*RequestMeta); ok && isRM {
+		snap := rm.Snapshot()
+		snap.QueuePosition, _ = q.position(id)
+		return snap, true
+	}
+	return m, ok
+}
+
+// QueuePosition returns the place of request id in the queue, from 1, or
+// false if it is not queued.
+func (q *QueuedRequestKeeper) QueuePosition(id string) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.position(id)
+}
+
+func (q *QueuedRequestKeeper) position(id string) (int, bool) {
+	for i, item := range q.queue {
+		if item.req.ID() == id {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// Cancel removes request id from the queue, or stops it if it is running;
+// either way it ends as StatusCancelled.
+func (q *QueuedRequestKeeper) Cancel(id string) *util.Result {
+	q.mu.Lock()
+	pos, queued := q.position(id)
+	if queued {
+		q.queue = append(q.queue[:pos-1], q.queue[pos:]...)
+	}
+	cancel, dispatched := q.dispatched[id]
+	q.mu.Unlock()
+	switch {
+	case queued:
+		q.dequeued(id, ErrCancelled)
+	case dispatched:
+		cancel(ErrCancelled)
+	default:
+		return q.InMemRequestKeeper.Cancel(id)
+	}
+	return nil
+}
+
+// dequeued ends request id, taken off the queue without running, as
+// cancelled by cause.
+func (q *QueuedRequestKeeper) dequeued(id string, cause error) {
+	if m, ok := q.keeper.Get(id); ok {
+		m.SetResults(append(m.GetResults(), util.Error("Cancel", cause)))
+	}
+	q.keeper.Transition(id, StatusQueued, StatusCancelled)
+}
+
+// Shutdown stops accepting requests and waits until the queued and running
+// ones are done. If ctx is done first, it cancels those left and returns
+// once the workers have stopped.
+func (q *QueuedRequestKeeper) Shutdown(ctx context.Context) *util.Result {
+	q.shutdown.Do(func() {
+		q.mu.Lock()
+		q.closing = true
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+
+	select {
+	case <-q.drained:
+		q.stop(ErrCancelled)
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	left := q.queue
+	q.queue = nil
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	for _, item := range left {
+		q.dequeued(item.req.ID(), ErrCancelled)
+	}
+	q.stop(ErrCancelled)
+	<-q.drained
+	return util.Error("Shutdown", ctx.Err())
+}
+
+func (q *QueuedRequestKeeper) work() {
+	defer q.workers.Done()
+	for {
+		item := q.next()
+		if item == nil {
+			return
+		}
+		stopped := context.AfterFunc(q.stopCtx, func() { item.cancel(context.Cause(q.stopCtx)) })
+		q.run(item.ctx, item.req, StatusQueued)
+		stopped()
+		item.cancel(nil)
+
+		q.mu.Lock()
+		delete(q.dispatched, item.req.ID())
+		q.running[item.req.Name()]--
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// next takes the first queued request whose name is under its limit,
+// waiting for one, and marks it dispatched; it returns nil once the keeper
+// is closing and the queue is empty.
+func (q *QueuedRequestKeeper) next() *queuedRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for i, item := range q.queue {
+			name := item.req.Name()
+			if limit, ok := q.limits[name]; ok && q.running[name] >= limit {
+				continue
+			}
+			q.queue = append(q.queue[:i], q.queue[i+1:]...)
+			q.running[name]++
+			item.ctx, item.cancel = context.WithCancelCause(item.ctx)
+			q.dispatched[item.req.ID()] = item.cancel
+			return item
+		}
+		if q.closing && len(q.queue) == 0 {
+			return nil
+		}
+		q.cond.Wait()
+	}
+}
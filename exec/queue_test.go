@@ -0,0 +1,214 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// gate counts the requests it holds, which run until it is opened or their
+// context is done.
+type gate struct {
+	mu      sync.Mutex
+	cur     int
+	max     int
+	started chan string
+	open    chan struct{}
+}
+
+func newGate() *gate {
+	return &gate{started: make(chan string, 100), open: make(chan struct{})}
+}
+
+type gateRequest struct {
+	id, name string
+	g        *gate
+}
+
+func (r *gateRequest) ID() string              { return r.id }
+func (r *gateRequest) Name() string            { return r.name }
+func (r *gateRequest) Logger() *zerolog.Logger { return nil }
+func (r *gateRequest) Run(ctx context.Context) (bool, []*util.Result) {
+	r.g.mu.Lock()
+	r.g.cur++
+	if r.g.cur > r.g.max {
+		r.g.max = r.g.cur
+	}
+	r.g.mu.Unlock()
+	defer func() {
+		r.g.mu.Lock()
+		r.g.cur--
+		r.g.mu.Unlock()
+	}()
+	r.g.started <- r.id
+	select {
+	case <-r.g.open:
+		return true, nil
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+func submit(t *testing.T, q *QueuedRequestKeeper, reqs ...*gateRequest) {
+	t.Helper()
+	for _, req := range reqs {
+		if _, res := q.RegisterContext(req); res != nil {
+			t.Fatal(res)
+		}
+		if res := q.Submit(context.Background(), req); res != nil {
+			t.Fatal(res)
+		}
+	}
+}
+
+func status(q *QueuedRequestKeeper, id string) Status {
+	m, _ := q.GetMeta(id)
+	return m.GetStatus()
+}
+
+func TestQueuedRequestKeeperWorkers(t *testing.T) {
+	q := NewQueuedRequestKeeper(NewInMemMetaKeeper(), 2)
+	g := newGate()
+	var reqs []*gateRequest
+	for i := 1; i <= 6; i++ {
+		reqs = append(reqs, &gateRequest{id: fmt.Sprint(i), name: "r", g: g})
+	}
+	submit(t, q, reqs...)
+	<-g.started
+	<-g.started
+
+	for i, want := range []int{0, 0, 1, 2, 3, 4} {
+		id := fmt.Sprint(i + 1)
+		m, _ := q.GetMeta(id)
+		if got := m.(*RequestMeta).GetQueuePosition(); got != want {
+			t.Errorf("request %s QueuePosition = %d, want %d", id, got, want)
+		}
+		if want > 0 && m.GetStatus() != StatusQueued {
+			t.Errorf("request %s status = %s, want queued", id, m.GetStatus())
+		}
+		if stored, _ := q.keeper.Get(id); stored.(*RequestMeta).GetQueuePosition() != 0 {
+			t.Errorf("GetMeta() of request %s changed the stored QueuePosition", id)
+		}
+	}
+	if res := q.Submit(context.Background(), reqs[5]); res == nil {
+		t.Error("Submit() of a queued request should fail")
+	}
+	if _, res := q.RegisterContext(reqs[5]); res == nil {
+		t.Error("Register() of a queued request should fail")
+	}
+
+	close(g.open)
+	if res := q.Shutdown(context.Background()); res != nil {
+		t.Fatal(res)
+	}
+	for _, req := range reqs {
+		if s := status(q, req.id); s != StatusOk {
+			t.Errorf("request %s status = %s, want succeeded", req.id, s)
+		}
+	}
+	if g.max != 2 {
+		t.Errorf("%d requests ran at once, want 2", g.max)
+	}
+	if _, res := q.RegisterContext(reqs[0]); res != nil {
+		t.Fatal(res)
+	}
+	if res := q.Submit(context.Background(), reqs[0]); res == nil {
+		t.Error("Submit() after Shutdown() should fail")
+	}
+}
+
+func TestQueuedRequestKeeperLimit(t *testing.T) {
+	q := NewQueuedRequestKeeper(NewInMemMetaKeeper(), 4)
+	q.SetLimit("a", 1)
+	g := newGate()
+	submit(t, q,
+		&gateRequest{id: "a1", name: "a", g: g},
+		&gateRequest{id: "a2", name: "a", g: g},
+		&gateRequest{id: "b1", name: "b", g: g},
+	)
+	started := map[string]bool{<-g.started: true, <-g.started: true}
+	if !started["a1"] || !started["b1"] {
+		t.Errorf("started %v, want a1 and b1", started)
+	}
+	if pos, ok := q.QueuePosition("a2"); !ok || pos != 1 {
+		t.Errorf("QueuePosition(a2) = %d, %v, want 1", pos, ok)
+	}
+
+	// cancelling a queued request takes it off the queue
+	if res := q.Cancel("a2"); res != nil {
+		t.Fatal(res)
+	}
+	if s := status(q, "a2"); s != StatusCancelled {
+		t.Errorf("a2 status = %s, want cancelled", s)
+	}
+	if res := q.Cancel("a1"); res != nil {
+		t.Fatal(res)
+	}
+	close(g.open)
+	if res := q.Shutdown(context.Background()); res != nil {
+		t.Fatal(res)
+	}
+	for id, want := range map[string]Status{"a1": StatusCancelled, "a2": StatusCancelled, "b1": StatusOk} {
+		if s := status(q, id); s != want {
+			t.Errorf("%s status = %s, want %s", id, s, want)
+		}
+	}
+}
+
+// transitionHook calls hook before each Transition of its AtomicMetaKeeper.
+type transitionHook struct {
+	AtomicMetaKeeper
+	hook func(id string, to Status)
+}
+
+func (k *transitionHook) Transition(id string, from, to Status) bool {
+	k.hook(id, to)
+	return k.AtomicMetaKeeper.Transition(id, from, to)
+}
+
+func TestQueuedRequestKeeperCancelDispatched(t *testing.T) {
+	// cancel the request after a worker took it off the queue but before
+	// it is running
+	var q *QueuedRequestKeeper
+	var res *util.Result
+	keeper := &transitionHook{AtomicMetaKeeper: NewInMemMetaKeeper(), hook: func(id string, to Status) {
+		if to == StautsRunning {
+			res = q.Cancel(id)
+		}
+	}}
+	q = NewQueuedRequestKeeper(keeper, 1)
+	submit(t, q, &gateRequest{id: "1", name: "r", g: newGate()})
+	if res := q.Shutdown(context.Background()); res != nil {
+		t.Fatal(res)
+	}
+	if res != nil {
+		t.Errorf("Cancel() = %v, want nil", res)
+	}
+	if s := status(q, "1"); s != StatusCancelled {
+		t.Errorf("status = %s, want cancelled", s)
+	}
+}
+
+func TestQueuedRequestKeeperShutdownTimeout(t *testing.T) {
+	q := NewQueuedRequestKeeper(NewInMemMetaKeeper(), 1)
+	g := newGate()
+	submit(t, q, &gateRequest{id: "1", name: "r", g: g}, &gateRequest{id: "2", name: "r", g: g})
+	<-g.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if res := q.Shutdown(ctx); res == nil {
+		t.Error("Shutdown() = nil, want the context error")
+	}
+	for _, id := range []string{"1", "2"} {
+		if s := status(q, id); s != StatusCancelled {
+			t.Errorf("request %s status = %s, want cancelled", id, s)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package exec
 
 import (
+	"context"
+
 	"github.com/rs/zerolog"
 
 	"github.com/soderasen-au/go-common/util"
@@ -13,11 +15,24 @@ const (
 	StatusFailed  Status = "failed"
 	StatusOk      Status = "succeeded"
 	StautsRunning Status = "running"
+	// StatusQueued is a request waiting for a worker of a
+	// QueuedRequestKeeper.
+	StatusQueued Status = "queued"
+	// StatusCancelled is a request stopped by RequestKeeper.Cancel or by the
+	// cancellation of the context it was run with.
+	StatusCancelled Status = "cancelled"
+	// StatusTimedOut is a request stopped by its timeout.
+	StatusTimedOut Status = "timed_out"
 )
 
-type Request interface {
+// Identifier is what a keeper needs to know of a request to register it.
+type Identifier interface {
 	ID() string
 	Name() string
+}
+
+type Request interface {
+	Identifier
 	Logger() *zerolog.Logger
 	Run() (bool, []*util.Result)
 }
@@ -31,13 +46,40 @@ type Meta interface {
 	SetStatus(s Status)
 }
 
+// MetaKeeper stores the metas of requests. Implementations must be safe for
+// concurrent use.
 type MetaKeeper interface {
 	Get(reqId string) (Meta, bool)
 	Set(req Meta)
 }
 
+// AtomicMetaKeeper is a MetaKeeper that also updates a meta as a single
+// step. NewRequestKeeper uses these methods when its keeper has them, and
+// otherwise makes them single steps only among its own calls.
+type AtomicMetaKeeper interface {
+	MetaKeeper
+	// SetIf stores m if its request has no meta yet or cond accepts the
+	// current one, as a single step; it reports whether m was stored.
+	SetIf(m Meta, cond func(cur Meta) bool) bool
+	// Transition moves the status of request reqId from one to another as a
+	// single step, reporting false if it has no meta or is not in status from.
+	Transition(reqId string, from, to Status) bool
+}
+
 type RequestKeeper interface {
 	Register(req Request) (*RequestMeta, *util.Result)
 	GetMeta(id string) (Meta, bool)
 	AsyncRun(req Request)
 }
+
+// ContextRequestKeeper is a RequestKeeper that also runs ContextRequests,
+// which it can stop.
+type ContextRequestKeeper interface {
+	RequestKeeper
+	// RegisterContext registers req like Register.
+	RegisterContext(req ContextRequest) (*RequestMeta, *util.Result)
+	// RunContext runs req like AsyncRun, until ctx is done at the latest.
+	RunContext(ctx context.Context, req ContextRequest)
+	// Cancel stops the running request id; it ends as StatusCancelled.
+	Cancel(id string) *util.Result
+}
@@ -1,43 +1,136 @@
 package exec
 
 import (
+	"sync"
+
 	"github.com/soderasen-au/go-common/util"
 )
 
+// RequestMeta is safe for concurrent use through its methods; reading or
+// writing its fields directly is not.
 type RequestMeta struct {
 	RequestID string         `json:"request_id,omitempty" yaml:"request_id,omitempty" bson:"request_id,omitempty"`
 	FuncName  string         `json:"func_name,omitempty" yaml:"func_name,omitempty" bson:"func_name,omitempty"`
 	Results   []*util.Result `json:"results,omitempty" yaml:"result,omitempty" bson:"results,omitempty"`
 	Status    Status         `json:"status,omitempty" yaml:"status,omitempty" bson:"status,omitempty"`
+	// QueuePosition is the place of a StatusQueued request in its queue,
+	// from 1, in the snapshots returned by QueuedRequestKeeper.GetMeta.
+	QueuePosition int `json:"queue_position,omitempty" yaml:"queue_position,omitempty" bson:"queue_position,omitempty"`
+	// Attempts are the runs of the request, more than one if it was retried;
+	// Results are those of the last.
+	Attempts []*Attempt `json:"attempts,omitempty" yaml:"attempts,omitempty" bson:"attempts,omitempty"`
+
+	mu sync.RWMutex
 }
 
 func (m *RequestMeta) GetResults() []*util.Result {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.Results
 }
 
 func (m *RequestMeta) SetResults(r []*util.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Results = r
 }
 
 func (m *RequestMeta) SetStatus(s Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Status = s
 }
 
+// CompareAndSetStatus sets the status to new if it is old, reporting whether
+// it did.
+func (m *RequestMeta) CompareAndSetStatus(old, new Status) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Status != old {
+		return false
+	}
+	m.Status = new
+	return true
+}
+
+func (m *RequestMeta) GetQueuePosition() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.QueuePosition
+}
+
+func (m *RequestMeta) SetQueuePosition(p int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.QueuePosition = p
+}
+
+func (m *RequestMeta) GetAttempts() []*Attempt {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Attempts
+}
+
+func (m *RequestMeta) AddAttempt(a *Attempt) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Attempts = append(m.Attempts, a)
+}
+
 func (m *RequestMeta) ID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.RequestID
 }
 
 func (m *RequestMeta) Name() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.FuncName
 }
 
 func (m *RequestMeta) GetStatus() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.Status
 }
 
-func (meta *RequestMeta) Reset(req Request) {
+// Snapshot returns a copy of m taken at once, e.g. to be encoded while m is
+// still being updated.
+func (m *RequestMeta) Snapshot() *RequestMeta {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return &RequestMeta{
+		RequestID: m.RequestID,
+		FuncName:  m.FuncName,
+		Results:   append([]*util.Result(nil), m.Results...),
+		Status:    m.Status,
+
+		QueuePosition: m.QueuePosition,
+		Attempts:      append([]*Attempt(nil), m.Attempts...),
+	}
+}
+
+func (meta *RequestMeta) Reset(req Identifier) {
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
 	meta.RequestID = req.ID()
 	meta.FuncName = req.Name()
 	meta.Results = nil
 	meta.Status = StatusReady
+	meta.QueuePosition = 0
+	meta.Attempts = nil
+}
+
+// compareAndSetStatus is RequestMeta.CompareAndSetStatus for any Meta; for
+// those without it, it is only atomic against callers holding the same lock.
+func compareAndSetStatus(m Meta, old, new Status) bool {
+	if cas, ok := m.(interface{ CompareAndSetStatus(old, new Status) bool }); ok {
+		return cas.CompareAndSetStatus(old, new)
+	}
+	if m.GetStatus() != old {
+		return false
+	}
+	m.SetStatus(new)
+	return true
 }
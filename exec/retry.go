@@ -0,0 +1,82 @@
+package exec
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// RetryPolicy decides whether and when a failed request is run again. The
+// zero policy runs a request once.
+type RetryPolicy struct {
+	// MaxAttempts is the number of runs at most, the first included.
+	MaxAttempts int `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	// InitialBackoff is the wait before the second attempt; every later one
+	// waits Multiplier times longer, up to MaxBackoff if it is set.
+	InitialBackoff time.Duration `json:"initial_backoff,omitempty" yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+	// Multiplier defaults to 2.
+	Multiplier float64 `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	// Jitter shortens each wait by a random part of up to Jitter of it, from
+	// 0 to 1, so failed requests don't all retry at once.
+	Jitter float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// RetryOn reports whether a failed attempt with these results is worth
+	// retrying; nil retries every failure.
+	RetryOn func(results []*util.Result) bool `json:"-" yaml:"-"`
+}
+
+// Backoff returns the wait before attempt, from 2.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-2))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		d -= d * jitter * rand.Float64()
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// retries reports whether an attempt that failed with results is followed
+// by another.
+func (p RetryPolicy) retries(attempt int, results []*util.Result) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.RetryOn == nil || p.RetryOn(results)
+}
+
+// RetryOnCodes returns a RetryPolicy.RetryOn retrying when any result, or
+// any of their inner results, has one of codes.
+func RetryOnCodes(codes ...int) func(results []*util.Result) bool {
+	return func(results []*util.Result) bool {
+		for _, r := range results {
+			for ; r != nil; r = r.Inner {
+				for _, c := range codes {
+					if r.Code == c {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
+
+// Attempt records one run of a request.
+type Attempt struct {
+	Number     int            `json:"number" yaml:"number" bson:"number"`
+	StartedAt  time.Time      `json:"started_at" yaml:"started_at" bson:"started_at"`
+	FinishedAt time.Time      `json:"finished_at" yaml:"finished_at" bson:"finished_at"`
+	Status     Status         `json:"status,omitempty" yaml:"status,omitempty" bson:"status,omitempty"`
+	Results    []*util.Result `json:"results,omitempty" yaml:"results,omitempty" bson:"results,omitempty"`
+}
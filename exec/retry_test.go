@@ -0,0 +1,168 @@
+package exec
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// flakyRequest fails with code until it has run failures times.
+type flakyRequest struct {
+	id       string
+	failures int32
+	code     int
+	runs     int32
+}
+
+func (r *flakyRequest) ID() string              { return r.id }
+func (r *flakyRequest) Name() string            { return "flaky" }
+func (r *flakyRequest) Logger() *zerolog.Logger { return nil }
+func (r *flakyRequest) Run(ctx context.Context) (bool, []*util.Result) {
+	if atomic.AddInt32(&r.runs, 1) <= r.failures {
+		return false, []*util.Result{{Code: r.code, Msg: "unavailable"}}
+	}
+	return true, []*util.Result{{Msg: "done"}}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		2: 100 * time.Millisecond,
+		3: 200 * time.Millisecond,
+		4: 400 * time.Millisecond,
+		6: time.Second,
+	} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	p.Multiplier, p.Jitter = 3, 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(3); got < 150*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("Backoff(3) with jitter = %v, want within [150ms, 300ms]", got)
+		}
+	}
+}
+
+func TestRetryOnCodes(t *testing.T) {
+	retryOn := RetryOnCodes(503, 504)
+	tests := []struct {
+		name    string
+		results []*util.Result
+		want    bool
+	}{
+		{name: "none", want: false},
+		{name: "match", results: []*util.Result{{Code: 500}, {Code: 503}}, want: true},
+		{name: "inner", results: []*util.Result{{Code: -1, Inner: &util.Result{Code: 504}}}, want: true},
+		{name: "other", results: []*util.Result{{Code: 400}}, want: false},
+	}
+	for _, tt := range tests {
+		if got := retryOn(tt.results); got != tt.want {
+			t.Errorf("%s: RetryOnCodes() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunContextRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *flakyRequest
+		policy   RetryPolicy
+		want     Status
+		attempts []Status
+	}{
+		{
+			name:     "succeeds on retry",
+			req:      &flakyRequest{id: "1", failures: 2, code: 503},
+			policy:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Jitter: 0.5},
+			want:     StatusOk,
+			attempts: []Status{StatusFailed, StatusFailed, StatusOk},
+		},
+		{
+			name:     "attempts exhausted",
+			req:      &flakyRequest{id: "2", failures: 5, code: 503},
+			policy:   RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			want:     StatusFailed,
+			attempts: []Status{StatusFailed, StatusFailed},
+		},
+		{
+			name:     "code not retried",
+			req:      &flakyRequest{id: "3", failures: 1, code: 400},
+			policy:   RetryPolicy{MaxAttempts: 3, RetryOn: RetryOnCodes(503)},
+			want:     StatusFailed,
+			attempts: []Status{StatusFailed},
+		},
+		{
+			name:     "no policy",
+			req:      &flakyRequest{id: "4", failures: 1},
+			want:     StatusFailed,
+			attempts: []Status{StatusFailed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewInMemRequestKeeper()
+			k.SetRetryPolicy("flaky", tt.policy)
+			if _, res := k.RegisterContext(tt.req); res != nil {
+				t.Fatal(res)
+			}
+			k.RunContext(context.Background(), tt.req)
+
+			m, _ := k.GetMeta(tt.req.id)
+			if m.GetStatus() != tt.want {
+				t.Errorf("status = %s, want %s", m.GetStatus(), tt.want)
+			}
+			attempts := m.(*RequestMeta).GetAttempts()
+			if len(attempts) != len(tt.attempts) {
+				t.Fatalf("%d attempts, want %d", len(attempts), len(tt.attempts))
+			}
+			var last time.Time
+			for i, a := range attempts {
+				if a.Number != i+1 || a.Status != tt.attempts[i] || len(a.Results) != 1 {
+					t.Errorf("attempt %d = %+v, want %s", i+1, a, tt.attempts[i])
+				}
+				if a.StartedAt.Before(last) || a.FinishedAt.Before(a.StartedAt) {
+					t.Errorf("attempt %d ran from %v to %v, after %v", i+1, a.StartedAt, a.FinishedAt, last)
+				}
+				last = a.FinishedAt
+			}
+			if got := m.GetResults(); len(got) != 1 || got[0] != attempts[len(attempts)-1].Results[0] {
+				t.Errorf("results = %v, want those of the last attempt", got)
+			}
+		})
+	}
+}
+
+func TestRunContextRetryCancel(t *testing.T) {
+	k := NewInMemRequestKeeper()
+	k.SetRetryPolicy("flaky", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute})
+	req := &flakyRequest{id: "1", failures: 5}
+	if _, res := k.RegisterContext(req); res != nil {
+		t.Fatal(res)
+	}
+	done := make(chan struct{})
+	go func() {
+		k.RunContext(context.Background(), req)
+		close(done)
+	}()
+	for atomic.LoadInt32(&req.runs) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for k.Cancel("1") != nil {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not cancelled during its backoff")
+	}
+	m, _ := k.GetMeta("1")
+	if m.GetStatus() != StatusCancelled || len(m.(*RequestMeta).GetAttempts()) != 1 {
+		t.Errorf("meta = %+v, want cancelled after 1 attempt", m)
+	}
+}
@@ -0,0 +1,330 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/loggers"
+	"github.com/soderasen-au/go-common/util"
+)
+
+// MissedRuns decides what a Scheduler does with the runs of a Task that
+// were due while it was not running, e.g. during downtime.
+type MissedRuns string
+
+const (
+	// MissedRunsSkip drops missed runs; the task runs next when it is due
+	// again. This is the default.
+	MissedRunsSkip MissedRuns = "skip"
+	// MissedRunsOnce runs a task once for all its missed runs.
+	MissedRunsOnce MissedRuns = "once"
+	// MissedRunsCatchUp runs a task once for each of its missed runs, up to
+	// ScheduledTask.MaxCatchUp.
+	MissedRunsCatchUp MissedRuns = "catch_up"
+)
+
+const (
+	// DefaultMissedAfter is the default Scheduler.MissedAfter.
+	DefaultMissedAfter = time.Minute
+	// DefaultPollInterval is the default Scheduler.PollInterval.
+	DefaultPollInterval = time.Second
+
+	// SchedulerStartedBy is the Job.StartedBy of the jobs a Scheduler starts.
+	SchedulerStartedBy = "scheduler"
+
+	// maxMissedRuns bounds the missed runs of a task counted at once.
+	maxMissedRuns = 10000
+)
+
+// ScheduledTask is a Task with what a Scheduler needs to run it.
+type ScheduledTask struct {
+	Task     *Task
+	Schedule Schedule
+	Missed   MissedRuns
+	// MaxCatchUp bounds the runs of MissedRunsCatchUp to the latest ones;
+	// 0 means no bound.
+	MaxCatchUp int
+	// NewRequest returns the request of the Job for the run due at due.
+	// Each must have an ID of its own, see JobRequestID.
+	NewRequest func(task *Task, due time.Time) ContextRequest
+}
+
+// JobRequestID returns a request ID for the run of task due at due.
+func JobRequestID(task string, due time.Time) string {
+	return fmt.Sprintf("%s@%s", task, due.UTC().Format(time.RFC3339Nano))
+}
+
+// Scheduler runs Tasks at their scheduled times: when a Task's NextRun is
+// due, it registers and runs a request for it with the ContextRequestKeeper as a
+// new Job, computes the following NextRun and, once the Job is done,
+// records it with Task.RecordJob.
+//
+// A Task given with a NextRun, e.g. restored from storage, keeps it; runs
+// that became due while the scheduler was not running are handled by the
+// task's MissedRuns policy. Jobs of the same Task may overlap; use a
+// QueuedRequestKeeper with a limit to prevent that.
+type Scheduler struct {
+	// Location is the timezone schedules are read in; nil means time.Local.
+	Location *time.Location
+	// MissedAfter is how late a run may start; later, it has been missed.
+	MissedAfter time.Duration
+	// PollInterval is how often the status of a Job is checked when the
+	// keeper runs requests in the background.
+	PollInterval time.Duration
+	// OnChange is called with a task whenever the scheduler changed it, to
+	// persist it. It must not call the scheduler.
+	OnChange func(task *Task)
+	Logger   *zerolog.Logger
+
+	keeper ContextRequestKeeper
+	now    func() time.Time
+	mu     sync.Mutex
+	tasks  map[string]*ScheduledTask
+	wake   chan struct{}
+	jobs   sync.WaitGroup
+}
+
+// NewScheduler returns a Scheduler starting jobs with keeper, reading
+// schedules in loc.
+func NewScheduler(keeper ContextRequestKeeper, loc *time.Location) *Scheduler {
+	return &Scheduler{
+		Location:     loc,
+		MissedAfter:  DefaultMissedAfter,
+		PollInterval: DefaultPollInterval,
+		Logger:       loggers.NullLogger,
+		keeper:       keeper,
+		now:          time.Now,
+		tasks:        make(map[string]*ScheduledTask),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (s *Scheduler) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// Add schedules st, replacing any task of the same name. Its NextRun is
+// computed unless it has one.
+func (s *Scheduler) Add(st *ScheduledTask) *util.Result {
+	if st.Task == nil || st.Task.Name == "" {
+		return util.MsgError("Add", "task must have a name")
+	}
+	if st.Schedule == nil || st.NewRequest == nil {
+		return util.MsgError("Add", fmt.Sprintf("task %s needs a Schedule and NewRequest", st.Task.Name))
+	}
+	switch st.Missed {
+	case "":
+		st.Missed = MissedRunsSkip
+	case MissedRunsSkip, MissedRunsOnce, MissedRunsCatchUp:
+	default:
+		return util.MsgError("Add", fmt.Sprintf("unknown missed runs policy %q", st.Missed))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st.Task.NextRun == nil {
+		if next := st.Schedule.Next(s.now().In(s.location())); !next.IsZero() {
+			st.Task.NextRun = &next
+		}
+		s.changed(st.Task)
+	}
+	s.tasks[st.Task.Name] = st
+	s.notify()
+	return nil
+}
+
+// Remove unschedules the task name; its running Job is left to finish.
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, name)
+	s.notify()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// changed reports task to OnChange; the caller holds s.mu.
+func (s *Scheduler) changed(task *Task) {
+	if s.OnChange != nil {
+		s.OnChange(task)
+	}
+}
+
+// Run starts the jobs of the tasks as they are due until ctx is done, then
+// waits for the jobs running, whose context it is, to finish.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.jobs.Wait()
+	for {
+		next := s.tick(ctx, s.now())
+
+		var due <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(s.now()))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-due:
+		case <-s.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// tick starts the jobs due at now and returns when the next one is.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now = now.In(s.location())
+	var earliest time.Time
+	for _, st := range s.tasks {
+		task := st.Task
+		if task.NextRun != nil && !task.NextRun.After(now) {
+			for _, due := range s.dueRuns(st, now) {
+				s.start(ctx, st, due, now)
+			}
+			s.changed(task)
+		}
+		if task.NextRun != nil && (earliest.IsZero() || task.NextRun.Before(earliest)) {
+			earliest = *task.NextRun
+		}
+	}
+	return earliest
+}
+
+// dueRuns returns the runs of st to start at now, by its MissedRuns policy,
+// and moves its NextRun after now.
+func (s *Scheduler) dueRuns(st *ScheduledTask, now time.Time) []time.Time {
+	task := st.Task
+	missedBefore := now.Add(-s.MissedAfter)
+	var missed, onTime []time.Time
+	next := task.NextRun.In(s.location())
+	for n := 0; !next.IsZero() && !next.After(now); n++ {
+		if n == maxMissedRuns {
+			s.Logger.Warn().Str("task", task.Name).Msg("too many missed runs, the rest is skipped")
+			next = st.Schedule.Next(now)
+			break
+		}
+		if next.Before(missedBefore) {
+			missed = append(missed, next)
+		} else {
+			onTime = append(onTime, next)
+		}
+		next = st.Schedule.Next(next)
+	}
+	if next.IsZero() {
+		task.NextRun = nil
+	} else {
+		task.NextRun = &next
+	}
+
+	if len(missed) > 0 {
+		s.Logger.Warn().Str("task", task.Name).Int("missed", len(missed)).Str("policy", string(st.Missed)).Msg("missed runs")
+	}
+	switch st.Missed {
+	case MissedRunsOnce:
+		if len(missed) > 0 && len(onTime) == 0 {
+			return missed[len(missed)-1:]
+		}
+	case MissedRunsCatchUp:
+		if st.MaxCatchUp > 0 && len(missed) > st.MaxCatchUp {
+			missed = missed[len(missed)-st.MaxCatchUp:]
+		}
+		return append(missed, onTime...)
+	}
+	return onTime
+}
+
+// start registers and runs the request of the run of st due at due as a new
+// Job; the caller holds s.mu.
+func (s *Scheduler) start(ctx context.Context, st *ScheduledTask, due, now time.Time) {
+	task := st.Task
+	req := st.NewRequest(task, due)
+	job := &Job{
+		TaskName:  task.Name,
+		ReqID:     req.ID(),
+		Status:    StautsRunning,
+		StartedBy: SchedulerStartedBy,
+		StartedAt: now,
+	}
+	task.LastJob = job
+	if _, res := s.keeper.RegisterContext(req); res != nil {
+		s.Logger.Error().Str("task", task.Name).Err(res).Msg("Register")
+		s.finish(task, job, StatusFailed, []*util.Result{res})
+		return
+	}
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		s.keeper.RunContext(ctx, req)
+		status, results := s.wait(ctx, req.ID())
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.finish(task, job, status, results)
+		s.changed(task)
+	}()
+}
+
+// wait returns the outcome of request id once it is done, polling for it
+// if the keeper runs requests in the background.
+func (s *Scheduler) wait(ctx context.Context, id string) (Status, []*util.Result) {
+	for {
+		m, ok := s.keeper.GetMeta(id)
+		if !ok {
+			return StatusFailed, []*util.Result{util.MsgError("Wait", "request meta is gone")}
+		}
+		switch status := m.GetStatus(); {
+		case isDone(status):
+			return status, m.GetResults()
+		case status == StatusReady:
+			// RunContext has returned without even queueing it
+			return StatusFailed, []*util.Result{util.MsgError("Wait", "request was not run")}
+		}
+		select {
+		case <-ctx.Done():
+			// the keeper is stopping the request too; give it a last look
+			s.keeper.Cancel(id)
+			if m, ok := s.keeper.GetMeta(id); ok && isDone(m.GetStatus()) {
+				return m.GetStatus(), m.GetResults()
+			}
+			return StatusCancelled, []*util.Result{util.Error("Wait", context.Cause(ctx))}
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+func isDone(s Status) bool {
+	switch s {
+	case StatusOk, StatusFailed, StatusCancelled, StatusTimedOut:
+		return true
+	}
+	return false
+}
+
+// finish records the outcome of job in task; the caller holds s.mu.
+func (s *Scheduler) finish(task *Task, job *Job, status Status, results []*util.Result) {
+	for _, res := range task.RecordJob(job, status, results, s.now()) {
+		s.Logger.Error().Str("task", task.Name).Err(res).Msg("RecordJob")
+	}
+}
@@ -0,0 +1,175 @@
+package exec
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+type jobRequest struct {
+	id   string
+	ok   bool
+	runs *int32
+}
+
+func (r *jobRequest) ID() string              { return r.id }
+func (r *jobRequest) Name() string            { return "job" }
+func (r *jobRequest) Logger() *zerolog.Logger { return nil }
+func (r *jobRequest) Run(ctx context.Context) (bool, []*util.Result) {
+	atomic.AddInt32(r.runs, 1)
+	if !r.ok {
+		return false, []*util.Result{{Code: 0, Msg: "info"}, {Code: 503, Msg: "unavailable"}}
+	}
+	return true, nil
+}
+
+// jobTask schedules jobRequests succeeding if ok, recording in dues the due
+// times they are created for.
+type jobTask struct {
+	*ScheduledTask
+	dues []time.Time
+	runs int32
+}
+
+func newJobTask(sched Schedule, ok bool) *jobTask {
+	jt := &jobTask{}
+	jt.ScheduledTask = &ScheduledTask{
+		Task:     &Task{Name: "report"},
+		Schedule: sched,
+		NewRequest: func(task *Task, due time.Time) ContextRequest {
+			jt.dues = append(jt.dues, due)
+			return &jobRequest{id: JobRequestID(task.Name, due), ok: ok, runs: &jt.runs}
+		},
+	}
+	return jt
+}
+
+func newTestScheduler(loc *time.Location, now *time.Time) *Scheduler {
+	s := NewScheduler(NewInMemRequestKeeper(), loc)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestSchedulerNextRun(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC) // 11:00 in Sydney
+	s := newTestScheduler(sydney, &now)
+	sched, _ := ParseCron("0 9 * * *")
+	jt := newJobTask(sched, true)
+	var changes int
+	s.OnChange = func(task *Task) { changes++ }
+	if res := s.Add(jt.ScheduledTask); res != nil {
+		t.Fatal(res)
+	}
+	want := time.Date(2026, 10, 18, 9, 0, 0, 0, sydney)
+	if next := jt.Task.NextRun; next == nil || !next.Equal(want) || next.Location() != sydney {
+		t.Errorf("NextRun = %v, want %v", next, want)
+	}
+	if changes != 1 {
+		t.Errorf("OnChange called %d times, want 1", changes)
+	}
+
+	if res := s.Add(&ScheduledTask{Task: &Task{Name: "x"}}); res == nil {
+		t.Error("Add() without a schedule should fail")
+	}
+	if res := s.Add(&ScheduledTask{Task: &Task{Name: "x"}, Schedule: sched, NewRequest: jt.NewRequest, Missed: "later"}); res == nil {
+		t.Error("Add() with an unknown policy should fail")
+	}
+}
+
+func TestSchedulerTick(t *testing.T) {
+	for _, ok := range []bool{true, false} {
+		now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+		s := newTestScheduler(time.UTC, &now)
+		jt := newJobTask(Every(time.Hour), ok)
+		s.Add(jt.ScheduledTask)
+
+		if next := s.tick(context.Background(), now); !next.Equal(now.Add(time.Hour)) || jt.runs != 0 {
+			t.Fatalf("tick() before due = %v with %d runs", next, jt.runs)
+		}
+		now = now.Add(time.Hour + 10*time.Second)
+		next := s.tick(context.Background(), now)
+		s.jobs.Wait()
+
+		due := time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)
+		if !next.Equal(due.Add(time.Hour)) {
+			t.Errorf("tick() = %v, want %v", next, due.Add(time.Hour))
+		}
+		task := jt.Task
+		job := task.LastJob
+		if jt.runs != 1 || job == nil || job.ReqID != JobRequestID("report", due) || job.StartedBy != SchedulerStartedBy || !job.StartedAt.Equal(now) || job.FinishedAt == nil {
+			t.Fatalf("ok=%v: %d runs, LastJob = %+v", ok, jt.runs, job)
+		}
+		if ok && (job.Status != StatusOk || task.LastSucc != job || task.LastFail != nil || len(job.Errors) != 0) {
+			t.Errorf("succeeded job recorded as %+v", task)
+		}
+		if !ok && (job.Status != StatusFailed || task.LastFail != job || task.LastSucc != nil || len(job.Errors) != 1 || job.Errors[0].Code != 503) {
+			t.Errorf("failed job recorded as %+v", task)
+		}
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	tests := []struct {
+		policy     MissedRuns
+		maxCatchUp int
+		wantDues   []time.Duration // before now
+	}{
+		{policy: MissedRunsSkip},
+		{policy: MissedRunsOnce, wantDues: []time.Duration{30 * time.Minute}},
+		{policy: MissedRunsCatchUp, wantDues: []time.Duration{330 * time.Minute, 270 * time.Minute, 210 * time.Minute, 150 * time.Minute, 90 * time.Minute, 30 * time.Minute}},
+		{policy: MissedRunsCatchUp, maxCatchUp: 2, wantDues: []time.Duration{90 * time.Minute, 30 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			// restored after downtime with its runs of the last 5.5 hours due
+			now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+			s := newTestScheduler(time.UTC, &now)
+			jt := newJobTask(Every(time.Hour), true)
+			jt.Missed, jt.MaxCatchUp = tt.policy, tt.maxCatchUp
+			nextRun := now.Add(-330 * time.Minute)
+			jt.Task.NextRun = &nextRun
+			s.Add(jt.ScheduledTask)
+
+			next := s.tick(context.Background(), now)
+			s.jobs.Wait()
+			if want := now.Add(30 * time.Minute); !next.Equal(want) {
+				t.Errorf("tick() = %v, want %v", next, want)
+			}
+			if len(jt.dues) != len(tt.wantDues) || int(jt.runs) != len(tt.wantDues) {
+				t.Fatalf("ran for %v (%d runs), want %d", jt.dues, jt.runs, len(tt.wantDues))
+			}
+			for i, d := range tt.wantDues {
+				if !jt.dues[i].Equal(now.Add(-d)) {
+					t.Errorf("run %d due at %v, want %v", i, jt.dues[i], now.Add(-d))
+				}
+			}
+		})
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := NewScheduler(NewInMemRequestKeeper(), time.UTC)
+	var runs int32
+	s.Add(&ScheduledTask{
+		Task:     &Task{Name: "tick"},
+		Schedule: Every(10 * time.Millisecond),
+		NewRequest: func(task *Task, due time.Time) ContextRequest {
+			return &jobRequest{id: JobRequestID(task.Name, due), ok: true, runs: &runs}
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+	if runs := atomic.LoadInt32(&runs); runs < 3 {
+		t.Errorf("%d jobs ran in 200ms every 10ms, want at least 3", runs)
+	}
+}
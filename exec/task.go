@@ -0,0 +1,117 @@
+package exec
+
+import (
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// TaskStats summarises the finished Jobs in a Task's History.
+type TaskStats struct {
+	Jobs      int `json:"jobs" yaml:"jobs" bson:"jobs"`
+	Succeeded int `json:"succeeded" yaml:"succeeded" bson:"succeeded"`
+	Failed    int `json:"failed" yaml:"failed" bson:"failed"`
+	// SuccessRate is Succeeded / Jobs, 0 without jobs.
+	SuccessRate  float64       `json:"success_rate" yaml:"success_rate" bson:"success_rate"`
+	MeanDuration time.Duration `json:"mean_duration" yaml:"mean_duration" bson:"mean_duration"`
+	P95Duration  time.Duration `json:"p95_duration" yaml:"p95_duration" bson:"p95_duration"`
+	// ConsecutiveFailures counts the latest jobs that did not succeed.
+	ConsecutiveFailures int `json:"consecutive_failures" yaml:"consecutive_failures" bson:"consecutive_failures"`
+}
+
+// RecordJob records job as finished at finishedAt with status and the
+// results with a non-zero code as its Errors. It becomes the task's LastJob
+// and LastSucc or LastFail, and is added to History, of which only the
+// latest MaxJobCount are kept if it is positive.
+//
+// The LogFile of jobs rotated out of History is deleted, unless it is still
+// that of LastJob, LastSucc or LastFail; failures to delete are returned,
+// the job is recorded regardless.
+func (t *Task) RecordJob(job *Job, status Status, results []*util.Result, finishedAt time.Time) []*util.Result {
+	job.FinishedAt = &finishedAt
+	job.Status = status
+	job.Errors = nil
+	for _, r := range results {
+		if r != nil && r.Code != 0 {
+			job.Errors = append(job.Errors, r)
+		}
+	}
+
+	t.LastJob = job
+	if status == StatusOk {
+		t.LastSucc = job
+	} else {
+		t.LastFail = job
+	}
+	if len(t.History) == 0 || t.History[len(t.History)-1] != job {
+		t.History = append(t.History, job)
+	}
+	return t.rotate()
+}
+
+// rotate drops the oldest jobs from History beyond MaxJobCount, deleting
+// their log files.
+func (t *Task) rotate() []*util.Result {
+	if t.MaxJobCount <= 0 || len(t.History) <= t.MaxJobCount {
+		return nil
+	}
+	n := len(t.History) - t.MaxJobCount
+	rotated := t.History[:n]
+	t.History = append([]*Job(nil), t.History[n:]...)
+
+	kept := map[string]bool{}
+	for _, j := range append([]*Job{t.LastJob, t.LastSucc, t.LastFail}, t.History...) {
+		if j != nil && j.LogFile != "" {
+			kept[j.LogFile] = true
+		}
+	}
+	var errs []*util.Result
+	for _, j := range rotated {
+		if j == nil || j.LogFile == "" || kept[j.LogFile] {
+			continue
+		}
+		if err := os.Remove(j.LogFile); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, util.Error("RemoveLogFile", err))
+		}
+	}
+	return errs
+}
+
+// Stats summarises the finished jobs in History; durations are those of
+// jobs with a FinishedAt, and P95Duration is by the nearest rank.
+func (t *Task) Stats() TaskStats {
+	var s TaskStats
+	var durations []time.Duration
+	var total time.Duration
+	for _, j := range t.History {
+		if j == nil || !isDone(j.Status) {
+			continue
+		}
+		s.Jobs++
+		if j.Status == StatusOk {
+			s.Succeeded++
+			s.ConsecutiveFailures = 0
+		} else {
+			s.Failed++
+			s.ConsecutiveFailures++
+		}
+		if j.FinishedAt != nil {
+			d := j.FinishedAt.Sub(j.StartedAt)
+			durations = append(durations, d)
+			total += d
+		}
+	}
+	if s.Jobs > 0 {
+		s.SuccessRate = float64(s.Succeeded) / float64(s.Jobs)
+	}
+	if len(durations) > 0 {
+		s.MeanDuration = total / time.Duration(len(durations))
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		rank := int(math.Ceil(0.95 * float64(len(durations))))
+		s.P95Duration = durations[rank-1]
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soderasen-au/go-common/fx"
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestTaskRecordJobTrace(t *testing.T) {
+	exp, res := fx.Parse(`qty > 10`)
+	if res != nil {
+		t.Fatal(res)
+	}
+	_, trace := fx.EvalWithTrace(exp, fx.MapEnv{"qty": 3})
+	task := &Task{Name: "check"}
+	job := &Job{TaskName: "check"}
+	task.RecordJob(job, StatusFailed, []*util.Result{trace.Result()}, time.Now())
+	if len(job.Errors) != 1 || job.Errors[0].Result != trace {
+		t.Errorf("Errors = %v, want the trace", job.Errors)
+	}
+}
+
+func TestTaskRecordJob(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	task := &Task{Name: "report", MaxJobCount: 2}
+	var jobs []*Job
+	for i, status := range []Status{StatusOk, StatusFailed, StatusOk, StatusFailed} {
+		log := filepath.Join(dir, string(rune('a'+i))+".log")
+		if err := os.WriteFile(log, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		job := &Job{TaskName: "report", StartedAt: start.Add(time.Duration(i) * time.Hour), LogFile: log}
+		results := []*util.Result{{Code: 0, Msg: "info"}}
+		if status == StatusFailed {
+			results = append(results, util.MsgError("Run", "boom"))
+		}
+		if errs := task.RecordJob(job, status, results, job.StartedAt.Add(time.Minute)); errs != nil {
+			t.Fatal(errs)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if len(task.History) != 2 || task.History[0] != jobs[2] || task.History[1] != jobs[3] {
+		t.Errorf("History = %v, want the last 2 jobs", task.History)
+	}
+	if task.LastJob != jobs[3] || task.LastSucc != jobs[2] || task.LastFail != jobs[3] {
+		t.Errorf("LastJob, LastSucc, LastFail = %p, %p, %p", task.LastJob, task.LastSucc, task.LastFail)
+	}
+	if j := jobs[3]; j.Status != StatusFailed || j.FinishedAt == nil || !j.FinishedAt.Equal(j.StartedAt.Add(time.Minute)) || len(j.Errors) != 1 {
+		t.Errorf("recorded job = %+v", j)
+	}
+	for i, wantLog := range []bool{false, false, true, true} {
+		if _, err := os.Stat(jobs[i].LogFile); (err == nil) != wantLog {
+			t.Errorf("job %d log file exists = %v, want %v", i, err == nil, wantLog)
+		}
+	}
+
+	// a rotated job still the latest success keeps its log file
+	task = &Task{MaxJobCount: 1}
+	succ := &Job{LogFile: filepath.Join(dir, "c.log")}
+	task.RecordJob(succ, StatusOk, nil, start)
+	task.RecordJob(&Job{LogFile: filepath.Join(dir, "missing.log")}, StatusFailed, nil, start)
+	if errs := task.RecordJob(&Job{}, StatusFailed, nil, start); errs != nil {
+		t.Errorf("RecordJob() of a missing log file = %v", errs)
+	}
+	if _, err := os.Stat(succ.LogFile); err != nil {
+		t.Errorf("log file of LastSucc was deleted: %v", err)
+	}
+	if len(task.History) != 1 || task.LastSucc != succ {
+		t.Errorf("History = %v, LastSucc = %v", task.History, task.LastSucc)
+	}
+}
+
+func TestTaskStats(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	job := func(status Status, d time.Duration) *Job {
+		j := &Job{Status: status, StartedAt: start}
+		if status != StautsRunning {
+			finished := start.Add(d)
+			j.FinishedAt = &finished
+		}
+		return j
+	}
+	tests := []struct {
+		name    string
+		history []*Job
+		want    TaskStats
+	}{
+		{name: "empty"},
+		{
+			name: "mixed",
+			history: []*Job{
+				job(StatusOk, time.Second), job(StatusFailed, 2*time.Second), job(StatusOk, 3*time.Second),
+				job(StatusTimedOut, 10*time.Second), job(StatusCancelled, 4*time.Second), job(StautsRunning, 0),
+			},
+			want: TaskStats{Jobs: 5, Succeeded: 2, Failed: 3, SuccessRate: 0.4, MeanDuration: 4 * time.Second, P95Duration: 10 * time.Second, ConsecutiveFailures: 2},
+		},
+		{
+			name:    "recovered",
+			history: []*Job{job(StatusFailed, time.Second), job(StatusOk, 3*time.Second)},
+			want:    TaskStats{Jobs: 2, Succeeded: 1, Failed: 1, SuccessRate: 0.5, MeanDuration: 2 * time.Second, P95Duration: 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &Task{History: tt.history}
+			if got := task.Stats(); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var history []*Job
+	for i := 1; i <= 100; i++ {
+		history = append(history, job(StatusOk, time.Duration(i)*time.Millisecond))
+	}
+	if got := (&Task{History: history}).Stats().P95Duration; got != 95*time.Millisecond {
+		t.Errorf("P95Duration of 1..100ms = %v, want 95ms", got)
+	}
+}
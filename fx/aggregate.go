@@ -0,0 +1,246 @@
+package fx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func init() {
+	RegisterAggregate("count", func() Aggregator { return &countAgg{} })
+	RegisterAggregate("sum", func() Aggregator { return &sumAgg{ctx: "sum"} })
+	RegisterAggregate("avg", func() Aggregator { return &sumAgg{ctx: "avg", avg: true} })
+	RegisterAggregate("min", func() Aggregator { return &pickAgg{pick: Min} })
+	RegisterAggregate("max", func() Aggregator { return &pickAgg{pick: Max} })
+	RegisterAggregate("distinct", func() Aggregator { return &distinctAgg{seen: map[string]bool{}} })
+}
+
+// Aggregator folds the values of an aggregate's argument, one per row, into
+// a single result.
+type Aggregator interface {
+	// Add takes the value of the next row, which is never an error. It
+	// returns an error value to stop the aggregation, or nil.
+	Add(v *Value) *Value
+	Result() *Value
+}
+
+type AggregatorCreator func() Aggregator
+
+var aggregates = map[string]*funcDef{}
+
+// RegisterAggregate makes a one-argument call name(x) an aggregate: x is
+// evaluated for every row of the RowSet the call is evaluated in, and the
+// values are folded by a new Aggregator. Calls to name with another number
+// of arguments, or evaluated outside a RowSet, still go to the function
+// registry if it has name, so min(a, b) is the scalar Min while min(a) is
+// the aggregate over a RowSet and the scalar elsewhere.
+func RegisterAggregate(name string, create AggregatorCreator) {
+	aggregates[name] = &funcDef{name: name, arity: 1, agg: create}
+}
+
+// RowSet is an Env over a set of rows, such as a Group. Aggregates evaluate
+// their argument in each row's Env; other variables resolve through Lookup.
+type RowSet interface {
+	Env
+	Rows() []Env
+}
+
+// Rows is a RowSet without variables of its own.
+type Rows []Env
+
+func (r Rows) Lookup(name string) (*Value, bool) {
+	return nil, false
+}
+
+func (r Rows) Rows() []Env {
+	return r
+}
+
+// Group is a RowSet of the rows that share the same group-by values. Key
+// holds those values by the source text of their expression, so a rule on
+// a group can refer to `region` when grouping by region.
+type Group struct {
+	Key     map[string]*Value
+	Members []Env
+}
+
+func (g *Group) Lookup(name string) (*Value, bool) {
+	v, ok := g.Key[name]
+	return v, ok
+}
+
+func (g *Group) Rows() []Env {
+	return g.Members
+}
+
+// GroupBy splits rows into groups with equal values of keys, in the order
+// the groups are first seen. As in SQL, null keys form one group.
+func GroupBy(rows []Env, keys ...Expression) ([]*Group, *util.Result) {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = Format(k)
+	}
+	var groups []*Group
+	index := map[string]*Group{}
+	for r, row := range rows {
+		vals := make([]*Value, len(keys))
+		id := &strings.Builder{}
+		for i, k := range keys {
+			v := k.Eval(row)
+			if v.HasError() {
+				return nil, v.Error.With(fmt.Sprintf("GroupBy Row %d", r))
+			}
+			vals[i] = v
+			writeKey(id, v)
+			id.WriteString("\x00")
+		}
+		g, ok := index[id.String()]
+		if !ok {
+			g = &Group{Key: make(map[string]*Value, len(keys))}
+			for i, name := range names {
+				g.Key[name] = vals[i]
+			}
+			index[id.String()] = g
+			groups = append(groups, g)
+		}
+		g.Members = append(g.Members, row)
+	}
+	return groups, nil
+}
+
+// evalAggregate folds the argument of an aggregate call over the rows of
+// env, which must be a RowSet.
+func evalAggregate(e CallExp, def *funcDef, env Env) *Value {
+	rs, ok := env.(RowSet)
+	if !ok {
+		return Error(util.MsgError(e.Name, "aggregate needs a RowSet environment"))
+	}
+	agg := def.agg()
+	if a, ok := agg.(interface{ setDecimalOptions(o DecimalOptions) }); ok {
+		a.setDecimalOptions(decimalOptionsOf(env))
+	}
+	for i, row := range rs.Rows() {
+		v := e.Args[0].Eval(row)
+		if v.HasError() {
+			return Error(v.Error.With(fmt.Sprintf("%s Row %d Error", e.Name, i)))
+		}
+		if err := agg.Add(v); err != nil {
+			return Error(err.Error.With(fmt.Sprintf("%s Row %d", e.Name, i)))
+		}
+	}
+	return agg.Result()
+}
+
+// countAgg counts the rows whose value is neither null nor false, so that
+// count(status == "failed") counts failures and count(x) non-null values.
+type countAgg struct {
+	n int
+}
+
+func (a *countAgg) Add(v *Value) *Value {
+	if !v.IsNull() && !v.False() {
+		a.n++
+	}
+	return nil
+}
+
+func (a *countAgg) Result() *Value {
+	return Number(float64(a.n))
+}
+
+// sumAgg adds up numbers, skipping nulls; decimals stay exact, but for
+// the division of an average, done by the DecimalOptions of the RowSet. The
+// sum of no numbers is 0, their average is null.
+type sumAgg struct {
+	ctx     string
+	avg     bool
+	decimal DecimalOptions
+	total   *Value
+	n       int
+}
+
+func (a *sumAgg) setDecimalOptions(o DecimalOptions) {
+	a.decimal = o
+}
+
+func (a *sumAgg) Add(v *Value) *Value {
+	if v.IsNull() {
+		return nil
+	}
+	if _, err := numOperand(a.ctx, v); err != nil {
+		return err
+	}
+	if a.total == nil {
+		a.total = Number(0)
+	}
+	if a.total = a.total.Add(v); a.total.HasError() {
+		return a.total
+	}
+	a.n++
+	return nil
+}
+
+func (a *sumAgg) Result() *Value {
+	switch {
+	case a.n == 0 && a.avg:
+		return Nil()
+	case a.n == 0:
+		return Number(0)
+	case a.avg:
+		return a.total.div(Number(float64(a.n)), a.decimal)
+	}
+	return a.total
+}
+
+// pickAgg keeps the smallest or largest value; see Min and Max.
+type pickAgg struct {
+	pick func(vs ...*Value) *Value
+	best *Value
+}
+
+func (a *pickAgg) Add(v *Value) *Value {
+	if a.best == nil {
+		a.best = Nil()
+	}
+	best := a.pick(a.best, v)
+	if best.HasError() {
+		return best
+	}
+	a.best = best
+	return nil
+}
+
+func (a *pickAgg) Result() *Value {
+	if a.best == nil {
+		return Nil()
+	}
+	return a.best
+}
+
+// writeKey writes what tells v apart from other group keys and distinct
+// values; a dual goes by its number, as it compares.
+func writeKey(sb *strings.Builder, v *Value) {
+	if v.IsDual() {
+		v = Number(*v.Number)
+	}
+	formatValue(sb, v)
+}
+
+// distinctAgg counts the different non-null values; 1 and "1" differ.
+type distinctAgg struct {
+	seen map[string]bool
+}
+
+func (a *distinctAgg) Add(v *Value) *Value {
+	if !v.IsNull() {
+		sb := &strings.Builder{}
+		writeKey(sb, v)
+		a.seen[sb.String()] = true
+	}
+	return nil
+}
+
+func (a *distinctAgg) Result() *Value {
+	return Number(float64(len(a.seen)))
+}
@@ -0,0 +1,141 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+)
+
+func taskRuns() []Env {
+	return []Env{
+		MapEnv{"task": "backup", "status": "failed", "duration": 90},
+		MapEnv{"task": "backup", "status": "success", "duration": 30},
+		MapEnv{"task": "backup", "status": "failed", "duration": 120},
+		MapEnv{"task": "sync", "status": "failed", "duration": 10},
+		MapEnv{"task": "sync", "status": "success", "duration": nil},
+		MapEnv{"task": "backup", "status": "failed", "duration": 75},
+		MapEnv{"task": "backup", "status": "failed", "duration": 60},
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		rows    []Env
+		want    *Value
+		wantErr string
+	}{
+		{name: "count predicate", input: `count(status == "failed") > 3`, rows: taskRuns(), want: True()},
+		{name: "count rows", input: `count(true)`, rows: taskRuns(), want: Number(7)},
+		{name: "count skips nulls", input: `count(duration)`, rows: taskRuns(), want: Number(6)},
+		{name: "avg", input: `avg(duration) > 60`, rows: taskRuns(), want: True()},
+		{name: "avg skips nulls", input: `avg(duration) * 6`, rows: taskRuns(), want: Number(385)},
+		{name: "sum", input: `sum(duration)`, rows: taskRuns(), want: Number(385)},
+		{name: "min", input: `min(duration)`, rows: taskRuns(), want: Number(10)},
+		{name: "max text", input: `max(status)`, rows: taskRuns(), want: Text("success")},
+		{name: "distinct", input: `distinct(task)`, rows: taskRuns(), want: Number(2)},
+		{name: "inner error", input: `max(duration * 2)`, rows: taskRuns(), wantErr: "max Row 4 Error"},
+		{name: "inner expression", input: `max(coalesce(duration, 0) * 2) - min(duration)`, rows: taskRuns(), want: Number(230)},
+		{name: "scalar min", input: `min(3, 1, 2)`, want: Number(1)},
+		{name: "scalar max of one", input: `max(1)`, want: Number(1)},
+		{name: "scalar min of one", input: `min(2) + max(3)`, want: Number(5)},
+		{name: "empty count", input: `count(true)`, rows: []Env{}, want: Number(0)},
+		{name: "empty sum", input: `sum(duration)`, rows: []Env{}, want: Number(0)},
+		{name: "empty avg", input: `avg(duration)`, rows: []Env{}, want: Nil()},
+		{name: "empty max", input: `max(duration)`, rows: []Env{}, want: Nil()},
+		{name: "sum of text", input: `sum(status)`, rows: taskRuns(), wantErr: "operand is not a number"},
+		{name: "mixed max", input: `max(status)`, rows: []Env{MapEnv{"status": 1}, MapEnv{"status": "x"}}, wantErr: "operand must be in same type"},
+		{name: "row error", input: `count(missing)`, rows: taskRuns(), wantErr: "count Row 0 Error"},
+		{name: "not a row set", input: `count(true)`, wantErr: "aggregate needs a RowSet environment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var env Env = MapEnv{}
+			if tt.rows != nil {
+				env = Rows(tt.rows)
+			}
+			got := e.Eval(env)
+			if tt.wantErr != "" {
+				if !got.HasError() || !strings.Contains(got.Error.Error(), tt.wantErr) {
+					t.Fatalf("Eval() = %v, want error %q", got, tt.wantErr)
+				}
+				return
+			}
+			if !got.Identical(tt.want) {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateArity(t *testing.T) {
+	if _, err := Parse(`count(a, b)`); err == nil || !strings.Contains(err.Error(), "count expects 1 argument(s), got 2") {
+		t.Errorf("Parse() error = %v", err)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	groups, err := GroupBy(taskRuns(), NewVarExp("task"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("GroupBy() gave %d groups, want 2", len(groups))
+	}
+
+	e, _ := Parse(`task == "backup" && count(status == "failed") >= 3`)
+	want := []struct {
+		task    string
+		members int
+		alert   bool
+	}{
+		{task: "backup", members: 5, alert: true},
+		{task: "sync", members: 2, alert: false},
+	}
+	for i, g := range groups {
+		if !g.Key["task"].Identical(Text(want[i].task)) || len(g.Members) != want[i].members {
+			t.Errorf("group %d = %v with %d rows, want %s with %d", i, g.Key["task"], len(g.Members), want[i].task, want[i].members)
+		}
+		if got := e.Eval(g); !got.Identical(Bool(want[i].alert)) {
+			t.Errorf("group %s: Eval() = %v, want %v", want[i].task, got, want[i].alert)
+		}
+	}
+}
+
+func TestGroupByExpression(t *testing.T) {
+	rows := []Env{
+		MapEnv{"region": "EU", "amount": 10},
+		MapEnv{"region": "eu", "amount": 5},
+		MapEnv{"region": nil, "amount": 1},
+		MapEnv{"amount": 2},
+	}
+	key, _ := Parse(`lower(region)`)
+	if _, err := GroupBy(rows, key); err == nil || !strings.Contains(err.Error(), "GroupBy Row 2") {
+		t.Errorf("GroupBy() error = %v, want it to name row 2", err)
+	}
+
+	key, _ = Parse(`lower(coalesce(region, "none"))`)
+	groups, err := GroupBy(rows[:3], key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, _ := Parse(`sum(amount)`)
+	got := map[string]float64{}
+	for _, g := range groups {
+		got[*g.Key[`lower(coalesce(region, "none"))`].Text] = *sum.Eval(g).Number
+	}
+	if len(got) != 2 || got["eu"] != 15 || got["none"] != 1 {
+		t.Errorf("sums by region = %v", got)
+	}
+}
+
+func TestOptimizeKeepsAggregates(t *testing.T) {
+	e, _ := Parse(`count(true) > 1 + 1`)
+	if got := Format(Optimize(e)); got != `count(true) > 2` {
+		t.Errorf("Optimize() = %s", got)
+	}
+}
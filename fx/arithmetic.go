@@ -0,0 +1,190 @@
+package fx
+
+import "github.com/soderasen-au/go-common/util"
+
+//AddOp Operator = "+"
+//SubOp Operator = "-"
+//MulOp Operator = "*"
+//DivOp Operator = "/"
+//ModOp Operator = "%"
+//NegOp Operator = "-"
+
+func init() {
+	RegisterNewBinOpExpCreator(AddOp, NewAddExp)
+	RegisterNewBinOpExpCreator(SubOp, NewSubExp)
+	RegisterNewBinOpExpCreator(MulOp, NewMulExp)
+	RegisterNewBinOpExpCreator(DivOp, NewDivExp)
+	RegisterNewBinOpExpCreator(ModOp, NewModExp)
+	RegisterNewUnaryOpExpCreator(NegOp, NewNegExp)
+}
+
+type AddExp struct {
+	BinOpExp
+}
+
+func (e AddExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e AddExp) Eval(env Env) *Value {
+	return e.Lh.Eval(env).Add(e.Rh.Eval(env))
+}
+
+func NewAddExp(lh, rh Expression) Expression {
+	return &AddExp{
+		BinOpExp{
+			Op: AddOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type SubExp struct {
+	BinOpExp
+}
+
+func (e SubExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e SubExp) Eval(env Env) *Value {
+	return e.Lh.Eval(env).Sub(e.Rh.Eval(env))
+}
+
+func NewSubExp(lh, rh Expression) Expression {
+	return &SubExp{
+		BinOpExp{
+			Op: SubOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type MulExp struct {
+	BinOpExp
+}
+
+func (e MulExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e MulExp) Eval(env Env) *Value {
+	return e.Lh.Eval(env).Mul(e.Rh.Eval(env))
+}
+
+func NewMulExp(lh, rh Expression) Expression {
+	return &MulExp{
+		BinOpExp{
+			Op: MulOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type DivExp struct {
+	BinOpExp
+}
+
+func (e DivExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e DivExp) Eval(env Env) *Value {
+	return e.Lh.Eval(env).div(e.Rh.Eval(env), decimalOptionsOf(env))
+}
+
+func NewDivExp(lh, rh Expression) Expression {
+	return &DivExp{
+		BinOpExp{
+			Op: DivOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type ModExp struct {
+	BinOpExp
+}
+
+func (e ModExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e ModExp) Eval(env Env) *Value {
+	return e.Lh.Eval(env).Mod(e.Rh.Eval(env))
+}
+
+func NewModExp(lh, rh Expression) Expression {
+	return &ModExp{
+		BinOpExp{
+			Op: ModOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type NegExp struct {
+	UnaryOpExp
+}
+
+func (e NegExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e NegExp) Eval(env Env) *Value {
+	return e.X.Eval(env).Neg()
+}
+
+func NewNegExp(x Expression) Expression {
+	return &NegExp{
+		UnaryOpExp{
+			Op: NegOp,
+			X:  x,
+		},
+	}
+}
+
+// Min returns the smallest of vs as ordered by Value.Less. Nulls are
+// ignored; the result is null if every value is.
+func Min(vs ...*Value) *Value {
+	return pick("Min", vs, func(lh, rh *Value) *Value { return lh.Less(rh) })
+}
+
+// Max returns the largest of vs as ordered by Value.Less. Nulls are
+// ignored; the result is null if every value is.
+func Max(vs ...*Value) *Value {
+	return pick("Max", vs, func(lh, rh *Value) *Value { return rh.Less(lh) })
+}
+
+// pick returns the non-null value that wins every comparison by better.
+func pick(ctx string, vs []*Value, better func(lh, rh *Value) *Value) *Value {
+	if len(vs) == 0 {
+		return Error(util.MsgError(ctx, "no operands"))
+	}
+	best := Nil()
+	for _, v := range vs {
+		if v.HasError() {
+			return Error(v.Error.With(ctx))
+		}
+		if v.IsNull() {
+			continue
+		}
+		if best.IsNull() {
+			best = v
+			continue
+		}
+		b := better(v, best)
+		if b.HasError() {
+			return Error(b.Error.With(ctx))
+		}
+		if b.True() {
+			best = v
+		}
+	}
+	return best
+}
@@ -0,0 +1,126 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestArithmeticExp_Calc(t *testing.T) {
+	tests := []struct {
+		name    string
+		exp     Expression
+		want    *Value
+		wantErr bool
+	}{
+		{name: "1 + 2", exp: NewAddExp(Number(1), Number(2)), want: Number(3)},
+		{name: "text + text", exp: NewAddExp(Text("ab"), Text("cd")), want: Text("abcd")},
+		{name: "text + number", exp: NewAddExp(Text("ab"), Number(1)), wantErr: true},
+		{name: "number + text", exp: NewAddExp(Number(1), Text("ab")), wantErr: true},
+		{name: "error + number", exp: NewAddExp(Error(util.MsgError("test", "error")), Number(1)), wantErr: true},
+		{name: "5 - 7", exp: NewSubExp(Number(5), Number(7)), want: Number(-2)},
+		{name: "text - text", exp: NewSubExp(Text("a"), Text("b")), wantErr: true},
+		{name: "3 * 4", exp: NewMulExp(Number(3), Number(4)), want: Number(12)},
+		{name: "number * error", exp: NewMulExp(Number(3), Error(util.MsgError("test", "error"))), wantErr: true},
+		{name: "7 / 2", exp: NewDivExp(Number(7), Number(2)), want: Number(3.5)},
+		{name: "7 / 0", exp: NewDivExp(Number(7), Number(0)), wantErr: true},
+		{name: "7 % 3", exp: NewModExp(Number(7), Number(3)), want: Number(1)},
+		{name: "-7 % 3", exp: NewModExp(Number(-7), Number(3)), want: Number(-1)},
+		{name: "7 % 0", exp: NewModExp(Number(7), Number(0)), wantErr: true},
+		{name: "-(5)", exp: NewNegExp(Number(5)), want: Number(-5)},
+		{name: "-(text)", exp: NewNegExp(Text("5")), wantErr: true},
+		{name: "nested", exp: NewMulExp(NewAddExp(Number(1), Number(2)), NewNegExp(Number(3))), want: Number(-9)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.exp.Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Calc() = %v, want %v", util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestValue_NumericFunctions(t *testing.T) {
+	tests := []struct {
+		name    string
+		got     *Value
+		want    *Value
+		wantErr bool
+	}{
+		{name: "abs negative", got: Number(-2.5).Abs(), want: Number(2.5)},
+		{name: "abs positive", got: Number(2.5).Abs(), want: Number(2.5)},
+		{name: "abs text", got: Text("x").Abs(), wantErr: true},
+		{name: "round half up", got: Number(2.5).Round(0), want: Number(3)},
+		{name: "round half away from zero", got: Number(-2.5).Round(0), want: Number(-3)},
+		{name: "round places", got: Number(3.14159).Round(2), want: Number(3.14)},
+		{name: "round nil", got: Nil().Round(0), wantErr: true},
+		{name: "floor", got: Number(2.7).Floor(), want: Number(2)},
+		{name: "floor negative", got: Number(-2.1).Floor(), want: Number(-3)},
+		{name: "floor error", got: Error(util.MsgError("test", "error")).Floor(), wantErr: true},
+		{name: "ceil", got: Number(2.1).Ceil(), want: Number(3)},
+		{name: "ceil negative", got: Number(-2.7).Ceil(), want: Number(-2)},
+		{name: "min numbers", got: Min(Number(3), Number(1), Number(2)), want: Number(1)},
+		{name: "max numbers", got: Max(Number(3), Number(1), Number(2)), want: Number(3)},
+		{name: "min texts", got: Min(Text("b"), Text("a")), want: Text("a")},
+		{name: "max single", got: Max(Number(7)), want: Number(7)},
+		{name: "min empty", got: Min(), wantErr: true},
+		{name: "max mixed", got: Max(Number(1), Text("a")), wantErr: true},
+		{name: "min error", got: Min(Error(util.MsgError("test", "error")), Number(1)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.HasError() != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", tt.got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.got.Identical(tt.want) {
+				t.Errorf("got %v, want %v", util.JsonStr(tt.got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestParse_Arithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		env     MapEnv
+		want    *Value
+		wantErr bool
+	}{
+		{name: "precedence", input: "1 + 2 * 3", want: Number(7)},
+		{name: "parentheses", input: "(1 + 2) * 3", want: Number(9)},
+		{name: "left associative sub", input: "10 - 4 - 3", want: Number(3)},
+		{name: "left associative div", input: "24 / 4 / 2", want: Number(3)},
+		{name: "binary minus without spaces", input: "5-3", want: Number(2)},
+		{name: "minus negative literal", input: "5 - -3", want: Number(8)},
+		{name: "unary minus", input: "-(2 + 3)", want: Number(-5)},
+		{name: "unary minus variable", input: "-x * 2", env: MapEnv{"x": 4}, want: Number(-8)},
+		{name: "mod", input: "10 % 4 == 2", want: True()},
+		{name: "text concat", input: `"foo" + "bar" == "foobar"`, want: True()},
+		{name: "threshold", input: "price * qty > 1000", env: MapEnv{"price": 250, "qty": 5}, want: True()},
+		{name: "arithmetic binds tighter than logic", input: "1 + 1 == 2 && 2 * 2 == 4", want: True()},
+		{name: "divide by zero", input: "1 / 0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Parse(%q).Eval() error = %v, wantErr %v", tt.input, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
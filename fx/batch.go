@@ -0,0 +1,839 @@
+package fx
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Column holds one typed value per row. Only the slice matching Type is set;
+// Nulls, when not nil, marks the null rows. A NullType column has no slice
+// and every row is null. A NumberType column may also set Decimals: a row
+// with a decimal holds it exactly, and Numbers its float approximation.
+type Column struct {
+	Type     Type
+	Numbers  []float64
+	Decimals []*Decimal
+	Texts    []string
+	Bools    []bool
+	Times    []time.Time
+	Nulls    []bool
+	n        int // rows of a NullType column
+}
+
+func NumberColumn(vs []float64) *Column {
+	return &Column{Type: NumberType, Numbers: vs}
+}
+
+// DecimalColumn returns a NumberType column of the decimals vs; nil ones
+// are null.
+func DecimalColumn(vs []*Decimal) *Column {
+	c := &Column{Type: NumberType, Numbers: make([]float64, len(vs)), Decimals: vs}
+	for i, d := range vs {
+		if d == nil {
+			c.setNull(i)
+		} else {
+			c.Numbers[i] = d.Float64()
+		}
+	}
+	return c
+}
+
+func TextColumn(vs []string) *Column {
+	return &Column{Type: TextType, Texts: vs}
+}
+
+func BoolColumn(vs []bool) *Column {
+	return &Column{Type: BoolType, Bools: vs}
+}
+
+func TimeColumn(vs []time.Time) *Column {
+	return &Column{Type: TimeType, Times: vs}
+}
+
+func NullColumn(n int) *Column {
+	return &Column{Type: NullType, n: n}
+}
+
+// WithNulls marks the rows where nulls[i] is true as null and returns c.
+func (c *Column) WithNulls(nulls []bool) *Column {
+	c.Nulls = nulls
+	return c
+}
+
+func (c *Column) Len() int {
+	switch c.Type {
+	case NumberType:
+		return len(c.Numbers)
+	case TextType:
+		return len(c.Texts)
+	case BoolType:
+		return len(c.Bools)
+	case TimeType:
+		return len(c.Times)
+	}
+	return c.n
+}
+
+func (c *Column) IsNull(i int) bool {
+	return c.Type == NullType || (c.Nulls != nil && c.Nulls[i])
+}
+
+// Value returns row i as a Value.
+func (c *Column) Value(i int) *Value {
+	if c.IsNull(i) {
+		return Nil()
+	}
+	switch c.Type {
+	case NumberType:
+		if d := c.decimal(i); d != nil {
+			return Dec(d)
+		}
+		return Number(c.Numbers[i])
+	case TextType:
+		return Text(c.Texts[i])
+	case BoolType:
+		return Bool(c.Bools[i])
+	case TimeType:
+		return Time(c.Times[i])
+	}
+	return Nil()
+}
+
+// decimal returns the decimal of row i, or nil if it holds none.
+func (c *Column) decimal(i int) *Decimal {
+	if c.Decimals == nil {
+		return nil
+	}
+	return c.Decimals[i]
+}
+
+func (c *Column) setNull(i int) {
+	if c.Nulls == nil {
+		c.Nulls = make([]bool, c.Len())
+	}
+	c.Nulls[i] = true
+}
+
+// Batch is a set of equally long, named columns: the variables of n rows.
+type Batch struct {
+	n    int
+	cols map[string]*Column
+}
+
+func NewBatch(n int) *Batch {
+	return &Batch{n: n, cols: map[string]*Column{}}
+}
+
+func (b *Batch) Len() int {
+	return b.n
+}
+
+// Add sets the column of variable name, which must have Len() rows.
+func (b *Batch) Add(name string, c *Column) *util.Result {
+	if c.Len() != b.n {
+		return util.MsgError("BatchAdd", fmt.Sprintf("column %s has %d rows, want %d", name, c.Len(), b.n))
+	}
+	b.cols[name] = c
+	return nil
+}
+
+func (b *Batch) Column(name string) (*Column, bool) {
+	c, ok := b.cols[name]
+	return c, ok
+}
+
+// batchRow resolves variables from one row of a batch.
+type batchRow struct {
+	b   *Batch
+	row int
+}
+
+func (r batchRow) Lookup(name string) (*Value, bool) {
+	c, ok := r.b.cols[name]
+	if !ok {
+		return nil, false
+	}
+	return c.Value(r.row), true
+}
+
+// EvalBatch evaluates e for every row of b at once and returns one result
+// per row. Rows give the same results as e.Eval on each row, but operators,
+// variables and literals work on whole columns without allocating a Value
+// per row; function calls and custom operators fall back to row by row
+// evaluation. && and || only evaluate their right operand for the rows
+// that need it, so `x != 0 && 10 / x > 1` does not fail on x == 0.
+// Comparisons follow CoerceStrict, as columns hold no dual values, and
+// decimals stay exact as they do in Eval.
+//
+// Instead of error values, the first row whose evaluation fails makes the
+// whole batch fail with that row in the error context.
+func EvalBatch(e Expression, b *Batch) (*Column, *util.Result) {
+	c, err := (&batchEval{b: b}).eval(e, nil)
+	if err != nil {
+		return nil, err.With("EvalBatch")
+	}
+	return c, nil
+}
+
+// Filter returns which rows of b e is true for; false and null rows are not
+// selected.
+func Filter(e Expression, b *Batch) ([]bool, *util.Result) {
+	c, err := EvalBatch(e, b)
+	if err != nil {
+		return nil, err
+	}
+	keep := make([]bool, b.n)
+	switch c.Type {
+	case BoolType:
+		for i := range keep {
+			keep[i] = c.Bools[i] && !c.IsNull(i)
+		}
+	case NullType:
+	default:
+		return nil, util.MsgError("Filter", "expression is not bool but "+string(c.Type))
+	}
+	return keep, nil
+}
+
+type batchEval struct {
+	b *Batch
+}
+
+// sel marks the rows to evaluate; nil selects every row. The results of
+// rows that are not selected are undefined.
+func selected(sel []bool, i int) bool {
+	return sel == nil || sel[i]
+}
+
+func rowError(row int, ctx, msg string) *util.Result {
+	return util.MsgError(ctx, msg).With(fmt.Sprintf("Row %d", row))
+}
+
+func (be *batchEval) newBools() *Column {
+	return BoolColumn(make([]bool, be.b.n))
+}
+
+func (be *batchEval) eval(e Expression, sel []bool) (*Column, *util.Result) {
+	switch x := e.(type) {
+	case Expr:
+		return be.eval(x.Expression, sel)
+	case *Value:
+		return be.constant(x)
+	case *VarExp:
+		c, ok := be.b.cols[x.Name]
+		if !ok {
+			return nil, util.MsgError("VarExp", "undefined variable: "+x.Name)
+		}
+		return c, nil
+	case BinaryExpression:
+		b := x.BinOp()
+		switch b.Op {
+		case AndOp, OrOp:
+			return be.logic(b, sel)
+		case LessOp, GreaterOp, LessEqOp, GreaterEqOp, EqualOp, NotEqOp:
+			l, r, err := be.operands(b, sel)
+			if err != nil {
+				return nil, err
+			}
+			return be.compare(b.Op, l, r, sel)
+		case AddOp, SubOp, MulOp, DivOp, ModOp:
+			l, r, err := be.operands(b, sel)
+			if err != nil {
+				return nil, err
+			}
+			return be.arith(b.Op, l, r, sel)
+		case IncludeOp, MatchOp, NotMatchOp:
+			l, r, err := be.operands(b, sel)
+			if err != nil {
+				return nil, err
+			}
+			return be.text(b.Op, l, r, sel)
+		case InOp, NotInOp:
+			return be.in(b, sel)
+		case BetweenOp:
+			return be.between(b, sel)
+		}
+	case UnaryExpression:
+		u := x.UnaryOp()
+		switch u.Op {
+		case NotOp:
+			c, err := be.eval(u.X, sel)
+			if err != nil {
+				return nil, err.With("Operand Error")
+			}
+			return be.not(c, sel)
+		case NegOp:
+			c, err := be.eval(u.X, sel)
+			if err != nil {
+				return nil, err.With("Operand Error")
+			}
+			return be.neg(c, sel)
+		case IsNullOp, IsNotNullOp:
+			c, err := be.eval(u.X, sel)
+			if err != nil {
+				return nil, err.With("Operand Error")
+			}
+			out := be.newBools()
+			for i := range out.Bools {
+				out.Bools[i] = c.IsNull(i) == (u.Op == IsNullOp)
+			}
+			return out, nil
+		}
+	}
+	return be.rowByRow(e, sel)
+}
+
+func (be *batchEval) operands(b BinOpExp, sel []bool) (*Column, *Column, *util.Result) {
+	l, err := be.eval(b.Lh, sel)
+	if err != nil {
+		return nil, nil, err.With("LH Error")
+	}
+	r, err := be.eval(b.Rh, sel)
+	if err != nil {
+		return nil, nil, err.With("RH Error")
+	}
+	return l, r, nil
+}
+
+// constant repeats a literal for every row.
+func (be *batchEval) constant(v *Value) (*Column, *util.Result) {
+	n := be.b.n
+	switch valueType(v) {
+	case NullType:
+		return NullColumn(n), nil
+	case BoolType:
+		vs := make([]bool, n)
+		if v.True() {
+			for i := range vs {
+				vs[i] = true
+			}
+		}
+		return BoolColumn(vs), nil
+	case NumberType:
+		vs := make([]float64, n)
+		for i, f := 0, util.MaybeNil(v.Number); i < n; i++ {
+			vs[i] = f
+		}
+		c := NumberColumn(vs)
+		if v.Decimal != nil {
+			c.Decimals = make([]*Decimal, n)
+			for i := range c.Decimals {
+				c.Decimals[i] = v.Decimal
+			}
+		}
+		return c, nil
+	case TextType:
+		vs := make([]string, n)
+		for i, s := 0, *v.Text; i < n; i++ {
+			vs[i] = s
+		}
+		return TextColumn(vs), nil
+	case TimeType:
+		vs := make([]time.Time, n)
+		for i, t := 0, *v.Time; i < n; i++ {
+			vs[i] = t
+		}
+		return TimeColumn(vs), nil
+	}
+	return nil, v.Error.With("Literal")
+}
+
+// rowByRow evaluates e on each selected row and collects the values into a
+// column, which must then hold one type.
+func (be *batchEval) rowByRow(e Expression, sel []bool) (*Column, *util.Result) {
+	n := be.b.n
+	vals := make([]*Value, n)
+	typ := NullType
+	for i := 0; i < n; i++ {
+		if !selected(sel, i) {
+			continue
+		}
+		v := e.Eval(batchRow{b: be.b, row: i})
+		if v.HasError() {
+			return nil, v.Error.With(fmt.Sprintf("Row %d", i))
+		}
+		vals[i] = v
+		t := valueType(v)
+		if t == NullType {
+			continue
+		}
+		if typ != NullType && t != typ {
+			return nil, rowError(i, "EvalBatch", fmt.Sprintf("mixed result types %s and %s", typ, t))
+		}
+		typ = t
+	}
+
+	c := &Column{Type: typ, n: n}
+	switch typ {
+	case NumberType:
+		c.Numbers = make([]float64, n)
+	case TextType:
+		c.Texts = make([]string, n)
+	case BoolType:
+		c.Bools = make([]bool, n)
+	case TimeType:
+		c.Times = make([]time.Time, n)
+	case NullType:
+		return c, nil
+	}
+	for i, v := range vals {
+		if v == nil || v.IsNull() {
+			c.setNull(i)
+			continue
+		}
+		switch typ {
+		case NumberType:
+			c.Numbers[i] = *v.Number
+			if v.Decimal != nil {
+				if c.Decimals == nil {
+					c.Decimals = make([]*Decimal, n)
+				}
+				c.Decimals[i] = v.Decimal
+			}
+		case TextType:
+			c.Texts[i] = *v.Text
+		case BoolType:
+			c.Bools[i] = v.True()
+		case TimeType:
+			c.Times[i] = *v.Time
+		}
+	}
+	return c, nil
+}
+
+// numbers returns the rows of a number or bool column as floats, with bools
+// as 1 and 0 like Value does.
+func numbers(c *Column) []float64 {
+	if c.Type == NumberType {
+		return c.Numbers
+	}
+	vs := make([]float64, len(c.Bools))
+	for i, b := range c.Bools {
+		if b {
+			vs[i] = 1
+		}
+	}
+	return vs
+}
+
+func isNumberColumn(c *Column) bool {
+	return c.Type == NumberType || c.Type == BoolType
+}
+
+// times returns the rows of c as times, parsing text like timeOperand does;
+// ok[i] is false where that fails.
+func times(c *Column, sel []bool) (ts []time.Time, ok []bool) {
+	ok = make([]bool, c.Len())
+	switch c.Type {
+	case TimeType:
+		for i := range ok {
+			ok[i] = true
+		}
+		return c.Times, ok
+	case TextType:
+		ts = make([]time.Time, len(c.Texts))
+		for i, s := range c.Texts {
+			if selected(sel, i) && !c.IsNull(i) {
+				t, err := ParseTime(s)
+				ts[i], ok[i] = t, err == nil
+			}
+		}
+		return ts, ok
+	}
+	return make([]time.Time, c.Len()), ok
+}
+
+// comparator returns a function ordering row i of l against row i of r,
+// with ok false when the two cannot be compared.
+func comparator(l, r *Column, sel []bool) func(i int) (c int, ok bool) {
+	switch {
+	case l.Type == TimeType || r.Type == TimeType:
+		lt, lok := times(l, sel)
+		rt, rok := times(r, sel)
+		return func(i int) (int, bool) {
+			if !lok[i] || !rok[i] {
+				return 0, false
+			}
+			return lt[i].Compare(rt[i]), true
+		}
+	case isNumberColumn(l) && isNumberColumn(r) && (l.Decimals != nil || r.Decimals != nil):
+		// like Value.Less, a decimal is compared to a number as a decimal
+		ln, rn := numbers(l), numbers(r)
+		return func(i int) (int, bool) {
+			a, aok := decimalAt(l, ln, i)
+			b, bok := decimalAt(r, rn, i)
+			if !aok || !bok {
+				return 0, false
+			}
+			return a.Cmp(b), true
+		}
+	case isNumberColumn(l) && isNumberColumn(r):
+		ln, rn := numbers(l), numbers(r)
+		return func(i int) (int, bool) {
+			switch {
+			case ln[i] < rn[i]:
+				return -1, true
+			case ln[i] > rn[i]:
+				return 1, true
+			}
+			return 0, true
+		}
+	case l.Type == TextType && r.Type == TextType:
+		return func(i int) (int, bool) {
+			return strings.Compare(l.Texts[i], r.Texts[i]), true
+		}
+	}
+	return func(i int) (int, bool) { return 0, false }
+}
+
+// decimalAt returns row i of the number or bool column c, whose floats are
+// fs, as a decimal.
+func decimalAt(c *Column, fs []float64, i int) (*Decimal, bool) {
+	if d := c.decimal(i); d != nil {
+		return d, true
+	}
+	d, err := DecimalFromFloat(fs[i])
+	return d, err == nil
+}
+
+func (be *batchEval) compare(op Operator, l, r *Column, sel []bool) (*Column, *util.Result) {
+	out := be.newBools()
+	cmp := comparator(l, r, sel)
+	// Value.Equal tells a bool from the number 1, Value.Less does not.
+	mixed := (l.Type == BoolType) != (r.Type == BoolType)
+	for i := range out.Bools {
+		if !selected(sel, i) {
+			continue
+		}
+		if l.IsNull(i) || r.IsNull(i) {
+			out.setNull(i)
+			continue
+		}
+		c, ok := cmp(i)
+		equal := ok && c == 0 && !mixed
+		switch op {
+		case EqualOp:
+			out.Bools[i] = equal
+			continue
+		case NotEqOp:
+			out.Bools[i] = !equal
+			continue
+		}
+		if !ok {
+			return nil, rowError(i, "ValueLess", "operand must be in same type")
+		}
+		switch op {
+		case LessOp:
+			out.Bools[i] = c < 0
+		case GreaterOp:
+			out.Bools[i] = c > 0
+		case LessEqOp:
+			out.Bools[i] = c < 0 || equal
+		case GreaterEqOp:
+			out.Bools[i] = c > 0 || equal
+		}
+	}
+	return out, nil
+}
+
+// logicColumn checks that c holds bools or nulls in the selected rows.
+func logicColumn(c *Column, sel []bool, side string) *util.Result {
+	if c.Type == BoolType || c.Type == NullType {
+		return nil
+	}
+	for i := 0; i < c.Len(); i++ {
+		if selected(sel, i) && !c.IsNull(i) {
+			return rowError(i, "LogicExpOperands", side+" is not bool")
+		}
+	}
+	return nil
+}
+
+// logic evaluates && and || with three-valued logic. The right operand is
+// only evaluated for the rows the left one does not decide.
+func (be *batchEval) logic(b BinOpExp, sel []bool) (*Column, *util.Result) {
+	l, err := be.eval(b.Lh, sel)
+	if err != nil {
+		return nil, err.With("LH Error")
+	}
+	if err := logicColumn(l, sel, "LH"); err != nil {
+		return nil, err
+	}
+	decides := b.Op == OrOp // true decides ||, false decides &&
+	rsel := make([]bool, be.b.n)
+	need := false
+	for i := range rsel {
+		rsel[i] = selected(sel, i) && (l.IsNull(i) || l.Bools[i] != decides)
+		need = need || rsel[i]
+	}
+	r := NullColumn(be.b.n)
+	if need {
+		if r, err = be.eval(b.Rh, rsel); err != nil {
+			return nil, err.With("RH Error")
+		}
+		if err := logicColumn(r, rsel, "RH"); err != nil {
+			return nil, err
+		}
+	}
+
+	out := be.newBools()
+	for i := range out.Bools {
+		switch {
+		case !selected(sel, i):
+		case !rsel[i]:
+			out.Bools[i] = decides
+		case !r.IsNull(i) && r.Bools[i] == decides:
+			out.Bools[i] = decides
+		case l.IsNull(i) || r.IsNull(i):
+			out.setNull(i)
+		default:
+			out.Bools[i] = !decides
+		}
+	}
+	return out, nil
+}
+
+func (be *batchEval) not(c *Column, sel []bool) (*Column, *util.Result) {
+	if err := logicColumn(c, sel, "Operand"); err != nil {
+		return nil, err
+	}
+	if c.Type == NullType {
+		return c, nil
+	}
+	out := be.newBools()
+	for i, b := range c.Bools {
+		out.Bools[i] = !b
+	}
+	out.Nulls = c.Nulls
+	return out, nil
+}
+
+func (be *batchEval) arith(op Operator, l, r *Column, sel []bool) (*Column, *util.Result) {
+	n := be.b.n
+	ctx := map[Operator]string{AddOp: "ValueAdd", SubOp: "ValueSub", MulOp: "ValueMul", DivOp: "ValueDiv", ModOp: "ValueMod"}[op]
+	if op == AddOp && l.Type == TextType && r.Type == TextType {
+		out := TextColumn(make([]string, n))
+		for i := range out.Texts {
+			if !selected(sel, i) {
+				continue
+			}
+			if l.IsNull(i) || r.IsNull(i) {
+				return nil, rowError(i, ctx, "operand Text can't be nil")
+			}
+			out.Texts[i] = l.Texts[i] + r.Texts[i]
+		}
+		return out, nil
+	}
+
+	if l.Decimals != nil || r.Decimals != nil {
+		return be.decimalArith(op, l, r, sel)
+	}
+
+	var ln, rn []float64
+	if isNumberColumn(l) {
+		ln = numbers(l)
+	}
+	if isNumberColumn(r) {
+		rn = numbers(r)
+	}
+	out := NumberColumn(make([]float64, n))
+	for i := range out.Numbers {
+		if !selected(sel, i) {
+			continue
+		}
+		if ln == nil || l.IsNull(i) {
+			return nil, rowError(i, ctx, "lh is not a number")
+		}
+		if rn == nil || r.IsNull(i) {
+			return nil, rowError(i, ctx, "rh is not a number")
+		}
+		a, b := ln[i], rn[i]
+		switch op {
+		case AddOp:
+			out.Numbers[i] = a + b
+		case SubOp:
+			out.Numbers[i] = a - b
+		case MulOp:
+			out.Numbers[i] = a * b
+		case DivOp, ModOp:
+			if b == 0 {
+				return nil, rowError(i, ctx, "division by zero")
+			}
+			if op == DivOp {
+				out.Numbers[i] = a / b
+			} else {
+				out.Numbers[i] = math.Mod(a, b)
+			}
+		}
+	}
+	return out, nil
+}
+
+// decimalArith computes arith on the values of each row, as decimal
+// arithmetic rounds by its own rules.
+func (be *batchEval) decimalArith(op Operator, l, r *Column, sel []bool) (*Column, *util.Result) {
+	calc := map[Operator]func(lh, rh *Value) *Value{
+		AddOp: (*Value).Add, SubOp: (*Value).Sub, MulOp: (*Value).Mul, DivOp: (*Value).Div, ModOp: (*Value).Mod,
+	}[op]
+	out := NumberColumn(make([]float64, be.b.n))
+	out.Decimals = make([]*Decimal, be.b.n)
+	for i := range out.Numbers {
+		if !selected(sel, i) {
+			continue
+		}
+		v := calc(l.Value(i), r.Value(i))
+		if v.HasError() {
+			return nil, v.Error.With(fmt.Sprintf("Row %d", i))
+		}
+		out.Numbers[i] = util.MaybeNil(v.Number)
+		out.Decimals[i] = v.Decimal
+	}
+	return out, nil
+}
+
+func (be *batchEval) neg(c *Column, sel []bool) (*Column, *util.Result) {
+	out := NumberColumn(make([]float64, be.b.n))
+	for i := range out.Numbers {
+		if !selected(sel, i) {
+			continue
+		}
+		if !isNumberColumn(c) || c.IsNull(i) {
+			return nil, rowError(i, "ValueNeg", "operand is not a number")
+		}
+	}
+	if isNumberColumn(c) {
+		for i, f := range numbers(c) {
+			out.Numbers[i] = -f
+		}
+		if c.Decimals != nil {
+			out.Decimals = make([]*Decimal, be.b.n)
+			for i, d := range c.Decimals {
+				if d != nil {
+					out.Decimals[i] = d.Neg()
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (be *batchEval) text(op Operator, l, r *Column, sel []bool) (*Column, *util.Result) {
+	ctx := "MatchExpOperands"
+	if op == IncludeOp {
+		ctx = "IncludeExpOperands"
+	}
+	out := be.newBools()
+	for i := range out.Bools {
+		if !selected(sel, i) {
+			continue
+		}
+		if l.Type != TextType || l.IsNull(i) {
+			return nil, rowError(i, ctx, "LV is not text")
+		}
+		if r.Type != TextType || r.IsNull(i) {
+			return nil, rowError(i, ctx, "RV is not text")
+		}
+		if op == IncludeOp {
+			out.Bools[i] = strings.Contains(l.Texts[i], r.Texts[i])
+			continue
+		}
+		re, err := compilePattern(r.Texts[i])
+		if err != nil {
+			return nil, err.With(fmt.Sprintf("Row %d", i))
+		}
+		out.Bools[i] = re.MatchString(l.Texts[i]) == (op == MatchOp)
+	}
+	return out, nil
+}
+
+// in follows inList: each item is only evaluated for the rows not matched
+// by an earlier item.
+func (be *batchEval) in(b BinOpExp, sel []bool) (*Column, *util.Result) {
+	l, err := be.eval(b.Lh, sel)
+	if err != nil {
+		return nil, err.With("LH Error")
+	}
+	out := be.newBools()
+	pending := make([]bool, be.b.n)
+	for i := range pending {
+		pending[i] = selected(sel, i) && !l.IsNull(i)
+		if selected(sel, i) && l.IsNull(i) {
+			out.setNull(i)
+		}
+	}
+	unknown := make([]bool, be.b.n)
+	for k, item := range listItems(b.Rh) {
+		r, err := be.eval(item, pending)
+		if err != nil {
+			return nil, err.With(fmt.Sprintf("Item %d Error", k))
+		}
+		eq, err := be.compare(EqualOp, l, r, pending)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range pending {
+			switch {
+			case !p:
+			case eq.IsNull(i):
+				unknown[i] = true
+			case eq.Bools[i]:
+				out.Bools[i] = true
+				pending[i] = false
+			}
+		}
+	}
+	for i, p := range pending {
+		if p && unknown[i] {
+			out.setNull(i)
+		}
+	}
+	if b.Op == NotInOp {
+		return be.not(out, sel)
+	}
+	return out, nil
+}
+
+func (be *batchEval) between(b BinOpExp, sel []bool) (*Column, *util.Result) {
+	bounds := listItems(b.Rh)
+	if len(bounds) != 2 {
+		return nil, util.MsgError("BetweenExpOperands", "RH must be a list of two bounds")
+	}
+	v, err := be.eval(b.Lh, sel)
+	if err != nil {
+		return nil, err.With("LH Error")
+	}
+	lo, err := be.eval(bounds[0], sel)
+	if err != nil {
+		return nil, err.With("Lower Bound Error")
+	}
+	hi, err := be.eval(bounds[1], sel)
+	if err != nil {
+		return nil, err.With("Upper Bound Error")
+	}
+	below, err := be.compare(LessOp, v, lo, sel)
+	if err != nil {
+		return nil, err
+	}
+	above, err := be.compare(LessOp, hi, v, sel)
+	if err != nil {
+		return nil, err
+	}
+	out := be.newBools()
+	for i := range out.Bools {
+		if !selected(sel, i) {
+			continue
+		}
+		switch {
+		case !below.IsNull(i) && below.Bools[i], !above.IsNull(i) && above.Bools[i]:
+		case below.IsNull(i) || above.IsNull(i):
+			out.setNull(i)
+		default:
+			out.Bools[i] = true
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,258 @@
+package fx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBatch(t testing.TB) *Batch {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	b := NewBatch(6)
+	cols := map[string]*Column{
+		"price":  NumberColumn([]float64{50, 150, 0, 99.5, 200, 10}).WithNulls([]bool{false, false, false, false, true, false}),
+		"qty":    NumberColumn([]float64{1, 0, 3, 2, 5, 0}),
+		"name":   TextColumn([]string{"Bob", "alice", "", "BOBBY", "eve", "x"}).WithNulls([]bool{false, false, false, false, false, true}),
+		"active": BoolColumn([]bool{true, false, true, false, true, false}).WithNulls([]bool{false, false, true, false, false, false}),
+		"at":     TimeColumn([]time.Time{day(1), day(5), day(10), day(15), day(20), day(25)}),
+		"since":  TextColumn([]string{"2024-03-01", "2024-03-10", "bad", "2024-03-15", "2024-03-01", "2024-03-30"}),
+		"none":   NullColumn(6),
+	}
+	for name, c := range cols {
+		if err := b.Add(name, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+func TestEvalBatch(t *testing.T) {
+	b := testBatch(t)
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{input: `price > 100`},
+		{input: `price <= 99.5 || qty >= 3`},
+		{input: `price == 50 && name != "Bob"`},
+		{input: `active == 1`},
+		{input: `active < 1`},
+		{input: `active && price > 0`},
+		{input: `!active || none`},
+		{input: `price is not null && qty != 0 && price / qty > 20`},
+		{input: `qty == 0 || 100 % qty == 0`},
+		{input: `price * 2 - qty + -qty`, wantErr: "lh is not a number"},
+		{input: `name + "!"`, wantErr: "operand Text can't be nil"},
+		{input: `price / qty`, wantErr: "division by zero"},
+		{input: `name ~= "ob"`, wantErr: "not text"},
+		{input: `name is not null && lower(name) =~ "^bob"`},
+		{input: `name is not null && name !~ "^[A-Z]"`},
+		{input: `name is null`},
+		{input: `qty in (0, 3, null)`},
+		{input: `name not in ("Bob", "eve")`},
+		{input: `price between 10 and qty * 50`},
+		{input: `at between "2024-03-05" and "2024-03-20"`},
+		{input: `at >= "2024-03-10"`},
+		{input: `at == since`},
+		{input: `at < since`, wantErr: "Row 2"},
+		{input: `name > 1`, wantErr: "operand must be in same type"},
+		{input: `coalesce(price, qty) * 2`},
+		{input: `len(text(qty)) + 1`},
+		{input: `abs(qty - 3) > 1`},
+		{input: `abs(price - 100) > 40`, wantErr: "Row 4"},
+		{input: `missing > 1`, wantErr: "undefined variable: missing"},
+		{input: `price > 1 && qty`, wantErr: "RH is not bool"},
+		{input: `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := EvalBatch(e, b)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBatch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBatch() error = %v", err)
+			}
+			for i := 0; i < b.Len(); i++ {
+				want := e.Eval(batchRow{b: b, row: i})
+				if !got.Value(i).Identical(want) {
+					t.Errorf("row %d = %v, per-row Eval = %v", i, got.Value(i), want)
+				}
+			}
+		})
+	}
+}
+
+// TestEqualUndefined checks that a typo'd variable fails equality like any
+// other comparison, row by row and in batch, instead of being unequal.
+func TestEvalBatch_Decimal(t *testing.T) {
+	b := testBatch(t)
+	amounts := make([]*Decimal, b.Len())
+	for i, s := range []string{"0.1", "0.2", "3", "1.10", "0.30", "2.5"} {
+		amounts[i] = decValue(s).Decimal
+	}
+	if err := b.Add("amount", DecimalColumn(amounts)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{input: `dec("0.1") + dec("0.2") == dec("0.3")`},
+		{input: `dec("0.1") + dec("0.2") != 0.3`},
+		{input: `amount + dec("0.2") == dec("0.3")`},
+		{input: `amount * qty`},
+		{input: `amount / 3`},
+		{input: `-amount`},
+		{input: `amount > dec("0.25")`},
+		{input: `amount <= 0.3`},
+		{input: `amount in (dec("0.1"), 0.3, 2.5)`},
+		{input: `amount between dec("0.2") and 1.1`},
+		{input: `dec("2.50") > price`},
+		{input: `amount + price`, wantErr: "Row 4"},
+		{input: `dec("1") / qty`, wantErr: "Row 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := EvalBatch(e, b)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EvalBatch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EvalBatch() error = %v", err)
+			}
+			for i := 0; i < b.Len(); i++ {
+				want := e.Eval(batchRow{b: b, row: i})
+				v := got.Value(i)
+				if !v.Identical(want) || (v.Decimal == nil) != (want.Decimal == nil) {
+					t.Errorf("row %d = %v, per-row Eval = %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEqualUndefined(t *testing.T) {
+	b := testBatch(t)
+	for _, input := range []string{`missing == 1`, `missing != 1`, `1 == missing`, `price != missing`, `missing <= 1`, `missing >= 1`} {
+		t.Run(input, func(t *testing.T) {
+			e, err := Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Eval(batchRow{b: b, row: 0}); !got.HasError() || !strings.Contains(got.Error.Error(), "undefined variable: missing") {
+				t.Errorf("Eval() = %v, want an undefined variable error", got)
+			}
+			if _, err := EvalBatch(e, b); err == nil || !strings.Contains(err.Error(), "undefined variable: missing") {
+				t.Errorf("EvalBatch() error = %v, want an undefined variable error", err)
+			}
+		})
+	}
+}
+
+func TestEvalBatchErrorRow(t *testing.T) {
+	e, _ := Parse(`price / qty > 1`)
+	_, err := EvalBatch(e, testBatch(t))
+	if err == nil || !strings.Contains(err.Error(), "Row 1") {
+		t.Errorf("EvalBatch() error = %v, want it to name row 1", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	b := testBatch(t)
+	e, _ := Parse(`price > 40 || active`)
+	got, err := Filter(e, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, true, false, true, true, false}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Filter() = %v, want %v", got, want)
+	}
+
+	e, _ = Parse(`price + 1`)
+	if _, err := Filter(e, b); err == nil {
+		t.Error("Filter() of a number expression should fail")
+	}
+}
+
+func TestBatchAdd(t *testing.T) {
+	b := NewBatch(2)
+	if err := b.Add("x", NumberColumn([]float64{1, 2, 3})); err == nil {
+		t.Error("Add() of a column with the wrong length should fail")
+	}
+	if err := b.Add("x", NullColumn(2)); err != nil {
+		t.Errorf("Add() error = %v", err)
+	}
+}
+
+const benchRows = 100_000
+
+// benchData builds the columns of a typical data-quality rule, along with the
+// same rows as maps for per-row evaluation.
+func benchData() (*Batch, []MapEnv) {
+	prices := make([]float64, benchRows)
+	qtys := make([]float64, benchRows)
+	regions := make([]string, benchRows)
+	rows := make([]MapEnv, benchRows)
+	for i := range rows {
+		prices[i] = float64(i % 500)
+		qtys[i] = float64(i % 7)
+		regions[i] = []string{"EU", "US", "APAC"}[i%3]
+		rows[i] = MapEnv{"price": prices[i], "qty": qtys[i], "region": regions[i]}
+	}
+	b := NewBatch(benchRows)
+	b.Add("price", NumberColumn(prices))
+	b.Add("qty", NumberColumn(qtys))
+	b.Add("region", TextColumn(regions))
+	return b, rows
+}
+
+var benchRules = []string{
+	`price > 100`,
+	`price * qty > 1000 && region in ("EU", "US")`,
+	`qty != 0 && price / qty between 10 and 50 || region == "APAC"`,
+}
+
+func BenchmarkEvalRows(b *testing.B) {
+	_, rows := benchData()
+	for _, rule := range benchRules {
+		e, _ := Parse(rule)
+		b.Run(rule, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, row := range rows {
+					e.Eval(row)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvalBatch(b *testing.B) {
+	batch, _ := benchData()
+	for _, rule := range benchRules {
+		e, _ := Parse(rule)
+		b.Run(rule, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := EvalBatch(e, batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
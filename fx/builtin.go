@@ -0,0 +1,163 @@
+package fx
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func init() {
+	// text
+	RegisterFunc("lower", 1, textFunc("lower", strings.ToLower))
+	RegisterFunc("upper", 1, textFunc("upper", strings.ToUpper))
+	RegisterFunc("trim", 1, textFunc("trim", strings.TrimSpace))
+	RegisterFunc("len", 1, fnLen)
+	RegisterFunc("startsWith", 2, textPredFunc("startsWith", strings.HasPrefix))
+	RegisterFunc("endsWith", 2, textPredFunc("endsWith", strings.HasSuffix))
+	RegisterFunc("replace", 3, fnReplace)
+	RegisterFunc("substr", VariadicArity, fnSubstr)
+
+	// conversions
+	RegisterFunc("num", 1, fnNum)
+	RegisterFunc("text", 1, fnText)
+	RegisterFunc("isNil", 1, fnIsNil)
+
+	// numeric
+	RegisterFunc("abs", 1, func(args []*Value) *Value { return args[0].Abs() })
+	RegisterFunc("floor", 1, func(args []*Value) *Value { return args[0].Floor() })
+	RegisterFunc("ceil", 1, func(args []*Value) *Value { return args[0].Ceil() })
+	registerEnvFunc("round", VariadicArity, fnRound)
+	RegisterFunc("min", VariadicArity, func(args []*Value) *Value { return Min(args...) })
+	RegisterFunc("max", VariadicArity, func(args []*Value) *Value { return Max(args...) })
+}
+
+func textFunc(name string, f func(string) string) Func {
+	return func(args []*Value) *Value {
+		s, err := textArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		return Text(f(s))
+	}
+}
+
+func textPredFunc(name string, f func(string, string) bool) Func {
+	return func(args []*Value) *Value {
+		s, err := textArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		p, err := textArg(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return Bool(f(s, p))
+	}
+}
+
+// len(s) counts characters, not bytes.
+func fnLen(args []*Value) *Value {
+	s, err := textArg("len", args, 0)
+	if err != nil {
+		return err
+	}
+	return Number(float64(utf8.RuneCountInString(s)))
+}
+
+// replace(s, old, new) replaces every occurrence of old.
+func fnReplace(args []*Value) *Value {
+	s, err := textArg("replace", args, 0)
+	if err != nil {
+		return err
+	}
+	old, err := textArg("replace", args, 1)
+	if err != nil {
+		return err
+	}
+	repl, err := textArg("replace", args, 2)
+	if err != nil {
+		return err
+	}
+	return Text(strings.ReplaceAll(s, old, repl))
+}
+
+// substr(s, start[, length]) takes characters from the 0-based start; both
+// bounds are clamped to the text.
+func fnSubstr(args []*Value) *Value {
+	if err := argCount("substr", args, 2, 3); err != nil {
+		return err
+	}
+	s, err := textArg("substr", args, 0)
+	if err != nil {
+		return err
+	}
+	start, err := intArg("substr", args, 1)
+	if err != nil {
+		return err
+	}
+	runes := []rune(s)
+	start = util.Max(0, util.Min(start, len(runes)))
+	end := len(runes)
+	if len(args) == 3 {
+		n, err := intArg("substr", args, 2)
+		if err != nil {
+			return err
+		}
+		end = util.Max(start, util.Min(start+n, len(runes)))
+	}
+	return Text(string(runes[start:end]))
+}
+
+// num(x) converts text to a number; numbers and nil are returned as is.
+func fnNum(args []*Value) *Value {
+	v := args[0]
+	if v.IsNil() || v.IsNumeric {
+		return v
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(util.MaybeNil(v.Text)), 64)
+	if err != nil {
+		return Error(util.Error("num", err))
+	}
+	return Number(f)
+}
+
+// text(x) converts a number to its shortest text form and a time to RFC 3339;
+// texts and nil are returned as is.
+func fnText(args []*Value) *Value {
+	v := args[0]
+	if v.IsTime() {
+		return Text(v.String())
+	}
+	if v.IsNil() || !v.IsNumeric {
+		return v
+	}
+	if v.IsBool() || v.IsDual() {
+		return Text(*v.Text)
+	}
+	if v.Decimal != nil {
+		return Text(v.Decimal.String())
+	}
+	return Text(strconv.FormatFloat(util.MaybeNil(v.Number), 'f', -1, 64))
+}
+
+func fnIsNil(args []*Value) *Value {
+	return Bool(args[0].IsNil())
+}
+
+// round(x[, places]) rounds half away from zero, or a decimal by the
+// DecimalOptions of env.
+func fnRound(env Env, args []*Value) *Value {
+	if err := argCount("round", args, 1, 2); err != nil {
+		return err
+	}
+	places := 0
+	if len(args) == 2 {
+		var err *Value
+		if places, err = intArg("round", args, 1); err != nil {
+			return err
+		}
+	}
+	return args[0].round(places, decimalOptionsOf(env))
+}
@@ -0,0 +1,77 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestBuiltinFuncs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		env     MapEnv
+		want    *Value
+		wantErr bool
+	}{
+		{name: "lower", input: `lower("AbC")`, want: Text("abc")},
+		{name: "upper", input: `upper("AbC")`, want: Text("ABC")},
+		{name: "upper number", input: `upper(1)`, wantErr: true},
+		{name: "trim", input: `trim("  a b  ")`, want: Text("a b")},
+		{name: "len", input: `len("héllo")`, want: Number(5)},
+		{name: "len empty", input: `len("")`, want: Number(0)},
+		{name: "startsWith", input: `startsWith("report.csv", "rep")`, want: True()},
+		{name: "startsWith false", input: `startsWith("report.csv", "csv")`, want: False()},
+		{name: "endsWith", input: `endsWith("report.csv", ".csv")`, want: True()},
+		{name: "replace", input: `replace("a-b-c", "-", "_")`, want: Text("a_b_c")},
+		{name: "substr from", input: `substr("hello", 1)`, want: Text("ello")},
+		{name: "substr length", input: `substr("hello", 1, 3)`, want: Text("ell")},
+		{name: "substr clamped", input: `substr("hello", 3, 10)`, want: Text("lo")},
+		{name: "substr past end", input: `substr("hello", 10)`, want: Text("")},
+		{name: "substr negative start", input: `substr("hello", -2, 2)`, want: Text("he")},
+		{name: "substr negative length", input: `substr("hello", 2, -1)`, want: Text("")},
+		{name: "substr fraction", input: `substr("hello", 1.5)`, wantErr: true},
+		{name: "substr arity", input: `substr("hello")`, wantErr: true},
+		{name: "num", input: `num(" 12.5 ")`, want: Number(12.5)},
+		{name: "num number", input: `num(3)`, want: Number(3)},
+		{name: "num invalid", input: `num("abc")`, wantErr: true},
+		{name: "num nil", input: `num(x)`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "text", input: `text(12.5)`, want: Text("12.5")},
+		{name: "text integer", input: `text(7)`, want: Text("7")},
+		{name: "text bool", input: `text(true)`, want: Text("true")},
+		{name: "text text", input: `text("a")`, want: Text("a")},
+		{name: "isNil", input: `isNil(x)`, env: MapEnv{"x": nil}, want: True()},
+		{name: "isNil value", input: `isNil(x)`, env: MapEnv{"x": ""}, want: False()},
+		{name: "abs", input: `abs(-3)`, want: Number(3)},
+		{name: "floor", input: `floor(2.9)`, want: Number(2)},
+		{name: "ceil", input: `ceil(2.1)`, want: Number(3)},
+		{name: "round", input: `round(2.5)`, want: Number(3)},
+		{name: "round places", input: `round(2.345, 2)`, want: Number(2.35)},
+		{name: "round arity", input: `round(1, 2, 3)`, wantErr: true},
+		{name: "min", input: `min(3, 1, 2)`, want: Number(1)},
+		{name: "max", input: `max(3, 1, 2)`, want: Number(3)},
+		{name: "max empty", input: `max()`, wantErr: true},
+		{
+			name:  "normalise before compare",
+			input: `lower(trim(region)) == "eu"`,
+			env:   MapEnv{"region": "  EU "},
+			want:  True(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Parse(%q).Eval() error = %v, wantErr %v", tt.input, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
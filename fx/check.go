@@ -0,0 +1,307 @@
+package fx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Type is the static type of an expression as inferred by Check.
+type Type string
+
+const (
+	AnyType    Type = "any" // not known until evaluation
+	NullType   Type = "null"
+	BoolType   Type = "bool"
+	NumberType Type = "number"
+	TextType   Type = "text"
+	TimeType   Type = "time"
+)
+
+// Schema gives the type of each variable. A variable missing from the schema
+// is undefined, unless a dotted prefix of its name is typed AnyType: with
+// "row": AnyType, `row.price` is AnyType too.
+type Schema map[string]Type
+
+// FuncType is the signature Check uses for calls to a function. When there
+// are more arguments than Params, the last param applies to the rest.
+type FuncType struct {
+	Params []Type
+	Result Type
+}
+
+var funcTypes = map[string]FuncType{}
+
+// RegisterFuncType declares the signature of a registered function. Calls to
+// functions without a signature are not type checked and yield AnyType.
+func RegisterFuncType(name string, t FuncType) {
+	funcTypes[name] = t
+}
+
+func init() {
+	textToText := FuncType{Params: []Type{TextType}, Result: TextType}
+	numToNum := FuncType{Params: []Type{NumberType}, Result: NumberType}
+	RegisterFuncType("lower", textToText)
+	RegisterFuncType("upper", textToText)
+	RegisterFuncType("trim", textToText)
+	RegisterFuncType("len", FuncType{Params: []Type{TextType}, Result: NumberType})
+	RegisterFuncType("startsWith", FuncType{Params: []Type{TextType, TextType}, Result: BoolType})
+	RegisterFuncType("endsWith", FuncType{Params: []Type{TextType, TextType}, Result: BoolType})
+	RegisterFuncType("replace", FuncType{Params: []Type{TextType, TextType, TextType}, Result: TextType})
+	RegisterFuncType("substr", FuncType{Params: []Type{TextType, NumberType, NumberType}, Result: TextType})
+	RegisterFuncType("num", FuncType{Params: []Type{AnyType}, Result: NumberType})
+	RegisterFuncType("text", FuncType{Params: []Type{AnyType}, Result: TextType})
+	RegisterFuncType("isNil", FuncType{Params: []Type{AnyType}, Result: BoolType})
+	RegisterFuncType("abs", numToNum)
+	RegisterFuncType("floor", numToNum)
+	RegisterFuncType("ceil", numToNum)
+	RegisterFuncType("round", FuncType{Params: []Type{NumberType, NumberType}, Result: NumberType})
+	RegisterFuncType("now", FuncType{Result: TimeType})
+	RegisterFuncType("today", FuncType{Result: TimeType})
+	RegisterFuncType("date", FuncType{Params: []Type{TimeType, TextType}, Result: TimeType})
+	RegisterFuncType("addDays", FuncType{Params: []Type{TimeType, NumberType}, Result: TimeType})
+	RegisterFuncType("dateDiff", FuncType{Params: []Type{TimeType, TimeType, TextType}, Result: NumberType})
+	RegisterFuncType("format", FuncType{Params: []Type{TimeType, TextType}, Result: TextType})
+	RegisterFuncType("count", FuncType{Params: []Type{AnyType}, Result: NumberType})
+	RegisterFuncType("sum", numToNum)
+	RegisterFuncType("avg", numToNum)
+	RegisterFuncType("distinct", FuncType{Params: []Type{AnyType}, Result: NumberType})
+}
+
+// Check infers the type of e from the variable types in schema and reports
+// every problem that would make evaluation fail or give a useless result:
+// mismatched operand types, comparisons with a null literal, undefined
+// variables, wrong function arguments and invalid regular expressions.
+// Issues are ordered by position; like parse errors, each carries its
+// Position in Result. A nil schema leaves every variable AnyType.
+func Check(e Expression, schema Schema) (Type, []*util.Result) {
+	c := &checker{schema: schema}
+	t := c.typeOf(e)
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		pi, pj := c.issues[i].Result.(Position), c.issues[j].Result.(Position)
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+	return t, c.issues
+}
+
+type checker struct {
+	schema Schema
+	issues []*util.Result
+}
+
+func (c *checker) report(pos Position, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	if pos != (Position{}) {
+		msg = pos.String() + ": " + msg
+	}
+	err := util.MsgError("Check", msg)
+	err.Result = pos
+	c.issues = append(c.issues, err)
+}
+
+// accepts reports whether a value of type got can be used where want is
+// expected. Bools are numbers in fx, and text is parsed where a time is
+// expected.
+func accepts(want, got Type) bool {
+	switch {
+	case want == AnyType || got == AnyType || want == got:
+		return true
+	case want == NumberType && got == BoolType:
+		return true
+	case want == BoolType && got == NullType:
+		return true
+	case want == TimeType && got == TextType:
+		return true
+	}
+	return false
+}
+
+// comparable reports whether Less and Equal can compare the two types.
+func comparable(lt, rt Type) bool {
+	return accepts(lt, rt) || accepts(rt, lt)
+}
+
+func valueType(v *Value) Type {
+	switch {
+	case v.HasError():
+		return AnyType
+	case v.IsNull():
+		return NullType
+	case v.IsBool():
+		return BoolType
+	case v.IsTime():
+		return TimeType
+	case v.IsNumeric:
+		return NumberType
+	}
+	return TextType
+}
+
+func (c *checker) typeOf(e Expression) Type {
+	switch x := e.(type) {
+	case nil:
+		c.report(Position{}, "nil expression")
+	case Expr:
+		return c.typeOf(x.Expression)
+	case *Value:
+		return valueType(x)
+	case *VarExp:
+		return c.varType(x)
+	case *CallExp:
+		return c.callType(x)
+	case *ListExp:
+		for _, item := range x.Items {
+			c.typeOf(item)
+		}
+		c.report(x.Pos, "a list is only allowed after in, not in and between")
+	case BinaryExpression:
+		return c.binOpType(x.BinOp())
+	case UnaryExpression:
+		return c.unaryOpType(x.UnaryOp())
+	}
+	return AnyType
+}
+
+func (c *checker) varType(e *VarExp) Type {
+	if c.schema == nil {
+		return AnyType
+	}
+	if t, ok := c.schema[e.Name]; ok {
+		return t
+	}
+	parts := strings.Split(e.Name, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		if c.schema[strings.Join(parts[:i], ".")] == AnyType {
+			return AnyType
+		}
+	}
+	c.report(e.Pos, "undefined variable: %s", e.Name)
+	return AnyType
+}
+
+func (c *checker) callType(e *CallExp) Type {
+	args := make([]Type, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = c.typeOf(a)
+	}
+	if _, err := lookupFunc(e.Name, len(e.Args)); err != nil {
+		c.report(e.Pos, "%s", err.Msg)
+		return AnyType
+	}
+	ft, ok := funcTypes[e.Name]
+	if !ok {
+		return AnyType
+	}
+	for i, t := range args {
+		if len(ft.Params) == 0 {
+			break
+		}
+		want := ft.Params[util.Min(i, len(ft.Params)-1)]
+		if !accepts(want, t) && t != NullType {
+			c.report(e.Pos, "argument %d of %s must be %s, got %s", i, e.Name, want, t)
+		}
+	}
+	return ft.Result
+}
+
+// operand reports an operand of op whose type is not accepted for want.
+func (c *checker) operand(pos Position, op Operator, side string, got, want Type) {
+	if !accepts(want, got) {
+		c.report(pos, "%s of %s must be %s, got %s", side, op, want, got)
+	}
+}
+
+// compare checks the operands of a comparison.
+func (c *checker) compare(pos Position, op Operator, lt, rt Type) {
+	if lt == NullType || rt == NullType {
+		c.report(pos, "%s null is always unknown; use is null", op)
+		return
+	}
+	if !comparable(lt, rt) {
+		c.report(pos, "cannot compare %s with %s using %s", lt, rt, op)
+	}
+}
+
+func (c *checker) binOpType(b BinOpExp) Type {
+	switch b.Op {
+	case InOp, NotInOp:
+		lt := c.typeOf(b.Lh)
+		for _, item := range listItems(b.Rh) {
+			// as in inList, a null item only makes the result unknown
+			if it := c.typeOf(item); it != NullType {
+				c.compare(b.Pos, b.Op, lt, it)
+			}
+		}
+		return BoolType
+	case BetweenOp:
+		lt := c.typeOf(b.Lh)
+		bounds := listItems(b.Rh)
+		if len(bounds) != 2 {
+			c.report(b.Pos, "between needs a list of two bounds")
+		}
+		for _, bound := range bounds {
+			c.compare(b.Pos, b.Op, lt, c.typeOf(bound))
+		}
+		return BoolType
+	}
+
+	lt, rt := c.typeOf(b.Lh), c.typeOf(b.Rh)
+	switch b.Op {
+	case LessOp, GreaterOp, LessEqOp, GreaterEqOp, EqualOp, NotEqOp:
+		c.compare(b.Pos, b.Op, lt, rt)
+		return BoolType
+	case IncludeOp, MatchOp, NotMatchOp:
+		c.operand(b.Pos, b.Op, "LH", lt, TextType)
+		c.operand(b.Pos, b.Op, "RH", rt, TextType)
+		if v, ok := b.Rh.(*Value); ok && b.Op != IncludeOp && rt == TextType {
+			if _, err := compilePattern(*v.Text); err != nil {
+				c.report(b.Pos, "invalid regular expression: %s", err.Msg)
+			}
+		}
+		return BoolType
+	case AddOp:
+		if lt == TextType || rt == TextType {
+			c.operand(b.Pos, b.Op, "LH", lt, TextType)
+			c.operand(b.Pos, b.Op, "RH", rt, TextType)
+			return TextType
+		}
+		c.operand(b.Pos, b.Op, "LH", lt, NumberType)
+		c.operand(b.Pos, b.Op, "RH", rt, NumberType)
+		if lt == AnyType && rt == AnyType {
+			return AnyType
+		}
+		return NumberType
+	case SubOp, MulOp, DivOp, ModOp:
+		c.operand(b.Pos, b.Op, "LH", lt, NumberType)
+		c.operand(b.Pos, b.Op, "RH", rt, NumberType)
+		if v, ok := b.Rh.(*Value); ok && (b.Op == DivOp || b.Op == ModOp) && v.IsNumeric && util.MaybeNil(v.Number) == 0 {
+			c.report(b.Pos, "division by zero")
+		}
+		return NumberType
+	case AndOp, OrOp:
+		c.operand(b.Pos, b.Op, "LH", lt, BoolType)
+		c.operand(b.Pos, b.Op, "RH", rt, BoolType)
+		return BoolType
+	}
+	return AnyType
+}
+
+func (c *checker) unaryOpType(u UnaryOpExp) Type {
+	t := c.typeOf(u.X)
+	switch u.Op {
+	case NotOp:
+		c.operand(u.Pos, u.Op, "operand", t, BoolType)
+		return BoolType
+	case NegOp:
+		c.operand(u.Pos, u.Op, "operand", t, NumberType)
+		return NumberType
+	case IsNullOp, IsNotNullOp:
+		return BoolType
+	}
+	return AnyType
+}
@@ -0,0 +1,109 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	schema := Schema{
+		"price":   NumberType,
+		"qty":     NumberType,
+		"name":    TextType,
+		"active":  BoolType,
+		"created": TimeType,
+		"row":     AnyType,
+	}
+	tests := []struct {
+		name     string
+		input    string
+		noSchema bool
+		want     Type
+		wantErrs []Position
+		wantMsg  string
+	}{
+		{name: "relation", input: `price >= 100`, want: BoolType},
+		{name: "arithmetic", input: `price * qty - 1`, want: NumberType},
+		{name: "text concat", input: `name + "!"`, want: TextType},
+		{name: "logic", input: `active && (price > 1 || name ~= "x")`, want: BoolType},
+		{name: "bool as number", input: `active + 1 > 0`, want: BoolType},
+		{name: "time with text", input: `created < "2024-01-01"`, want: BoolType},
+		{name: "time function", input: `dateDiff(now(), created) > 3`, want: BoolType},
+		{name: "any prefix", input: `row.price > 1 && row.name == 1`, want: BoolType},
+		{name: "nil schema", input: `whatever + 1`, noSchema: true, want: NumberType},
+		{name: "is null", input: `name is null`, want: BoolType},
+		{name: "in", input: `name in ("a", null)`, want: BoolType},
+		{name: "untyped function", input: `coalesce(price, 0)`, want: AnyType},
+		{name: "text vs number", input: `name < 5`, want: BoolType, wantErrs: []Position{{1, 6}}, wantMsg: "cannot compare text with number using <"},
+		{name: "equal never true", input: `price == "5"`, want: BoolType, wantErrs: []Position{{1, 7}}},
+		{name: "null literal", input: `price != null`, want: BoolType, wantErrs: []Position{{1, 7}}, wantMsg: "use is null"},
+		{name: "undefined variable", input: `pirce > 1`, want: BoolType, wantErrs: []Position{{1, 1}}, wantMsg: "undefined variable: pirce"},
+		{name: "arithmetic on text", input: `name * 2`, want: NumberType, wantErrs: []Position{{1, 6}}, wantMsg: "LH of * must be number, got text"},
+		{name: "mixed add", input: `price + name`, want: TextType, wantErrs: []Position{{1, 7}}},
+		{name: "neg text", input: `-name`, want: NumberType, wantErrs: []Position{{1, 1}}},
+		{name: "not number", input: `!price`, want: BoolType, wantErrs: []Position{{1, 1}}},
+		{name: "logic on number", input: `price && active`, want: BoolType, wantErrs: []Position{{1, 7}}},
+		{name: "include number", input: `price ~= "1"`, want: BoolType, wantErrs: []Position{{1, 7}}},
+		{name: "bad regex", input: `name =~ "a("`, want: BoolType, wantErrs: []Position{{1, 6}}, wantMsg: "invalid regular expression"},
+		{name: "division by zero", input: `price / 0`, want: NumberType, wantErrs: []Position{{1, 7}}},
+		{name: "function arg", input: `lower(price) == "a"`, want: BoolType, wantErrs: []Position{{1, 1}}, wantMsg: "argument 0 of lower must be text, got number"},
+		{name: "in item", input: `price in (1, "2")`, want: BoolType, wantErrs: []Position{{1, 7}}},
+		{name: "between bound", input: `created between "2024-01-01" and 5`, want: BoolType, wantErrs: []Position{{1, 9}}},
+		{name: "time vs number", input: `created > 5`, want: BoolType, wantErrs: []Position{{1, 9}}},
+		{
+			name:     "all issues at once",
+			input:    "name < 5 &&\n  pirce > 1 &&\n  -name == 1",
+			want:     BoolType,
+			wantErrs: []Position{{1, 6}, {2, 3}, {3, 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			s := schema
+			if tt.noSchema {
+				s = nil
+			}
+			got, issues := Check(exp, s)
+			if got != tt.want {
+				t.Errorf("Check(%q) type = %s, want %s", tt.input, got, tt.want)
+			}
+			if len(issues) != len(tt.wantErrs) {
+				t.Fatalf("Check(%q) issues = %v, want %d", tt.input, issues, len(tt.wantErrs))
+			}
+			for i, issue := range issues {
+				if pos := issue.Result.(Position); pos != tt.wantErrs[i] {
+					t.Errorf("Check(%q) issue %d at %v, want %v: %v", tt.input, i, pos, tt.wantErrs[i], issue)
+				}
+			}
+			if tt.wantMsg != "" && !strings.Contains(issues[0].Msg, tt.wantMsg) {
+				t.Errorf("Check(%q) issue = %q, want %q", tt.input, issues[0].Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestCheck_Built(t *testing.T) {
+	_, issues := Check(NewLessExp(NewVarExp("a"), NewListExp(Number(1))), Schema{"a": NumberType})
+	if len(issues) != 1 || issues[0].Result.(Position) != (Position{}) {
+		t.Fatalf("Check() issues = %v, want one without position", issues)
+	}
+	if strings.HasPrefix(issues[0].Msg, "line") {
+		t.Errorf("Check() issue = %q, want no position prefix", issues[0].Msg)
+	}
+
+	if _, issues := Check(nil, nil); len(issues) != 1 {
+		t.Errorf("Check(nil) issues = %v, want 1", issues)
+	}
+
+	RegisterFunc("plusOne", 1, func(args []*Value) *Value { return args[0].Add(Number(1)) })
+	RegisterFuncType("plusOne", FuncType{Params: []Type{NumberType}, Result: NumberType})
+	exp, _ := Parse(`plusOne("a")`)
+	if typ, issues := Check(Expr{exp}, nil); typ != NumberType || len(issues) != 1 {
+		t.Errorf("Check(plusOne) = %s, %v, want number and 1 issue", typ, issues)
+	}
+}
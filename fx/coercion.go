@@ -0,0 +1,124 @@
+package fx
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Coercion decides how comparisons treat operands of different kinds, such
+// as a number compared with text. Dual values, which hold both a number and
+// the text it was read from, are the common case; see Dual.
+//
+// Times, bools, nulls and errors are never coerced.
+type Coercion string
+
+const (
+	// CoerceStrict compares numbers with numbers and text with text: the
+	// number 7 is neither equal to nor comparable with the text "7". A dual
+	// value takes the kind of the other operand. Two duals are ordered by
+	// their numbers, so "9" < "10", but are only equal if their texts are
+	// too, so "007" and "7" differ. This is the default.
+	CoerceStrict Coercion = "strict"
+	// CoerceNumeric compares as numbers whenever both operands are numbers
+	// or numeric text, so "10" > "9" and "007" == 7; otherwise both are
+	// compared as text.
+	CoerceNumeric Coercion = "numeric"
+	// CoerceText compares the texts of both operands, so "007" != 7 but
+	// "10" < "9". Numbers are written without trailing zeros, duals use
+	// the text they were read from.
+	CoerceText Coercion = "text"
+)
+
+// WithCoercion returns env with every comparison evaluated in it, including
+// those in aggregates over its rows, coercing its operands by c.
+func WithCoercion(env Env, c Coercion) Env {
+	return withOptions(env, func(o *evalOptions) { o.coercion = c })
+}
+
+// coercionOf returns the policy env was given by WithCoercion.
+func coercionOf(env Env) Coercion {
+	if c, ok := env.(interface{ Coercion() Coercion }); ok {
+		return c.Coercion()
+	}
+	return CoerceStrict
+}
+
+// Less is Value.Less after coercing the operands.
+func (c Coercion) Less(lh, rh *Value) *Value {
+	l, r := c.coerce(lh, rh)
+	return l.Less(r)
+}
+
+// Equal is Value.Equal after coercing the operands.
+func (c Coercion) Equal(lh, rh *Value) *Value {
+	l, r := c.coerce(lh, rh)
+	return l.Equal(r)
+}
+
+func (c Coercion) coerce(lh, rh *Value) (*Value, *Value) {
+	for _, v := range []*Value{lh, rh} {
+		if v.HasError() || v.IsNull() || v.Time != nil || v.IsBool() {
+			return lh, rh
+		}
+	}
+	switch c {
+	case CoerceNumeric:
+		if l, ok := asNumber(lh); ok {
+			if r, ok := asNumber(rh); ok {
+				return l, r
+			}
+		}
+		return asText(lh), asText(rh)
+	case CoerceText:
+		return asText(lh), asText(rh)
+	}
+	switch {
+	case lh.IsDual() && rh.IsDual():
+		return lh, rh
+	case lh.IsDual() && rh.IsNumeric:
+		l, _ := asNumber(lh)
+		return l, rh
+	case lh.IsDual():
+		return asText(lh), rh
+	case rh.IsDual() && lh.IsNumeric:
+		r, _ := asNumber(rh)
+		return lh, r
+	case rh.IsDual():
+		return lh, asText(rh)
+	}
+	return lh, rh
+}
+
+// asNumber returns the number of v, parsing text; ok is false if v is text
+// that is not a finite number.
+func asNumber(v *Value) (*Value, bool) {
+	switch {
+	case v.Decimal != nil || (v.IsNumeric && v.Text == nil):
+		return v, true
+	case v.IsNumeric && v.Number != nil:
+		return Number(*v.Number), true
+	case v.Text != nil:
+		f, err := strconv.ParseFloat(strings.TrimSpace(*v.Text), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return v, false
+		}
+		return Number(f), true
+	}
+	return v, false
+}
+
+// asText returns v as text: its own text for text and dual values.
+func asText(v *Value) *Value {
+	switch {
+	case v.Text != nil && !v.IsNumeric:
+		return v
+	case v.Text != nil:
+		return Text(*v.Text)
+	case v.Decimal != nil:
+		return Text(v.Decimal.String())
+	case v.Number != nil:
+		return Text(strconv.FormatFloat(*v.Number, 'f', -1, 64))
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package fx
+
+import (
+	"testing"
+)
+
+// TestCoercion documents each policy on CSV-style data: code and qty are
+// read with Dual, so they keep both their text and their number.
+func TestCoercion(t *testing.T) {
+	row := MapEnv{
+		"code":  Dual("007"),
+		"qty":   Dual("10"),
+		"name":  Dual("abc"),
+		"n":     7,
+		"price": decValue("9.50"),
+		"nine":  Dual("9"),
+		"seven": Dual("7"),
+	}
+	tests := []struct {
+		input   string
+		strict  string
+		numeric string
+		text    string
+	}{
+		// a dual compared with a number uses its number, except as text
+		{input: `code == 7`, strict: "true", numeric: "true", text: "false"},
+		{input: `code == n`, strict: "true", numeric: "true", text: "false"},
+		// a dual compared with text uses its text, except as numbers
+		{input: `code == "007"`, strict: "true", numeric: "true", text: "true"},
+		{input: `code == "7"`, strict: "false", numeric: "true", text: "false"},
+		// two duals are ordered by number, unless as text, but equal only
+		// when read alike, unless numeric
+		{input: `code < qty`, strict: "true", numeric: "true", text: "true"},
+		{input: `qty < code`, strict: "false", numeric: "false", text: "false"},
+		{input: `qty > nine`, strict: "true", numeric: "true", text: "false"},
+		{input: `code == seven`, strict: "false", numeric: "true", text: "false"},
+		// plain numbers and text: only strict refuses to compare them
+		{input: `n < "10"`, strict: "error", numeric: "true", text: "false"},
+		{input: `n == "7.0"`, strict: "false", numeric: "true", text: "false"},
+		{input: `"10" > "9"`, strict: "false", numeric: "true", text: "false"},
+		{input: `n < "abc"`, strict: "error", numeric: "true", text: "true"},
+		{input: `name > 1`, strict: "error", numeric: "true", text: "true"},
+		// decimals keep their digits: 9.50 as text is "9.50"
+		{input: `price == "9.5"`, strict: "false", numeric: "true", text: "false"},
+		{input: `price == 9.5`, strict: "true", numeric: "true", text: "false"},
+		// in and between use the policy too
+		{input: `code in ("7", "8")`, strict: "false", numeric: "true", text: "false"},
+		{input: `qty between "9" and "11"`, strict: "false", numeric: "true", text: "false"},
+		// nulls and bools are never coerced
+		{input: `null == "x"`, strict: "null", numeric: "null", text: "null"},
+		{input: `true == "true"`, strict: "false", numeric: "false", text: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for c, want := range map[Coercion]string{CoerceStrict: tt.strict, CoerceNumeric: tt.numeric, CoerceText: tt.text} {
+				got := e.Eval(WithCoercion(row, c))
+				var s string
+				switch {
+				case got.HasError():
+					s = "error"
+				case got.IsNull():
+					s = "null"
+				default:
+					s = Format(got)
+				}
+				if s != want {
+					t.Errorf("%s: Eval() = %s, want %s", c, s, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCoercionDefault(t *testing.T) {
+	e, _ := Parse(`code == "7"`)
+	if got := e.Eval(MapEnv{"code": Dual("007")}); !got.False() {
+		t.Errorf("Eval() without a policy = %v, want strict false", got)
+	}
+	e, _ = Parse(`code < "7"`)
+	if got := e.Eval(WithCoercion(nil, CoerceNumeric)); !got.HasError() {
+		t.Errorf("Eval() with a nil env = %v, want an undefined variable", got)
+	}
+}
+
+func TestCoercionDefaultDuals(t *testing.T) {
+	if got := Dual("10").Less(Dual("9")); !got.False() {
+		t.Errorf("Dual(\"10\").Less(Dual(\"9\")) = %v, want false", got)
+	}
+	e, _ := Parse(`count < limit`)
+	if got := e.Eval(MapEnv{"count": Dual("10"), "limit": Dual("9")}); !got.False() {
+		t.Errorf("Eval() without a policy = %v, want false", got)
+	}
+}
+
+func TestCoercionAggregate(t *testing.T) {
+	rows := Rows{MapEnv{"qty": Dual("10")}, MapEnv{"qty": Dual("9")}, MapEnv{"qty": Dual("010")}}
+	e, _ := Parse(`count(qty == "10")`)
+	for c, want := range map[Coercion]float64{CoerceStrict: 1, CoerceNumeric: 2, CoerceText: 1} {
+		if got := e.Eval(WithCoercion(rows, c)); !got.Identical(Number(want)) {
+			t.Errorf("%s: Eval() = %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestDualValue(t *testing.T) {
+	v := Dual("007")
+	if !v.IsDual() || *v.Text != "007" || *v.Number != 7 || v.String() != "007" {
+		t.Errorf("Dual(\"007\") = %+v", v)
+	}
+	if Dual("abc").IsDual() || Number(7).IsDual() || True().IsDual() {
+		t.Error("only values read from numeric text are dual")
+	}
+	if got := Dual("007").Add(Number(1)); !got.Identical(Number(8)) {
+		t.Errorf("Dual(\"007\") + 1 = %v, want 8", got)
+	}
+	if !Dual("007").Identical(Dual("007")) || Dual("007").Identical(Dual("7")) {
+		t.Error("duals are identical when read from the same text")
+	}
+}
@@ -0,0 +1,182 @@
+package fx
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// TimeFormats are the ISO 8601 style patterns (see util.FromISO8601) tried,
+// in order, when text has to be read as a time. Fractional seconds are
+// accepted after the seconds field of any format.
+var TimeFormats = []string{
+	"YYYY-MM-DDTHH:mm:ssZ",
+	"YYYY-MM-DDTHH:mm:ss",
+	"YYYY-MM-DD HH:mm:ss",
+	"YYYY-MM-DD",
+}
+
+// TimeLocation is the location for times parsed without a zone offset and
+// for now() and today().
+var TimeLocation = time.Local
+
+// nowFunc is replaced by tests to pin the clock.
+var nowFunc = time.Now
+
+func init() {
+	RegisterVolatileFunc("now", 0, fnNow)
+	RegisterVolatileFunc("today", 0, fnToday)
+	RegisterFunc("date", VariadicArity, fnDate)
+	RegisterFunc("addDays", 2, fnAddDays)
+	RegisterFunc("dateDiff", VariadicArity, fnDateDiff)
+	RegisterFunc("format", 2, fnFormat)
+}
+
+// ParseTime reads s with the given ISO 8601 style formats, or TimeFormats if
+// none are given. Times without a zone offset are in TimeLocation.
+func ParseTime(s string, formats ...string) (time.Time, *util.Result) {
+	if len(formats) == 0 {
+		formats = TimeFormats
+	}
+	s = strings.TrimSpace(s)
+	for _, f := range formats {
+		if t, err := time.ParseInLocation(util.FromISO8601(f), s, TimeLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, util.MsgError("ParseTime", fmt.Sprintf("'%s' does not match any time format", s))
+}
+
+// timeOperands returns both operands as times; a text operand compared with a
+// time is parsed with TimeFormats.
+func timeOperands(ctx string, lh, rh *Value) (time.Time, time.Time, *Value) {
+	lt, err := timeOperand(ctx, lh)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	rt, err := timeOperand(ctx, rh)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return lt, rt, nil
+}
+
+func timeOperand(ctx string, v *Value) (time.Time, *Value) {
+	if v.Time != nil {
+		return *v.Time, nil
+	}
+	if !v.IsNumeric && v.Text != nil {
+		t, err := ParseTime(*v.Text)
+		if err != nil {
+			return time.Time{}, Error(err.With(ctx))
+		}
+		return t, nil
+	}
+	return time.Time{}, Error(util.MsgError(ctx, "operand must be in same type"))
+}
+
+func fnNow(args []*Value) *Value {
+	return Time(nowFunc().In(TimeLocation))
+}
+
+// today() is midnight of the current day in TimeLocation.
+func fnToday(args []*Value) *Value {
+	now := nowFunc().In(TimeLocation)
+	return Time(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, TimeLocation))
+}
+
+// date(s[, format]) parses text into a time.
+func fnDate(args []*Value) *Value {
+	if err := argCount("date", args, 1, 2); err != nil {
+		return err
+	}
+	if args[0].IsTime() {
+		return args[0]
+	}
+	s, err := textArg("date", args, 0)
+	if err != nil {
+		return err
+	}
+	var formats []string
+	if len(args) == 2 {
+		f, err := textArg("date", args, 1)
+		if err != nil {
+			return err
+		}
+		formats = []string{f}
+	}
+	t, perr := ParseTime(s, formats...)
+	if perr != nil {
+		return Error(perr)
+	}
+	return Time(t)
+}
+
+// addDays(t, n) moves t by n calendar days; n may be negative.
+func fnAddDays(args []*Value) *Value {
+	t, err := timeOperand("addDays", args[0])
+	if err != nil {
+		return err
+	}
+	n, err := intArg("addDays", args, 1)
+	if err != nil {
+		return err
+	}
+	return Time(t.AddDate(0, 0, n))
+}
+
+var dateDiffUnits = map[string]time.Duration{
+	"hours":   time.Hour,
+	"minutes": time.Minute,
+	"seconds": time.Second,
+}
+
+// dateDiff(a, b[, unit]) is a - b measured in unit: days (default), hours,
+// minutes or seconds. Days are the calendar days between the dates of a and
+// b in TimeLocation, so a daylight saving change doesn't shorten them;
+// partial hours, minutes and seconds are truncated toward zero.
+func fnDateDiff(args []*Value) *Value {
+	if err := argCount("dateDiff", args, 2, 3); err != nil {
+		return err
+	}
+	a, b, err := timeOperands("dateDiff", args[0], args[1])
+	if err != nil {
+		return err
+	}
+	unit := "days"
+	if len(args) == 3 {
+		if unit, err = textArg("dateDiff", args, 2); err != nil {
+			return err
+		}
+	}
+	if unit == "days" {
+		return Number(float64(civilDay(a) - civilDay(b)))
+	}
+	d, ok := dateDiffUnits[unit]
+	if !ok {
+		return Error(util.MsgError("dateDiff", "unknown unit: "+unit))
+	}
+	return Number(math.Trunc(float64(a.Sub(b)) / float64(d)))
+}
+
+// civilDay numbers the calendar day of t in TimeLocation.
+func civilDay(t time.Time) int64 {
+	y, m, d := t.In(TimeLocation).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+}
+
+// format(t, pattern) renders t with an ISO 8601 style pattern.
+func fnFormat(args []*Value) *Value {
+	t, err := timeOperand("format", args[0])
+	if err != nil {
+		return err
+	}
+	f, err := textArg("format", args, 1)
+	if err != nil {
+		return err
+	}
+	return Text(t.Format(util.FromISO8601(f)))
+}
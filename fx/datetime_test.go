@@ -0,0 +1,229 @@
+package fx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// pinClock fixes now() and TimeLocation for the duration of a test.
+func pinClock(t *testing.T, now time.Time) {
+	oldNow, oldLoc := nowFunc, TimeLocation
+	nowFunc = func() time.Time { return now }
+	TimeLocation = now.Location()
+	t.Cleanup(func() {
+		nowFunc, TimeLocation = oldNow, oldLoc
+	})
+}
+
+func TestParseTime(t *testing.T) {
+	pinClock(t, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		input   string
+		formats []string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "date", input: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "date time", input: "2024-03-01 08:15:00", want: time.Date(2024, 3, 1, 8, 15, 0, 0, time.UTC)},
+		{name: "iso local", input: "2024-03-01T08:15:00", want: time.Date(2024, 3, 1, 8, 15, 0, 0, time.UTC)},
+		{name: "iso zulu", input: "2024-03-01T08:15:00Z", want: time.Date(2024, 3, 1, 8, 15, 0, 0, time.UTC)},
+		{name: "iso offset", input: "2024-03-01T10:15:00+02:00", want: time.Date(2024, 3, 1, 8, 15, 0, 0, time.UTC)},
+		{name: "iso fraction", input: "2024-03-01T08:15:00.250Z", want: time.Date(2024, 3, 1, 8, 15, 0, 250000000, time.UTC)},
+		{name: "custom format", input: "01/03/2024", formats: []string{"DD/MM/YYYY"}, want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "invalid", input: "yesterday", wantErr: true},
+		{name: "custom format mismatch", input: "2024-03-01", formats: []string{"DD/MM/YYYY"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTime(tt.input, tt.formats...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValue_Time(t *testing.T) {
+	t1 := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("EET", 2*3600))
+	t3 := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		got     *Value
+		want    *Value
+		wantErr bool
+	}{
+		{name: "less", got: Time(t1).Less(Time(t3)), want: True()},
+		{name: "not less", got: Time(t3).Less(Time(t1)), want: False()},
+		{name: "equal across zones", got: Time(t1).Equal(Time(t2)), want: True()},
+		{name: "less across zones", got: Time(t2).Less(Time(t1)), want: False()},
+		{name: "not equal", got: Time(t1).Equal(Time(t3)), want: False()},
+		{name: "less than text", got: Time(t1).Less(Text("2024-03-02")), want: True()},
+		{name: "equal to text", got: Text("2024-03-01T08:00:00Z").Equal(Time(t1)), want: True()},
+		{name: "less than bad text", got: Time(t1).Less(Text("soon")), wantErr: true},
+		{name: "less than number", got: Time(t1).Less(Number(1)), wantErr: true},
+		{name: "equal to number", got: Time(t1).Equal(Number(1)), want: False()},
+		{name: "equal to nil", got: Time(t1).Equal(Nil()), want: Nil()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.HasError() != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", tt.got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.got.Identical(tt.want) {
+				t.Errorf("got %v, want %v", util.JsonStr(tt.got), util.JsonStr(tt.want))
+			}
+		})
+	}
+
+	t.Run("predicates", func(t *testing.T) {
+		v := Time(t1)
+		if !v.IsTime() || v.IsNil() || v.IsNumeric || v.IsBool() {
+			t.Errorf("Time() predicates wrong: %v", util.JsonStr(v))
+		}
+		if v.SetNum(1); v.IsTime() {
+			t.Error("SetNum() should clear Time")
+		}
+		if v.String() != "1.000000" {
+			t.Errorf("String() = %v", v.String())
+		}
+		if got := Time(t1).String(); got != "2024-03-01T08:00:00Z" {
+			t.Errorf("String() = %v, want RFC 3339", got)
+		}
+	})
+}
+
+func TestValue_TimeRoundTrip(t *testing.T) {
+	in := Time(time.Date(2024, 3, 1, 8, 15, 30, 123000000, time.FixedZone("", 2*3600)))
+
+	t.Run("json", func(t *testing.T) {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		out := &Value{}
+		if err := json.Unmarshal(buf, out); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if !out.IsTime() || !out.Identical(in) {
+			t.Errorf("json round trip = %s, want %s", util.JsonStr(out), util.JsonStr(in))
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		buf, err := yaml.Marshal(in)
+		if err != nil {
+			t.Fatalf("yaml.Marshal() error = %v", err)
+		}
+		out := &Value{}
+		if err := yaml.Unmarshal(buf, out); err != nil {
+			t.Fatalf("yaml.Unmarshal() error = %v", err)
+		}
+		if !out.IsTime() || !out.Identical(in) {
+			t.Errorf("yaml round trip = %s, want %s", util.JsonStr(out), util.JsonStr(in))
+		}
+	})
+}
+
+func TestTimeFuncs(t *testing.T) {
+	pinClock(t, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		input   string
+		env     MapEnv
+		want    *Value
+		wantErr bool
+	}{
+		{name: "now", input: `now()`, want: Time(time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC))},
+		{name: "today", input: `today()`, want: Time(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC))},
+		{name: "date", input: `date("2024-03-01")`, want: Time(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{name: "date format", input: `date("01.03.2024", "DD.MM.YYYY")`, want: Time(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{name: "date invalid", input: `date("soon")`, wantErr: true},
+		{name: "addDays", input: `addDays(today(), -7)`, want: Time(time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC))},
+		{name: "addDays text", input: `addDays("2024-02-28", 2)`, want: Time(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{name: "addDays fraction", input: `addDays(today(), 1.5)`, wantErr: true},
+		{name: "dateDiff days", input: `dateDiff(today(), "2024-03-01")`, want: Number(14)},
+		{name: "dateDiff negative", input: `dateDiff("2024-03-01", today())`, want: Number(-14)},
+		{name: "dateDiff hours", input: `dateDiff(now(), today(), "hours")`, want: Number(10)},
+		{name: "dateDiff minutes", input: `dateDiff(now(), today(), "minutes")`, want: Number(630)},
+		{name: "dateDiff bad unit", input: `dateDiff(now(), today(), "weeks")`, wantErr: true},
+		{name: "format", input: `format(now(), "YYYY/MM/DD HH:mm")`, want: Text("2024/03/15 10:30")},
+		{name: "format number", input: `format(1, "YYYY")`, wantErr: true},
+		{name: "text of time", input: `text(today())`, want: Text("2024-03-15T00:00:00Z")},
+		{
+			name:  "stale run",
+			input: `last_run < addDays(today(), -7)`,
+			env:   MapEnv{"last_run": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+			want:  True(),
+		},
+		{
+			name:  "recent run as text",
+			input: `last_run < addDays(today(), -7)`,
+			env:   MapEnv{"last_run": "2024-03-10T12:00:00Z"},
+			want:  False(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Parse(%q).Eval() error = %v, wantErr %v", tt.input, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestDateDiffDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	pinClock(t, time.Date(2026, 3, 30, 0, 30, 0, 0, berlin))
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		// 2026-03-29 has 23 hours in Berlin, 2026-10-25 has 25
+		{input: `dateDiff("2026-03-30", "2026-03-29")`, want: 1},
+		{input: `dateDiff("2026-03-30", "2026-03-29", "hours")`, want: 23},
+		{input: `dateDiff(now(), "2026-03-29 23:45:00", "days")`, want: 1},
+		{input: `dateDiff("2026-10-26", "2026-10-25")`, want: 1},
+		{input: `dateDiff("2026-10-25", "2026-10-26")`, want: -1},
+		{input: `dateDiff("2026-11-01", "2026-03-01")`, want: 245},
+		{input: `dateDiff("2026-03-29 23:00:00", "2026-03-29 01:00:00")`, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := exp.Eval(nil); !got.Identical(Number(tt.want)) {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func init() {
+	registerEnvFunc("dec", VariadicArity, fnDec)
+	RegisterFuncType("dec", FuncType{Params: []Type{AnyType, NumberType}, Result: NumberType})
+}
+
+// RoundingMode tells how decimal operations drop digits.
+type RoundingMode string
+
+const (
+	RoundHalfUp   RoundingMode = "half_up"   // half away from zero, like Value.Round on floats
+	RoundHalfEven RoundingMode = "half_even" // half to the even neighbour, banker's rounding
+	RoundDown     RoundingMode = "down"      // towards zero
+	RoundUp       RoundingMode = "up"        // away from zero
+	RoundFloor    RoundingMode = "floor"     // towards negative infinity
+	RoundCeiling  RoundingMode = "ceiling"   // towards positive infinity
+)
+
+// DecimalOptions are the settings of the decimal operations that are not
+// exact, chosen per evaluation with WithDecimalOptions.
+type DecimalOptions struct {
+	// DivisionScale is the number of fractional digits kept by decimal
+	// division, between 0 and MaxDecimalScale.
+	DivisionScale int
+	// Rounding is how decimal division, dec(x, scale) and round() drop
+	// digits.
+	Rounding RoundingMode
+}
+
+// defaultDecimalOptions apply to evaluations without WithDecimalOptions, to
+// Value.Div and Value.Round and to columnar evaluation.
+var defaultDecimalOptions = DecimalOptions{DivisionScale: 16, Rounding: RoundHalfUp}
+
+// WithDecimalOptions returns env with every decimal division, dec(x, scale)
+// and round() evaluated in it, including those in aggregates over its rows,
+// done by o instead of the default 16 digits rounded half up.
+func WithDecimalOptions(env Env, o DecimalOptions) Env {
+	return withOptions(env, func(opts *evalOptions) { opts.decimal = o })
+}
+
+// decimalOptionsOf returns the DecimalOptions env was given, or the
+// defaults.
+func decimalOptionsOf(env Env) DecimalOptions {
+	if e, ok := env.(interface{ DecimalOptions() DecimalOptions }); ok {
+		return e.DecimalOptions()
+	}
+	return defaultDecimalOptions
+}
+
+// MaxDecimalScale bounds the exponents of parsed decimals and the scales
+// asked of dec() and round(), so that an input such as "1e999999999" fails
+// instead of allocating its digits.
+const MaxDecimalScale = 1000
+
+// Decimal is an exact decimal number with a fixed number of fractional
+// digits, its scale: 1.50 has scale 2. Operations never modify their
+// operands. In JSON and YAML a Decimal is a string, so its digits survive
+// decoders that read numbers as floats.
+type Decimal struct {
+	coef  big.Int // value × 10^scale
+	scale int
+}
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// ParseDecimal reads a decimal number such as "-12.50" or "1.5e-3", keeping
+// every digit. The exponent must be within ±MaxDecimalScale.
+func ParseDecimal(s string) (*Decimal, *util.Result) {
+	s = strings.TrimSpace(s)
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return nil, util.MsgError("ParseDecimal", fmt.Sprintf("invalid exponent in '%s'", s))
+		}
+		if e > MaxDecimalScale || e < -MaxDecimalScale {
+			return nil, util.MsgError("ParseDecimal", fmt.Sprintf("exponent of '%s' is out of range", s))
+		}
+		mantissa, exp = s[:i], e
+	}
+	intPart, frac, _ := strings.Cut(mantissa, ".")
+	digits := intPart + frac
+	if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		digits = digits[1:]
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" || strings.ContainsAny(frac, "+-") {
+		return nil, util.MsgError("ParseDecimal", fmt.Sprintf("'%s' is not a decimal number", s))
+	}
+	d := &Decimal{scale: len(frac) - exp}
+	d.coef.SetString(intPart+frac, 10)
+	if d.scale < 0 {
+		d.coef.Mul(&d.coef, pow10(-d.scale))
+		d.scale = 0
+	}
+	return d, nil
+}
+
+// DecimalFromFloat converts f through its shortest decimal representation,
+// so 0.1 becomes exactly 0.1.
+func DecimalFromFloat(f float64) (*Decimal, *util.Result) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, util.MsgError("DecimalFromFloat", fmt.Sprintf("%v is not a decimal number", f))
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (d *Decimal) Scale() int {
+	return d.scale
+}
+
+func (d *Decimal) Sign() int {
+	return d.coef.Sign()
+}
+
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(&d.coef).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.coef.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the nearest float.
+func (d *Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// aligned returns the coefficients of a and b at their common scale.
+func aligned(a, b *Decimal) (*big.Int, *big.Int, int) {
+	ac, bc := new(big.Int).Set(&a.coef), new(big.Int).Set(&b.coef)
+	switch {
+	case a.scale < b.scale:
+		ac.Mul(ac, pow10(b.scale-a.scale))
+		return ac, bc, b.scale
+	case a.scale > b.scale:
+		bc.Mul(bc, pow10(a.scale-b.scale))
+	}
+	return ac, bc, a.scale
+}
+
+func (d *Decimal) Cmp(o *Decimal) int {
+	a, b, _ := aligned(d, o)
+	return a.Cmp(b)
+}
+
+func (d *Decimal) Add(o *Decimal) *Decimal {
+	a, b, scale := aligned(d, o)
+	r := &Decimal{scale: scale}
+	r.coef.Add(a, b)
+	return r
+}
+
+func (d *Decimal) Sub(o *Decimal) *Decimal {
+	a, b, scale := aligned(d, o)
+	r := &Decimal{scale: scale}
+	r.coef.Sub(a, b)
+	return r
+}
+
+func (d *Decimal) Mul(o *Decimal) *Decimal {
+	r := &Decimal{scale: d.scale + o.scale}
+	r.coef.Mul(&d.coef, &o.coef)
+	return r
+}
+
+// Quo divides d by o to scale fractional digits, rounding by mode. o must
+// not be zero.
+func (d *Decimal) Quo(o *Decimal, scale int, mode RoundingMode) *Decimal {
+	// d/o = d.coef×10^o.scale / (o.coef×10^d.scale), times 10^scale
+	num := new(big.Int).Mul(&d.coef, pow10(o.scale+scale))
+	den := new(big.Int).Mul(&o.coef, pow10(d.scale))
+	r := &Decimal{scale: scale}
+	r.coef.Set(roundQuo(num, den, mode))
+	return r
+}
+
+// Rem returns the remainder of d / o with the sign of d, like math.Mod. o
+// must not be zero.
+func (d *Decimal) Rem(o *Decimal) *Decimal {
+	a, b, scale := aligned(d, o)
+	r := &Decimal{scale: scale}
+	r.coef.Rem(a, b)
+	return r
+}
+
+func (d *Decimal) Neg() *Decimal {
+	r := &Decimal{scale: d.scale}
+	r.coef.Neg(&d.coef)
+	return r
+}
+
+func (d *Decimal) Abs() *Decimal {
+	r := &Decimal{scale: d.scale}
+	r.coef.Abs(&d.coef)
+	return r
+}
+
+// Round drops the fractional digits beyond scale; d is returned as is if it
+// has no more than scale digits.
+func (d *Decimal) Round(scale int, mode RoundingMode) *Decimal {
+	if d.scale <= scale {
+		return d
+	}
+	return d.Rescale(scale, mode)
+}
+
+// Rescale returns d with exactly scale fractional digits, padding with
+// zeros or rounding by mode. A negative scale rounds to tens, hundreds and
+// so on, giving an integer.
+func (d *Decimal) Rescale(scale int, mode RoundingMode) *Decimal {
+	if scale < 0 {
+		r := &Decimal{}
+		r.coef.Mul(roundQuo(&d.coef, pow10(d.scale-scale), mode), pow10(-scale))
+		return r
+	}
+	r := &Decimal{scale: scale}
+	if scale >= d.scale {
+		r.coef.Mul(&d.coef, pow10(scale-d.scale))
+		return r
+	}
+	r.coef.Set(roundQuo(&d.coef, pow10(d.scale-scale), mode))
+	return r
+}
+
+// trimZeros drops trailing fractional zeros, keeping at least min digits.
+func (d *Decimal) trimZeros(min int) *Decimal {
+	r := &Decimal{scale: d.scale}
+	r.coef.Set(&d.coef)
+	m := new(big.Int)
+	for r.scale > min {
+		q, _ := new(big.Int).QuoRem(&r.coef, bigTen, m)
+		if m.Sign() != 0 {
+			break
+		}
+		r.coef.Set(q)
+		r.scale--
+	}
+	return r
+}
+
+// roundQuo returns num / den rounded to an integer by mode.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	sign := num.Sign() * den.Sign()
+	away := false
+	switch mode {
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = sign < 0
+	case RoundCeiling:
+		away = sign > 0
+	case RoundHalfUp, RoundHalfEven:
+		half := new(big.Int).Abs(r)
+		half.Lsh(half, 1)
+		switch c := half.Cmp(new(big.Int).Abs(den)); {
+		case c > 0:
+			away = true
+		case c == 0:
+			away = mode == RoundHalfUp || q.Bit(0) == 1
+		}
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+func (d *Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a string or a number, keeping every digit of either.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	p, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = *p
+	return nil
+}
+
+func (d *Decimal) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	p, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = *p
+	return nil
+}
+
+// decimalOperands returns both numeric operands as decimals, converting a
+// float through its shortest representation. ok is false unless at least
+// one operand is a decimal, in which case float arithmetic applies.
+func decimalOperands(ctx string, lh, rh *Value) (l, r *Decimal, ok bool, err *Value) {
+	if lh.Decimal == nil && rh.Decimal == nil {
+		return nil, nil, false, nil
+	}
+	if l, err = decimalOperand(ctx, lh, "lh"); err != nil {
+		return nil, nil, true, err
+	}
+	if r, err = decimalOperand(ctx, rh, "rh"); err != nil {
+		return nil, nil, true, err
+	}
+	return l, r, true, nil
+}
+
+func decimalOperand(ctx string, v *Value, side string) (*Decimal, *Value) {
+	if v.HasError() {
+		return nil, Error(v.Error.With(strings.ToUpper(side) + " Error"))
+	}
+	if v.Decimal != nil {
+		return v.Decimal, nil
+	}
+	if !v.IsNumeric || v.Number == nil {
+		return nil, Error(util.MsgError(ctx, side+" is not a number"))
+	}
+	d, err := DecimalFromFloat(*v.Number)
+	if err != nil {
+		return nil, Error(err.With(ctx))
+	}
+	return d, nil
+}
+
+// fnDec converts a number or text to a decimal, optionally rescaled:
+// dec("1.5", 2) is 1.50.
+func fnDec(env Env, args []*Value) *Value {
+	if err := argCount("dec", args, 1, 2); err != nil {
+		return err
+	}
+	v := args[0]
+	var d *Decimal
+	switch {
+	case v.IsNull():
+		return Nil()
+	case v.Decimal != nil:
+		d = v.Decimal
+	case v.IsNumeric && v.Number != nil:
+		var err *util.Result
+		if d, err = DecimalFromFloat(*v.Number); err != nil {
+			return Error(err.With("dec"))
+		}
+	case v.Text != nil:
+		var err *util.Result
+		if d, err = ParseDecimal(*v.Text); err != nil {
+			return Error(err.With("dec"))
+		}
+	default:
+		return Error(util.MsgError("dec", "argument 0 is not a number or text"))
+	}
+	if len(args) == 2 {
+		scale, err := intArg("dec", args, 1)
+		if err != nil {
+			return err
+		}
+		if scale < 0 || scale > MaxDecimalScale {
+			return Error(util.MsgError("dec", fmt.Sprintf("scale must be between 0 and %d", MaxDecimalScale)))
+		}
+		d = d.Rescale(scale, decimalOptionsOf(env).Rounding)
+	}
+	return Dec(d)
+}
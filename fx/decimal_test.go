@@ -0,0 +1,309 @@
+package fx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decValue parses s into a decimal Value, panicking on invalid input.
+func decValue(s string) *Value {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return Dec(d)
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		scale   int
+		wantErr bool
+	}{
+		{input: "0", want: "0"},
+		{input: "12.50", want: "12.50", scale: 2},
+		{input: "-0.001", want: "-0.001", scale: 3},
+		{input: "+7", want: "7"},
+		{input: ".5", want: "0.5", scale: 1},
+		{input: "-.5", want: "-0.5", scale: 1},
+		{input: "5.", want: "5"},
+		{input: "1.5e3", want: "1500"},
+		{input: "1.5E-3", want: "0.0015", scale: 4},
+		{input: " 123456789012345678901234567890.123 ", want: "123456789012345678901234567890.123", scale: 3},
+		{input: "", wantErr: true},
+		{input: ".", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: "1.-2", wantErr: true},
+		{input: "--1", wantErr: true},
+		{input: "1e", wantErr: true},
+		{input: "0e1000", want: "0"},
+		{input: "1e-1000", want: "0." + strings.Repeat("0", 999) + "1", scale: 1000},
+		{input: "1e999999999", wantErr: true},
+		{input: "1e-1001", wantErr: true},
+		{input: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := ParseDecimal(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDecimal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if d.String() != tt.want || d.Scale() != tt.scale {
+				t.Errorf("ParseDecimal() = %s (scale %d), want %s (scale %d)", d, d.Scale(), tt.want, tt.scale)
+			}
+		})
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		input string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{input: "2.345", scale: 2, mode: RoundHalfUp, want: "2.35"},
+		{input: "2.345", scale: 2, mode: RoundHalfEven, want: "2.34"},
+		{input: "2.355", scale: 2, mode: RoundHalfEven, want: "2.36"},
+		{input: "-2.345", scale: 2, mode: RoundHalfUp, want: "-2.35"},
+		{input: "-2.345", scale: 2, mode: RoundHalfEven, want: "-2.34"},
+		{input: "2.341", scale: 2, mode: RoundHalfUp, want: "2.34"},
+		{input: "2.349", scale: 2, mode: RoundDown, want: "2.34"},
+		{input: "-2.349", scale: 2, mode: RoundDown, want: "-2.34"},
+		{input: "2.341", scale: 2, mode: RoundUp, want: "2.35"},
+		{input: "-2.341", scale: 2, mode: RoundUp, want: "-2.35"},
+		{input: "-2.341", scale: 2, mode: RoundFloor, want: "-2.35"},
+		{input: "2.349", scale: 2, mode: RoundFloor, want: "2.34"},
+		{input: "2.341", scale: 2, mode: RoundCeiling, want: "2.35"},
+		{input: "-2.349", scale: 2, mode: RoundCeiling, want: "-2.34"},
+		{input: "1.5", scale: 3, mode: RoundHalfUp, want: "1.500"},
+		{input: "1250", scale: -2, mode: RoundHalfEven, want: "1200"},
+		{input: "1251", scale: -2, mode: RoundHalfEven, want: "1300"},
+	}
+	for _, tt := range tests {
+		d, _ := ParseDecimal(tt.input)
+		if got := d.Rescale(tt.scale, tt.mode).String(); got != tt.want {
+			t.Errorf("%s.Rescale(%d, %s) = %s, want %s", tt.input, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalValue(t *testing.T) {
+	env := WithDecimalOptions(MapEnv{
+		"a":     decValue("0.1"),
+		"b":     decValue("0.2"),
+		"price": decValue("19.99"),
+		"qty":   3,
+		"limit": json.Number("59.97"),
+		"big":   decValue("12345678901234567890.01"),
+	}, DecimalOptions{DivisionScale: 4, Rounding: RoundHalfEven})
+	tests := []struct {
+		input   string
+		want    *Value
+		wantStr string
+		wantErr string
+	}{
+		{input: `a + b == 0.3`, want: True()},
+		{input: `a + b`, wantStr: "0.3"},
+		{input: `price * qty`, wantStr: "59.97"},
+		{input: `price * qty == limit`, want: True()},
+		{input: `price * qty > limit`, want: False()},
+		{input: `big + 0.01`, wantStr: "12345678901234567890.02"},
+		{input: `big > 12345678901234567890`, want: True()},
+		{input: `price - 20`, wantStr: "-0.01"},
+		{input: `-price`, wantStr: "-19.99"},
+		{input: `abs(a - b)`, wantStr: "0.1"},
+		{input: `10 / dec("3")`, wantStr: "3.3333"},
+		{input: `dec("10.00") / 4`, wantStr: "2.50"},
+		{input: `dec("2") / 3 * 3`, wantStr: "2.0001"},
+		{input: `price % 1`, wantStr: "0.99"},
+		{input: `price / 0`, wantErr: "division by zero"},
+		{input: `price % dec("0")`, wantErr: "division by zero"},
+		{input: `round(dec("2.345"), 2)`, wantStr: "2.34"},
+		{input: `round(dec("2.3"), 2)`, wantStr: "2.3"},
+		{input: `floor(dec("-2.5"))`, wantStr: "-3"},
+		{input: `ceil(dec("2.1"))`, wantStr: "3"},
+		{input: `dec(1.5, 2)`, wantStr: "1.50"},
+		{input: `dec("2.345", 2)`, wantStr: "2.34"},
+		{input: `dec(null) is null`, want: True()},
+		{input: `dec("x")`, wantErr: "is not a decimal number"},
+		{input: `dec(1, -1)`, wantErr: "scale must be between 0 and 1000"},
+		{input: `dec(1, 1001)`, wantErr: "scale must be between 0 and 1000"},
+		{input: `dec("1e999999999")`, wantErr: "exponent of '1e999999999' is out of range"},
+		{input: `round(price, -1001)`, wantErr: "places must be between -1000 and 1000"},
+		{input: `price < "20"`, wantErr: "operand must be in same type"},
+		{input: `price + "x"`, wantErr: "rh is not a number"},
+		{input: `price == true`, want: False()},
+		{input: `min(price, 5)`, want: Number(5)},
+		{input: `price in (19.99, 5)`, want: True()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := e.Eval(env)
+			switch {
+			case tt.wantErr != "":
+				if !got.HasError() || !strings.Contains(got.Error.Error(), tt.wantErr) {
+					t.Errorf("Eval() = %v, want error %q", got, tt.wantErr)
+				}
+			case tt.wantStr != "":
+				if got.Decimal == nil || got.String() != tt.wantStr {
+					t.Errorf("Eval() = %v, want decimal %s", got, tt.wantStr)
+				}
+			case !got.Identical(tt.want):
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalOptions(t *testing.T) {
+	half := DecimalOptions{DivisionScale: 2, Rounding: RoundDown}
+	rows := Rows{MapEnv{"x": decValue("1")}, MapEnv{"x": decValue("1")}, MapEnv{"x": decValue("0")}}
+	tests := []struct {
+		name    string
+		env     Env
+		input   string
+		want    string
+		wantErr string
+	}{
+		{name: "default", env: MapEnv{}, input: `dec("2") / 3`, want: "0.6666666666666667"},
+		{name: "options", env: WithDecimalOptions(MapEnv{}, half), input: `dec("2") / 3`, want: "0.66"},
+		{name: "round", env: WithDecimalOptions(MapEnv{}, half), input: `round(dec("2.349"), 2)`, want: "2.34"},
+		{name: "dec", env: WithDecimalOptions(MapEnv{}, half), input: `dec("2.349", 1)`, want: "2.3"},
+		{name: "nil env", env: WithDecimalOptions(nil, half), input: `dec("2") / 3`, want: "0.66"},
+		{name: "then coercion", env: WithCoercion(WithDecimalOptions(MapEnv{}, half), CoerceNumeric), input: `dec("2") / 3`, want: "0.66"},
+		{name: "after coercion", env: WithDecimalOptions(WithCoercion(MapEnv{}, CoerceNumeric), half), input: `dec("2") / 3`, want: "0.66"},
+		{name: "avg", env: WithDecimalOptions(rows, half), input: `avg(x)`, want: "0.66"},
+		{name: "rows", env: WithDecimalOptions(rows, half), input: `sum(x / 3)`, want: "0.66"},
+		{name: "bad scale", env: WithDecimalOptions(MapEnv{}, DecimalOptions{DivisionScale: -1}), input: `dec("2") / 3`, wantErr: "division scale must be between 0 and 1000"},
+	}
+	if c := coercionOf(WithDecimalOptions(WithCoercion(MapEnv{}, CoerceNumeric), half)); c != CoerceNumeric {
+		t.Errorf("WithDecimalOptions() dropped the coercion: %v", c)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := e.Eval(tt.env)
+			if tt.wantErr != "" {
+				if !got.HasError() || !strings.Contains(got.Error.Error(), tt.wantErr) {
+					t.Errorf("Eval() = %v, want error %q", got, tt.wantErr)
+				}
+				return
+			}
+			if got.Decimal == nil || got.String() != tt.want {
+				t.Errorf("Eval() = %v, want decimal %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalOptionsOptimize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `dec("2") / 3`, want: `dec("2") / 3`},
+		{input: `round(dec("2.345"), 2)`, want: `round(dec("2.345"), 2)`},
+		{input: `dec("10") / 2`, want: `dec("5")`},
+		{input: `round(dec("2.5"), 2)`, want: `dec("2.5")`},
+	}
+	for _, tt := range tests {
+		e, _ := Parse(tt.input)
+		if got := Format(Optimize(e)); got != tt.want {
+			t.Errorf("Format(Optimize(%s)) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalSum(t *testing.T) {
+	rows := Rows{}
+	for i := 0; i < 10; i++ {
+		rows = append(rows, MapEnv{"amount": decValue("0.10")})
+	}
+	e, _ := Parse(`sum(amount) == 1 && avg(amount) == 0.1`)
+	if got := e.Eval(rows); !got.True() {
+		t.Errorf("Eval() = %v, want true", got)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	v := decValue("1234567890.123456789000")
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"decimal":"1234567890.123456789000"`) {
+		t.Errorf("json.Marshal() = %s", data)
+	}
+	var back Value
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Decimal == nil || back.String() != "1234567890.123456789000" {
+		t.Errorf("json.Unmarshal() = %v", back.String())
+	}
+
+	var d Decimal
+	if err := json.Unmarshal([]byte(`0.30000000000000000001`), &d); err != nil || d.String() != "0.30000000000000000001" {
+		t.Errorf("json.Unmarshal() of a number = %v, %v", d.String(), err)
+	}
+	if err := json.Unmarshal([]byte(`"x"`), &d); err == nil {
+		t.Error("json.Unmarshal() of an invalid decimal should fail")
+	}
+	if err := json.Unmarshal([]byte(`"1e999999999"`), &d); err == nil {
+		t.Error("json.Unmarshal() of a huge exponent should fail")
+	}
+
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var yback Value
+	if err := yaml.Unmarshal(out, &yback); err != nil {
+		t.Fatal(err)
+	}
+	if yback.Decimal == nil || yback.String() != "1234567890.123456789000" {
+		t.Errorf("yaml round trip = %v from %s", yback.String(), out)
+	}
+}
+
+func TestDecimalExpressionRoundTrip(t *testing.T) {
+	e, _ := Parse(`price * qty > dec("59.970")`)
+	e = Optimize(e)
+	if got := Format(e); got != `price * qty > dec("59.970")` {
+		t.Errorf("Format() = %s", got)
+	}
+	data, err := Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Format(back); got != `price * qty > dec("59.970")` {
+		t.Errorf("Format(Unmarshal(Marshal())) = %s from %s", got, data)
+	}
+
+	_, args, err := ToSQL(e, SQLiteDialect, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 || args[0] != "59.970" {
+		t.Errorf("ToSQL() args = %#v", args)
+	}
+}
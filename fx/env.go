@@ -0,0 +1,206 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Env resolves the variables referenced by an expression during evaluation.
+type Env interface {
+	Lookup(name string) (*Value, bool)
+}
+
+// EnvFunc adapts a plain function to the Env interface.
+type EnvFunc func(name string) (*Value, bool)
+
+func (f EnvFunc) Lookup(name string) (*Value, bool) {
+	return f(name)
+}
+
+// MapEnv resolves variables from a map. Dotted names such as `row.price`
+// first try the full key, then walk nested maps and structs.
+type MapEnv map[string]any
+
+func (m MapEnv) Lookup(name string) (*Value, bool) {
+	if v, ok := m[name]; ok {
+		return ValueOf(v), true
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+	root, ok := m[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	return lookupPath(reflect.ValueOf(root), parts[1:])
+}
+
+// StructEnv resolves variables from the fields of a struct (or pointer to
+// struct). A field matches by its json tag, its name, or its name ignoring
+// case; dotted names walk into nested structs and maps.
+type StructEnv struct {
+	v reflect.Value
+}
+
+func NewStructEnv(s any) *StructEnv {
+	return &StructEnv{v: reflect.ValueOf(s)}
+}
+
+func (e *StructEnv) Lookup(name string) (*Value, bool) {
+	return lookupPath(e.v, strings.Split(name, "."))
+}
+
+func lookupPath(v reflect.Value, parts []string) (*Value, bool) {
+	for _, part := range parts {
+		v = indirect(v)
+		if !v.IsValid() {
+			return nil, false
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+		case reflect.Struct:
+			idx, ok := fieldIndex(v.Type(), part)
+			if !ok {
+				return nil, false
+			}
+			v = v.Field(idx)
+		default:
+			return nil, false
+		}
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	return ValueOf(v.Interface()), true
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// structFields caches, per struct type, the field index of every name a
+// variable may use to reference the field.
+var structFields sync.Map // map[reflect.Type]map[string]int
+
+func fieldIndex(t reflect.Type, name string) (int, bool) {
+	cached, ok := structFields.Load(t)
+	if !ok {
+		names := make(map[string]int)
+		for i := t.NumField() - 1; i >= 0; i-- {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			names[strings.ToLower(f.Name)] = i
+		}
+		for i := t.NumField() - 1; i >= 0; i-- {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			names[f.Name] = i
+			if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" && tag != "-" {
+				names[tag] = i
+			}
+		}
+		cached, _ = structFields.LoadOrStore(t, names)
+	}
+	names := cached.(map[string]int)
+	if i, ok := names[name]; ok {
+		return i, true
+	}
+	i, ok := names[strings.ToLower(name)]
+	return i, ok
+}
+
+// ValueOf converts a Go value into a *Value. Nil pointers become Nil(),
+// booleans become Bool, numeric kinds become Number, time.Time becomes Time
+// and strings become Text.
+// Unsupported types yield an error value.
+func ValueOf(x any) *Value {
+	switch t := x.(type) {
+	case nil:
+		return Nil()
+	case *Value:
+		if t == nil {
+			return Nil()
+		}
+		return t
+	case Value:
+		return &t
+	case bool:
+		return Bool(t)
+	case string:
+		return Text(t)
+	case float64:
+		return Number(t)
+	case int:
+		return Number(float64(t))
+	case time.Time:
+		return Time(t)
+	case *time.Time:
+		if t == nil {
+			return Nil()
+		}
+		return Time(*t)
+	case *Decimal:
+		if t == nil {
+			return Nil()
+		}
+		return Dec(t)
+	case Decimal:
+		return Dec(&t)
+	case json.Number:
+		// as decoded with json.Decoder.UseNumber, keeping every digit
+		if d, err := ParseDecimal(string(t)); err == nil {
+			return Dec(d)
+		}
+		return Text(string(t))
+	case fmt.Stringer:
+		if rv := reflect.ValueOf(x); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return Nil()
+		}
+		return Text(t.String())
+	}
+
+	rv := indirect(reflect.ValueOf(x))
+	if !rv.IsValid() {
+		return Nil()
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Bool(rv.Bool())
+	case reflect.String:
+		return Text(rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Number(float64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Number(float64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return Number(rv.Float())
+	}
+	if reflect.ValueOf(x).Kind() == reflect.Pointer && rv.CanInterface() {
+		return ValueOf(rv.Interface())
+	}
+	return Error(util.MsgError("ValueOf", fmt.Sprintf("unsupported type %T", x)))
+}
@@ -0,0 +1,178 @@
+package fx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+type envTestInner struct {
+	Region string
+}
+
+type envTestRow struct {
+	Price    float64 `json:"price"`
+	Qty      int
+	Name     string `json:"product_name,omitempty"`
+	Inner    envTestInner
+	InnerPtr *envTestInner
+	Labels   map[string]string
+	hidden   string
+}
+
+func TestMapEnv_Lookup(t *testing.T) {
+	env := MapEnv{
+		"price":     12.5,
+		"qty":       int64(3),
+		"name":      "widget",
+		"active":    true,
+		"missing":   nil,
+		"row":       map[string]any{"region": "EU", "nested": map[string]int{"n": 7}},
+		"row.flat":  "flat",
+		"structRow": envTestRow{Inner: envTestInner{Region: "APAC"}},
+	}
+
+	tests := []struct {
+		name   string
+		want   *Value
+		wantOk bool
+	}{
+		{name: "price", want: Number(12.5), wantOk: true},
+		{name: "qty", want: Number(3), wantOk: true},
+		{name: "name", want: Text("widget"), wantOk: true},
+		{name: "active", want: True(), wantOk: true},
+		{name: "missing", want: Nil(), wantOk: true},
+		{name: "row.region", want: Text("EU"), wantOk: true},
+		{name: "row.nested.n", want: Number(7), wantOk: true},
+		{name: "row.flat", want: Text("flat"), wantOk: true},
+		{name: "structRow.Inner.Region", want: Text("APAC"), wantOk: true},
+		{name: "row.unknown", wantOk: false},
+		{name: "unknown", wantOk: false},
+		{name: "price.cents", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := env.Lookup(tt.name)
+			if ok != tt.wantOk {
+				t.Fatalf("Lookup(%q) ok = %v, want %v", tt.name, ok, tt.wantOk)
+			}
+			if ok && !got.Identical(tt.want) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.name, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestStructEnv_Lookup(t *testing.T) {
+	row := &envTestRow{
+		Price:    9.99,
+		Qty:      4,
+		Name:     "gadget",
+		Inner:    envTestInner{Region: "EU"},
+		InnerPtr: &envTestInner{Region: "US"},
+		Labels:   map[string]string{"team": "data"},
+		hidden:   "secret",
+	}
+	env := NewStructEnv(row)
+
+	tests := []struct {
+		name   string
+		want   *Value
+		wantOk bool
+	}{
+		{name: "price", want: Number(9.99), wantOk: true},
+		{name: "Price", want: Number(9.99), wantOk: true},
+		{name: "qty", want: Number(4), wantOk: true},
+		{name: "product_name", want: Text("gadget"), wantOk: true},
+		{name: "Inner.Region", want: Text("EU"), wantOk: true},
+		{name: "inner.region", want: Text("EU"), wantOk: true},
+		{name: "InnerPtr.Region", want: Text("US"), wantOk: true},
+		{name: "Labels.team", want: Text("data"), wantOk: true},
+		{name: "hidden", wantOk: false},
+		{name: "nope", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := env.Lookup(tt.name)
+			if ok != tt.wantOk {
+				t.Fatalf("Lookup(%q) ok = %v, want %v", tt.name, ok, tt.wantOk)
+			}
+			if ok && !got.Identical(tt.want) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.name, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+
+	t.Run("nil pointer field", func(t *testing.T) {
+		env := NewStructEnv(envTestRow{})
+		if _, ok := env.Lookup("InnerPtr.Region"); ok {
+			t.Error("Lookup() through nil pointer should not resolve")
+		}
+		got, ok := env.Lookup("InnerPtr")
+		if !ok || !got.IsNil() {
+			t.Errorf("Lookup(InnerPtr) = %v, %v, want nil value", util.JsonStr(got), ok)
+		}
+	})
+}
+
+func TestEnvFunc_Lookup(t *testing.T) {
+	env := EnvFunc(func(name string) (*Value, bool) {
+		if name == "x" {
+			return Number(1), true
+		}
+		return nil, false
+	})
+
+	exp := NewLessExp(NewVarExp("x"), Number(2))
+	if got := exp.Eval(env); !got.True() {
+		t.Errorf("Eval() = %v, want true", util.JsonStr(got))
+	}
+	exp = NewLessExp(NewVarExp("y"), Number(2))
+	if got := exp.Eval(env); !got.HasError() {
+		t.Errorf("Eval() with undefined variable = %v, want error", util.JsonStr(got))
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	var nilValue *Value
+	var nilInt *int
+	n := 5
+
+	tests := []struct {
+		name    string
+		x       any
+		want    *Value
+		wantErr bool
+	}{
+		{name: "nil", x: nil, want: Nil()},
+		{name: "nil *Value", x: nilValue, want: Nil()},
+		{name: "*Value", x: Text("a"), want: Text("a")},
+		{name: "Value", x: *Number(1), want: Number(1)},
+		{name: "bool", x: true, want: True()},
+		{name: "string", x: "abc", want: Text("abc")},
+		{name: "int", x: 3, want: Number(3)},
+		{name: "uint8", x: uint8(3), want: Number(3)},
+		{name: "float32", x: float32(1.5), want: Number(1.5)},
+		{name: "named string", x: Operator("<"), want: Text("<")},
+		{name: "json.Number", x: json.Number("0.10"), want: decValue("0.1")},
+		{name: "decimal", x: decValue("1.5").Decimal, want: Number(1.5)},
+		{name: "pointer", x: &n, want: Number(5)},
+		{name: "nil pointer", x: nilInt, want: Nil()},
+		{name: "unsupported", x: []int{1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValueOf(tt.x)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("ValueOf(%v) error = %v, wantErr %v", tt.x, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("ValueOf(%v) = %v, want %v", tt.x, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
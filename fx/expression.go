@@ -2,30 +2,172 @@ package fx
 
 import "github.com/soderasen-au/go-common/util"
 
+// Expression is a node of an fx expression tree. Calc evaluates a tree that
+// holds no variables; Eval resolves variables from env, so the same tree can
+// be evaluated against many records.
 type Expression interface {
 	Calc() *Value
+	Eval(env Env) *Value
 }
 
 type BinOpExp struct {
-	Op Operator
-	Lh Expression
-	Rh Expression
+	Op  Operator
+	Lh  Expression
+	Rh  Expression
+	Pos Position // of the operator in source text, zero if built in code
 }
 
-type BinOpExpCreator func(lh *Value, rh *Value) Expression
+type UnaryOpExp struct {
+	Op  Operator
+	X   Expression
+	Pos Position // of the operator in source text, zero if built in code
+}
+
+// BinOp returns e itself. It is promoted to every expression type embedding
+// BinOpExp, so code walking a tree can reach the operator and operands
+// without knowing the concrete type; see BinaryExpression.
+func (e BinOpExp) BinOp() BinOpExp {
+	return e
+}
+
+// UnaryOp returns e itself, see BinOpExp.BinOp.
+func (e UnaryOpExp) UnaryOp() UnaryOpExp {
+	return e
+}
+
+// positioner is implemented by the nodes that remember their source
+// position; the parser sets it after a creator has built the node.
+type positioner interface {
+	setPos(pos Position)
+}
+
+func (e *BinOpExp) setPos(pos Position) {
+	e.Pos = pos
+}
+
+func (e *UnaryOpExp) setPos(pos Position) {
+	e.Pos = pos
+}
+
+// withPos records pos on e if e can hold a position, and returns e.
+func withPos(e Expression, pos Position) Expression {
+	if p, ok := e.(positioner); ok {
+		p.setPos(pos)
+	}
+	return e
+}
+
+// PosOf returns where e starts in source text: the operator of an operator
+// expression, the name of a variable or call, or the '(' of a list. It is
+// zero for literals and for nodes built in code.
+func PosOf(e Expression) Position {
+	switch x := e.(type) {
+	case *VarExp:
+		return x.Pos
+	case *CallExp:
+		return x.Pos
+	case *ListExp:
+		return x.Pos
+	case BinaryExpression:
+		return x.BinOp().Pos
+	case UnaryExpression:
+		return x.UnaryOp().Pos
+	}
+	return Position{}
+}
+
+// BinaryExpression is implemented by every expression built from a
+// BinOpExpCreator that embeds BinOpExp.
+type BinaryExpression interface {
+	Expression
+	BinOp() BinOpExp
+}
+
+// UnaryExpression is implemented by every expression built from a
+// UnaryOpExpCreator that embeds UnaryOpExp.
+type UnaryExpression interface {
+	Expression
+	UnaryOp() UnaryOpExp
+}
+
+type BinOpExpCreator func(lh Expression, rh Expression) Expression
+
+// ValueBinOpExpCreator is the creator of an operator whose operands must be
+// values, as all operands were before variables. RegisterNewBinOpExpCreator
+// still takes one: it is called with the operands themselves when both are
+// values, and otherwise with their values each time the expression is
+// evaluated.
+type ValueBinOpExpCreator func(lh *Value, rh *Value) Expression
+
+type UnaryOpExpCreator func(x Expression) Expression
 
 var (
-	binOpExpCreators = map[Operator]BinOpExpCreator{}
+	binOpExpCreators   = map[Operator]BinOpExpCreator{}
+	unaryOpExpCreators = map[Operator]UnaryOpExpCreator{}
+
+	// postfixOps marks the unary operators written after their operand.
+	postfixOps = map[Operator]bool{}
 )
 
-func RegisterNewBinOpExpCreator(op Operator, creator BinOpExpCreator) {
-	binOpExpCreators[op] = creator
+// RegisterNewBinOpExpCreator makes NewBinOpExp, and so the parser, build the
+// expressions of op with creator, a BinOpExpCreator or a
+// ValueBinOpExpCreator.
+func RegisterNewBinOpExpCreator[C BinOpExpCreator | func(lh, rh Expression) Expression | ValueBinOpExpCreator | func(lh, rh *Value) Expression](op Operator, creator C) {
+	switch c := any(creator).(type) {
+	case BinOpExpCreator:
+		binOpExpCreators[op] = c
+	case func(lh, rh Expression) Expression:
+		binOpExpCreators[op] = c
+	case ValueBinOpExpCreator:
+		binOpExpCreators[op] = c.adapt(op)
+	case func(lh, rh *Value) Expression:
+		binOpExpCreators[op] = ValueBinOpExpCreator(c).adapt(op)
+	}
+}
+
+// adapt returns a BinOpExpCreator calling c, see ValueBinOpExpCreator.
+func (c ValueBinOpExpCreator) adapt(op Operator) BinOpExpCreator {
+	return func(lh, rh Expression) Expression {
+		lv, lok := lh.(*Value)
+		rv, rok := rh.(*Value)
+		if lok && rok {
+			return c(lv, rv)
+		}
+		return &valueBinOpExp{BinOpExp: BinOpExp{Op: op, Lh: lh, Rh: rh}, create: c}
+	}
+}
+
+// valueBinOpExp evaluates its operands and evaluates the expression create
+// builds from their values.
+type valueBinOpExp struct {
+	BinOpExp
+	create ValueBinOpExpCreator
 }
 
-func NewBinOpExp(op Operator, lh, rh *Value) (Expression, *util.Result) {
+func (e valueBinOpExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e valueBinOpExp) Eval(env Env) *Value {
+	return e.create(e.Lh.Eval(env), e.Rh.Eval(env)).Eval(env)
+}
+
+func RegisterNewUnaryOpExpCreator(op Operator, creator UnaryOpExpCreator) {
+	unaryOpExpCreators[op] = creator
+}
+
+func NewBinOpExp(op Operator, lh, rh Expression) (Expression, *util.Result) {
 	c, ok := binOpExpCreators[op]
 	if !ok {
 		return nil, util.MsgError("LookupCreator", "No creator for OP: "+string(op))
 	}
 	return c(lh, rh), nil
 }
+
+func NewUnaryOpExp(op Operator, x Expression) (Expression, *util.Result) {
+	c, ok := unaryOpExpCreators[op]
+	if !ok {
+		return nil, util.MsgError("LookupCreator", "No unary creator for OP: "+string(op))
+	}
+	return c(x), nil
+}
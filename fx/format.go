@@ -0,0 +1,233 @@
+package fx
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// precPrimary is the binding power of operands that never need parentheses:
+// literals, variables, calls, lists and prefix operators.
+const precPrimary = precMultiply + 1
+
+// Format prints an expression tree as source text that Parse reads back into
+// an equivalent tree. Parentheses are only added where precedence needs them.
+// Time literals are printed as date("...") calls and error values, which
+// have no source form, as <error: ...>.
+func Format(e Expression) string {
+	sb := &strings.Builder{}
+	format(sb, e)
+	return sb.String()
+}
+
+func (e BinOpExp) String() string {
+	sb := &strings.Builder{}
+	formatBinOp(sb, e)
+	return sb.String()
+}
+
+func (e UnaryOpExp) String() string {
+	sb := &strings.Builder{}
+	formatUnaryOp(sb, e)
+	return sb.String()
+}
+
+func (e VarExp) String() string {
+	return Format(&e)
+}
+
+func (e CallExp) String() string {
+	return Format(&e)
+}
+
+func (e ListExp) String() string {
+	return Format(&e)
+}
+
+// precOfExp returns the binding power of the operator at the root of e.
+func precOfExp(e Expression) int {
+	switch x := e.(type) {
+	case Expr:
+		return precOfExp(x.Expression)
+	case BinaryExpression:
+		return precedenceOf(x.BinOp().Op)
+	case UnaryExpression:
+		if op := x.UnaryOp().Op; postfixOps[op] {
+			return precedenceOf(op)
+		}
+	}
+	return precPrimary
+}
+
+// formatOperand prints e, in parentheses if it binds looser than minPrec.
+func formatOperand(sb *strings.Builder, e Expression, minPrec int) {
+	if precOfExp(e) >= minPrec {
+		format(sb, e)
+		return
+	}
+	sb.WriteString("(")
+	format(sb, e)
+	sb.WriteString(")")
+}
+
+func format(sb *strings.Builder, e Expression) {
+	switch x := e.(type) {
+	case nil:
+		sb.WriteString("<nil>")
+	case Expr:
+		format(sb, x.Expression)
+	case *Value:
+		formatValue(sb, x)
+	case *VarExp:
+		formatVar(sb, x.Name)
+	case *CallExp:
+		formatCall(sb, x.Name, x.Args)
+	case *ListExp:
+		formatList(sb, x.Items)
+	case BinaryExpression:
+		formatBinOp(sb, x.BinOp())
+	case UnaryExpression:
+		formatUnaryOp(sb, x.UnaryOp())
+	default:
+		if s, ok := e.(interface{ String() string }); ok {
+			sb.WriteString(s.String())
+		} else {
+			sb.WriteString("<unknown>")
+		}
+	}
+}
+
+func formatBinOp(sb *strings.Builder, b BinOpExp) {
+	prec := precedenceOf(b.Op)
+	formatOperand(sb, b.Lh, prec)
+	sb.WriteString(" " + string(b.Op) + " ")
+	if bounds, ok := b.Rh.(*ListExp); ok && b.Op == BetweenOp && len(bounds.Items) == 2 {
+		formatOperand(sb, bounds.Items[0], prec+1)
+		sb.WriteString(" and ")
+		formatOperand(sb, bounds.Items[1], prec+1)
+		return
+	}
+	formatOperand(sb, b.Rh, prec+1)
+}
+
+func formatUnaryOp(sb *strings.Builder, u UnaryOpExp) {
+	if postfixOps[u.Op] {
+		formatOperand(sb, u.X, precedenceOf(u.Op))
+		sb.WriteString(" " + string(u.Op))
+		return
+	}
+	sb.WriteString(string(u.Op))
+	if op := []rune(u.Op); len(op) > 0 && isIdentPart(op[len(op)-1]) {
+		sb.WriteString(" ")
+	}
+	// -5 would be read back as a negative literal rather than a negation.
+	if v, ok := u.X.(*Value); ok && u.Op == NegOp && v.IsNumeric && !v.IsBool() {
+		sb.WriteString("(")
+		formatValue(sb, v)
+		sb.WriteString(")")
+		return
+	}
+	formatOperand(sb, u.X, precPrimary)
+}
+
+func formatValue(sb *strings.Builder, v *Value) {
+	switch {
+	case v.HasError():
+		sb.WriteString("<error: " + v.Error.Error() + ">")
+	case v.IsNull():
+		sb.WriteString("null")
+	case v.IsBool():
+		sb.WriteString(*v.Text)
+	case v.IsTime():
+		sb.WriteString("date(")
+		sb.WriteString(quoteText(v.Time.Format(time.RFC3339Nano)))
+		sb.WriteString(")")
+	case v.Decimal != nil:
+		sb.WriteString("dec(" + quoteText(v.Decimal.String()) + ")")
+	case v.IsDual():
+		// the text keeps what the number would lose, such as "007"
+		sb.WriteString(quoteText(*v.Text))
+	case v.IsNumeric:
+		sb.WriteString(strconv.FormatFloat(util.MaybeNil(v.Number), 'f', -1, 64))
+	default:
+		sb.WriteString(quoteText(util.MaybeNil(v.Text)))
+	}
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+)
+
+func quoteText(s string) string {
+	return `"` + textEscaper.Replace(s) + `"`
+}
+
+// formatVar prints name bare when the lexer would read it back as the same
+// variable, and with the '$' prefix when it clashes with a keyword or an
+// operator word.
+func formatVar(sb *strings.Builder, name string) {
+	if isPlainIdent(name) {
+		sb.WriteString(name)
+		return
+	}
+	sb.WriteString("$" + name)
+}
+
+func isPlainIdent(name string) bool {
+	for i, seg := range strings.Split(name, ".") {
+		rs := []rune(seg)
+		if len(rs) == 0 || !isIdentStart(rs[0]) {
+			return false
+		}
+		for _, r := range rs {
+			if !isIdentPart(r) {
+				return false
+			}
+		}
+		if i > 0 {
+			continue
+		}
+		switch seg {
+		case "true", "false", "null", "and":
+			return false
+		}
+		for _, op := range knownOperators() {
+			if op == seg || strings.HasPrefix(op, seg+" ") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func formatCall(sb *strings.Builder, name string, args []Expression) {
+	sb.WriteString(name)
+	sb.WriteString("(")
+	for i, a := range args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		format(sb, a)
+	}
+	sb.WriteString(")")
+}
+
+func formatList(sb *strings.Builder, items []Expression) {
+	sb.WriteString("(")
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		format(sb, item)
+	}
+	if len(items) == 1 {
+		sb.WriteString(",")
+	}
+	sb.WriteString(")")
+}
@@ -0,0 +1,136 @@
+package fx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "relation", input: `price>=100`, want: `price >= 100`},
+		{name: "dollar variable", input: `$price >= 100`, want: `price >= 100`},
+		{name: "dotted variable", input: `row.price < 1.5`, want: `row.price < 1.5`},
+		{name: "keyword variable", input: `$null == 1`, want: `$null == 1`},
+		{name: "operator variable", input: `$in == 1`, want: `$in == 1`},
+		{name: "precedence kept", input: `a + b * c`, want: `a + b * c`},
+		{name: "parens kept", input: `(a + b) * c`, want: `(a + b) * c`},
+		{name: "redundant parens dropped", input: `(a * b) + ((c))`, want: `a * b + c`},
+		{name: "left assoc", input: `a - b - c`, want: `a - b - c`},
+		{name: "right grouping", input: `a - (b - c)`, want: `a - (b - c)`},
+		{name: "logic", input: `a > 1 && (b < 2 || c == 3)`, want: `a > 1 && (b < 2 || c == 3)`},
+		{name: "not", input: `!(a && b)`, want: `!(a && b)`},
+		{name: "double not", input: `!!a`, want: `!!a`},
+		{name: "neg", input: `-(a + 1)`, want: `-(a + 1)`},
+		{name: "neg literal", input: `-a * -2`, want: `-a * -2`},
+		{name: "text escapes", input: `name == 'say "hi"\n\\'`, want: `name == "say \"hi\"\n\\"`},
+		{name: "literals", input: `f(true, false, null, 0.25, -3)`, want: `f(true, false, null, 0.25, -3)`},
+		{name: "large number", input: `x < 1e21`, want: `x < 1000000000000000000000`},
+		{name: "call", input: `lower( trim(name) ) ~= "ab"`, want: `lower(trim(name)) ~= "ab"`},
+		{name: "regex", input: `code =~ "^E[0-9]+$"`, want: `code =~ "^E[0-9]+$"`},
+		{name: "in", input: `status in ("failed","timeout")`, want: `status in ("failed", "timeout")`},
+		{name: "in single", input: `status not in ("failed",)`, want: `status not in ("failed",)`},
+		{name: "between", input: `x between 1+1 and (10)`, want: `x between 1 + 1 and 10`},
+		{name: "between logic bound", input: `x between (a || b) and c`, want: `x between (a || b) and c`},
+		{name: "is null", input: `x is null || y is not null`, want: `x is null || y is not null`},
+		{name: "is null of relation", input: `a == b is null`, want: `a == b is null`},
+		{name: "is null operand", input: `a == (b is null)`, want: `a == (b is null)`},
+		{name: "not is null", input: `!(x is null)`, want: `!(x is null)`},
+		{name: "multi line", input: "a > 1\n&& b", want: `a > 1 && b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := Format(exp)
+			if got != tt.want {
+				t.Errorf("Format() = %s, want %s", got, tt.want)
+			}
+			again, err := Parse(got)
+			if err != nil {
+				t.Fatalf("Parse(Format()) error = %v", err)
+			}
+			if s := Format(again); s != got {
+				t.Errorf("Format(Parse(Format())) = %s, want %s", s, got)
+			}
+		})
+	}
+}
+
+func init() {
+	RegisterFunc("f", VariadicArity, func(args []*Value) *Value { return Nil() })
+}
+
+func TestFormat_Built(t *testing.T) {
+	when := time.Date(2024, 3, 1, 8, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		exp  Expression
+		want string
+	}{
+		{name: "neg of positive literal", exp: NewNegExp(Number(5)), want: `-(5)`},
+		{name: "neg of negative literal", exp: NewNegExp(Number(-5)), want: `-(-5)`},
+		{name: "time literal", exp: NewLessExp(NewVarExp("at"), Time(when)), want: `at < date("2024-03-01T08:15:00Z")`},
+		{name: "right nested same precedence", exp: NewLessExp(Number(1), NewLessExp(Number(2), Number(3))), want: `1 < (2 < 3)`},
+		{name: "dual value", exp: Dual("42"), want: `"42"`},
+		{name: "dual value with leading zeros", exp: NewEqualExp(NewVarExp("code"), Dual("007")), want: `code == "007"`},
+		{name: "text value", exp: Dual("abc"), want: `"abc"`},
+		{name: "variable name with dash", exp: NewVarExp("a-b"), want: `$a-b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.exp); got != tt.want {
+				t.Errorf("Format() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Stringer(t *testing.T) {
+	exp, err := Parse(`lower(name) == "a" && x in (1, 2)`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got, want := exp.(BinaryExpression).BinOp().String(), `lower(name) == "a" && x in (1, 2)`; got != want {
+		t.Errorf("BinOpExp.String() = %s, want %s", got, want)
+	}
+	rh := exp.(BinaryExpression).BinOp().Rh
+	if got, want := rh.(*InExp).String(), `x in (1, 2)`; got != want {
+		t.Errorf("InExp.String() = %s, want %s", got, want)
+	}
+	if got, want := NewVarExp("x").(*VarExp).String(), `x`; got != want {
+		t.Errorf("VarExp.String() = %s, want %s", got, want)
+	}
+}
+
+func TestFormat_Eval(t *testing.T) {
+	env := MapEnv{"a": 3, "b": 4, "name": "Bob", "tags": nil}
+	inputs := []string{
+		`a - (b - 1) * 2`,
+		`!(a > b) && lower(name) == "bob"`,
+		`tags is null || a between b - 2 and b`,
+		`-a % 2 == -1`,
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			exp, err := Parse(input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", input, err)
+			}
+			again, err := Parse(Format(exp))
+			if err != nil {
+				t.Fatalf("Parse(Format()) error = %v", err)
+			}
+			if got, want := again.Eval(env), exp.Eval(env); !got.Identical(want) {
+				t.Errorf("Eval() = %v, want %v", got, want)
+			}
+		})
+	}
+}
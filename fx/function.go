@@ -0,0 +1,161 @@
+package fx
+
+import (
+	"fmt"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// VariadicArity registers a function that accepts any number of arguments;
+// the function checks its own argument count.
+const VariadicArity = -1
+
+// Func is the implementation of a function callable from an expression. It
+// receives evaluated, error-free arguments.
+type Func func(args []*Value) *Value
+
+type funcDef struct {
+	name     string
+	arity    int
+	fn       Func
+	envFn    func(env Env, args []*Value) *Value // set instead of fn, see registerEnvFunc
+	volatile bool
+	agg      AggregatorCreator // set for aggregates, see RegisterAggregate
+}
+
+var (
+	funcs = map[string]*funcDef{}
+)
+
+// RegisterFunc makes fn callable as name(...) from expressions. arity is the
+// exact number of arguments, or VariadicArity. fn must return the same result
+// for the same arguments, so that Optimize can fold calls with constant
+// arguments; use RegisterVolatileFunc otherwise.
+func RegisterFunc(name string, arity int, fn Func) {
+	funcs[name] = &funcDef{name: name, arity: arity, fn: fn}
+}
+
+// RegisterVolatileFunc registers a function, such as now(), whose result may
+// change between calls with the same arguments.
+func RegisterVolatileFunc(name string, arity int, fn Func) {
+	funcs[name] = &funcDef{name: name, arity: arity, fn: fn, volatile: true}
+}
+
+// registerEnvFunc registers a built-in whose result depends on the options
+// of the Env it is evaluated in, such as the DecimalOptions of round().
+func registerEnvFunc(name string, arity int, fn func(env Env, args []*Value) *Value) {
+	funcs[name] = &funcDef{name: name, arity: arity, envFn: fn}
+}
+
+// CallExp calls a registered function with the values of its arguments.
+type CallExp struct {
+	Name string
+	Args []Expression
+	Pos  Position
+	def  *funcDef
+}
+
+func (e *CallExp) setPos(pos Position) {
+	e.Pos = pos
+}
+
+func (e CallExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+// Eval evaluates every argument first; an argument error is returned as is
+// without calling the function. Aggregates evaluate their argument over the
+// rows of env instead, if env is a RowSet; otherwise a function of the same
+// name, such as the scalar max(x), is called if there is one.
+func (e CallExp) Eval(env Env) *Value {
+	def := e.def
+	if def == nil {
+		var err *util.Result
+		if def, err = lookupFunc(e.Name, len(e.Args)); err != nil {
+			return Error(err)
+		}
+	}
+	if def.agg != nil {
+		scalar, ok := funcs[e.Name]
+		if _, isRows := env.(RowSet); isRows || !ok || (scalar.arity != VariadicArity && scalar.arity != len(e.Args)) {
+			return evalAggregate(e, def, env)
+		}
+		def = scalar
+	}
+	args := make([]*Value, len(e.Args))
+	for i, a := range e.Args {
+		v := a.Eval(env)
+		if v.HasError() {
+			return Error(v.Error.With(fmt.Sprintf("%s Arg %d Error", e.Name, i)))
+		}
+		args[i] = v
+	}
+	if def.envFn != nil {
+		return def.envFn(env, args)
+	}
+	return def.fn(args)
+}
+
+func lookupFunc(name string, argc int) (*funcDef, *util.Result) {
+	if def, ok := aggregates[name]; ok && def.arity == argc {
+		return def, nil
+	}
+	def, ok := funcs[name]
+	if !ok && aggregates[name] != nil {
+		return nil, util.MsgError("LookupFunc", fmt.Sprintf("%s expects 1 argument(s), got %d", name, argc))
+	}
+	if !ok {
+		return nil, util.MsgError("LookupFunc", "unknown function: "+name)
+	}
+	if def.arity != VariadicArity && def.arity != argc {
+		return nil, util.MsgError("LookupFunc", fmt.Sprintf("%s expects %d argument(s), got %d", name, def.arity, argc))
+	}
+	return def, nil
+}
+
+// NewCallExp resolves name in the function registry and checks the number
+// of arguments.
+func NewCallExp(name string, args ...Expression) (Expression, *util.Result) {
+	def, err := lookupFunc(name, len(args))
+	if err != nil {
+		return nil, err
+	}
+	return &CallExp{
+		Name: name,
+		Args: args,
+		def:  def,
+	}, nil
+}
+
+// argCount checks the argument count of a variadic function.
+func argCount(name string, args []*Value, min, max int) *Value {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return Error(util.MsgError(name, fmt.Sprintf("expects %d argument(s), got %d", min, len(args))))
+		}
+		if max < 0 {
+			return Error(util.MsgError(name, fmt.Sprintf("expects at least %d argument(s), got %d", min, len(args))))
+		}
+		return Error(util.MsgError(name, fmt.Sprintf("expects %d to %d arguments, got %d", min, max, len(args))))
+	}
+	return nil
+}
+
+// textArg returns args[i] as text, or an error value if it is not text.
+func textArg(name string, args []*Value, i int) (string, *Value) {
+	v := args[i]
+	if !v.isText() {
+		return "", Error(util.MsgError(name, fmt.Sprintf("argument %d is not text", i)))
+	}
+	return *v.Text, nil
+}
+
+// intArg returns args[i] as an integer, or an error value if it is not a
+// whole number.
+func intArg(name string, args []*Value, i int) (int, *Value) {
+	v := args[i]
+	if !v.IsNumeric || v.Number == nil || *v.Number != float64(int(*v.Number)) {
+		return 0, Error(util.MsgError(name, fmt.Sprintf("argument %d is not an integer", i)))
+	}
+	return int(*v.Number), nil
+}
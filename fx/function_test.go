@@ -0,0 +1,101 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	RegisterFunc("double", 1, func(args []*Value) *Value {
+		return args[0].Mul(Number(2))
+	})
+	defer delete(funcs, "double")
+
+	exp, err := NewCallExp("double", Number(21))
+	if err != nil {
+		t.Fatalf("NewCallExp() error = %v", err)
+	}
+	if got := exp.Calc(); !got.Equal(Number(42)).True() {
+		t.Errorf("double(21) = %v, want 42", util.JsonStr(got))
+	}
+
+	exp, perr := Parse("double(x) + 1")
+	if perr != nil {
+		t.Fatalf("Parse() error = %v", perr)
+	}
+	if got := exp.Eval(MapEnv{"x": 4}); !got.Equal(Number(9)).True() {
+		t.Errorf("double(x) + 1 = %v, want 9", util.JsonStr(got))
+	}
+}
+
+func TestNewCallExp_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		args []Expression
+	}{
+		{name: "unknown function", fn: "nope", args: nil},
+		{name: "too few arguments", fn: "lower", args: nil},
+		{name: "too many arguments", fn: "lower", args: []Expression{Text("a"), Text("b")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCallExp(tt.fn, tt.args...); err == nil {
+				t.Errorf("NewCallExp(%s) should fail", tt.fn)
+			}
+		})
+	}
+}
+
+func TestCallExp_Eval(t *testing.T) {
+	t.Run("argument error is propagated", func(t *testing.T) {
+		exp, err := NewCallExp("upper", NewVarExp("missing"))
+		if err != nil {
+			t.Fatalf("NewCallExp() error = %v", err)
+		}
+		if got := exp.Eval(MapEnv{}); !got.HasError() {
+			t.Errorf("Eval() = %v, want error", util.JsonStr(got))
+		}
+	})
+
+	t.Run("unresolved function", func(t *testing.T) {
+		exp := &CallExp{Name: "nope"}
+		if got := exp.Calc(); !got.HasError() {
+			t.Errorf("Calc() = %v, want error", util.JsonStr(got))
+		}
+	})
+
+	t.Run("resolved lazily", func(t *testing.T) {
+		exp := &CallExp{Name: "upper", Args: []Expression{Text("a")}}
+		if got := exp.Calc(); !got.Equal(Text("A")).True() {
+			t.Errorf("Calc() = %v, want A", util.JsonStr(got))
+		}
+	})
+}
+
+func TestParse_CallErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos Position
+	}{
+		{name: "unknown function", input: "1 + nope(1)", wantPos: Position{Line: 1, Column: 5}},
+		{name: "wrong arity", input: `lower("a", "b")`, wantPos: Position{Line: 1, Column: 1}},
+		{name: "missing close paren", input: `lower("a"`, wantPos: Position{Line: 1, Column: 10}},
+		{name: "trailing comma", input: `lower("a",)`, wantPos: Position{Line: 1, Column: 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) should fail", tt.input)
+			}
+			if pos, _ := err.Result.(Position); pos != tt.wantPos {
+				t.Errorf("Parse(%q) error at %v, want %v (%v)", tt.input, pos, tt.wantPos, err)
+			}
+		})
+	}
+}
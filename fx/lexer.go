@@ -0,0 +1,346 @@
+package fx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokText
+	tokIdent
+	tokVar
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of input"
+	case tokNumber:
+		return "number"
+	case tokText:
+		return "text"
+	case tokIdent:
+		return "identifier"
+	case tokVar:
+		return "variable"
+	case tokOp:
+		return "operator"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokComma:
+		return "','"
+	}
+	return "unknown token"
+}
+
+// Position is a 1-based line/column location in expression source text.
+type Position struct {
+	Line   int `json:"line" yaml:"line"`
+	Column int `json:"column" yaml:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  Position
+}
+
+type lexer struct {
+	src  []rune
+	off  int
+	pos  Position
+	ops  []string
+	prev *token
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{
+		src: []rune(src),
+		pos: Position{Line: 1, Column: 1},
+		ops: knownOperators(),
+	}
+}
+
+// knownOperators lists every registered operator spelling, longest first,
+// so that the lexer can do longest-match on symbolic operators.
+func knownOperators() []string {
+	ops := make([]string, 0, len(binOpExpCreators)+len(unaryOpExpCreators))
+	for op := range binOpExpCreators {
+		if op != UnkownOp {
+			ops = append(ops, string(op))
+		}
+	}
+	for op := range unaryOpExpCreators {
+		if _, ok := binOpExpCreators[op]; !ok && op != UnkownOp {
+			ops = append(ops, string(op))
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if len(ops[i]) != len(ops[j]) {
+			return len(ops[i]) > len(ops[j])
+		}
+		return ops[i] < ops[j]
+	})
+	return ops
+}
+
+func isOperator(s string) bool {
+	if _, ok := binOpExpCreators[Operator(s)]; ok {
+		return true
+	}
+	_, ok := unaryOpExpCreators[Operator(s)]
+	return ok
+}
+
+func parseError(pos Position, format string, a ...interface{}) *util.Result {
+	err := util.MsgError("Parse", pos.String()+": "+fmt.Sprintf(format, a...))
+	err.Result = pos
+	return err
+}
+
+func (l *lexer) peekRune(n int) rune {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.off+n]
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.off]
+	l.off++
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r
+}
+
+func (l *lexer) skipSpaces() {
+	for l.off < len(l.src) && unicode.IsSpace(l.src[l.off]) {
+		l.advance()
+	}
+}
+
+// operandEnded reports whether the previous token closes an operand, in which
+// case a following '-' is a binary operator rather than a number sign.
+func (l *lexer) operandEnded() bool {
+	if l.prev == nil {
+		return false
+	}
+	switch l.prev.kind {
+	case tokNumber, tokText, tokIdent, tokVar, tokRParen:
+		return true
+	}
+	return false
+}
+
+func (l *lexer) next() (*token, *util.Result) {
+	tok, err := l.scan()
+	if err == nil {
+		l.prev = tok
+	}
+	return tok, err
+}
+
+func (l *lexer) scan() (*token, *util.Result) {
+	l.skipSpaces()
+	start := l.pos
+	if l.off >= len(l.src) {
+		return &token{kind: tokEOF, pos: start}, nil
+	}
+
+	r := l.peekRune(0)
+	switch {
+	case r == '(':
+		l.advance()
+		return &token{kind: tokLParen, text: "(", pos: start}, nil
+	case r == ')':
+		l.advance()
+		return &token{kind: tokRParen, text: ")", pos: start}, nil
+	case r == ',':
+		l.advance()
+		return &token{kind: tokComma, text: ",", pos: start}, nil
+	case r == '"' || r == '\'':
+		return l.scanText(start)
+	case isDigit(r) || (r == '.' && isDigit(l.peekRune(1))):
+		return l.scanNumber(start)
+	case r == '-' && !l.operandEnded() && (isDigit(l.peekRune(1)) || (l.peekRune(1) == '.' && isDigit(l.peekRune(2)))):
+		return l.scanNumber(start)
+	case isIdentStart(r):
+		return l.scanIdent(start)
+	case r == '$':
+		l.advance()
+		if !isIdentStart(l.peekRune(0)) {
+			return nil, parseError(start, "expected variable name after '$'")
+		}
+		tok, err := l.scanIdent(start)
+		if err != nil {
+			return nil, err
+		}
+		tok.kind = tokVar
+		return tok, nil
+	}
+
+	rest := string(l.src[l.off:])
+	for _, op := range l.ops {
+		if isIdentStart([]rune(op)[0]) {
+			continue
+		}
+		if strings.HasPrefix(rest, op) {
+			for range []rune(op) {
+				l.advance()
+			}
+			return &token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return nil, parseError(start, "unexpected character %q", r)
+}
+
+func (l *lexer) scanText(start Position) (*token, *util.Result) {
+	quote := l.advance()
+	sb := strings.Builder{}
+	for {
+		if l.off >= len(l.src) {
+			return nil, parseError(start, "unterminated text literal")
+		}
+		r := l.advance()
+		if r == quote {
+			return &token{kind: tokText, text: sb.String(), pos: start}, nil
+		}
+		if r != '\\' {
+			sb.WriteRune(r)
+			continue
+		}
+		if l.off >= len(l.src) {
+			return nil, parseError(start, "unterminated text literal")
+		}
+		escPos := l.pos
+		switch e := l.advance(); e {
+		case 'n':
+			sb.WriteRune('\n')
+		case 't':
+			sb.WriteRune('\t')
+		case 'r':
+			sb.WriteRune('\r')
+		case '\\', '"', '\'':
+			sb.WriteRune(e)
+		default:
+			return nil, parseError(escPos, "unknown escape sequence '\\%c'", e)
+		}
+	}
+}
+
+func (l *lexer) scanNumber(start Position) (*token, *util.Result) {
+	sb := strings.Builder{}
+	if l.peekRune(0) == '-' {
+		sb.WriteRune(l.advance())
+	}
+	for isDigit(l.peekRune(0)) {
+		sb.WriteRune(l.advance())
+	}
+	if l.peekRune(0) == '.' {
+		sb.WriteRune(l.advance())
+		for isDigit(l.peekRune(0)) {
+			sb.WriteRune(l.advance())
+		}
+	}
+	if r := l.peekRune(0); r == 'e' || r == 'E' {
+		n := 1
+		if s := l.peekRune(1); s == '+' || s == '-' {
+			n = 2
+		}
+		if isDigit(l.peekRune(n)) {
+			for i := 0; i < n; i++ {
+				sb.WriteRune(l.advance())
+			}
+			for isDigit(l.peekRune(0)) {
+				sb.WriteRune(l.advance())
+			}
+		}
+	}
+	if isIdentStart(l.peekRune(0)) {
+		return nil, parseError(l.pos, "unexpected character %q after number", l.peekRune(0))
+	}
+	return &token{kind: tokNumber, text: sb.String(), pos: start}, nil
+}
+
+// scanIdent reads an identifier; dots join path segments as in `row.price`.
+func (l *lexer) scanIdent(start Position) (*token, *util.Result) {
+	sb := strings.Builder{}
+	for {
+		for isIdentPart(l.peekRune(0)) {
+			sb.WriteRune(l.advance())
+		}
+		if l.peekRune(0) != '.' || !isIdentStart(l.peekRune(1)) {
+			break
+		}
+		sb.WriteRune(l.advance())
+	}
+	text := l.extendWordOp(sb.String())
+	if isOperator(text) {
+		return &token{kind: tokOp, text: text, pos: start}, nil
+	}
+	return &token{kind: tokIdent, text: text, pos: start}, nil
+}
+
+// extendWordOp joins the following words onto word while they spell the
+// start of a multi-word operator such as `not in`; otherwise it leaves the
+// input untouched.
+func (l *lexer) extendWordOp(word string) string {
+	for l.hasOpPrefix(word + " ") {
+		off, pos := l.off, l.pos
+		l.skipSpaces()
+		sb := strings.Builder{}
+		for isIdentPart(l.peekRune(0)) {
+			sb.WriteRune(l.advance())
+		}
+		next := word + " " + sb.String()
+		if sb.Len() == 0 || !(isOperator(next) || l.hasOpPrefix(next+" ")) {
+			l.off, l.pos = off, pos
+			break
+		}
+		word = next
+	}
+	return word
+}
+
+func (l *lexer) hasOpPrefix(prefix string) bool {
+	for _, op := range l.ops {
+		if strings.HasPrefix(op, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,175 @@
+package fx
+
+import (
+	"fmt"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+//InOp      Operator = "in"
+//NotInOp   Operator = "not in"
+//BetweenOp Operator = "between"
+
+func init() {
+	RegisterNewBinOpExpCreator(InOp, NewInExp)
+	RegisterNewBinOpExpCreator(NotInOp, NewNotInExp)
+	RegisterNewBinOpExpCreator(BetweenOp, NewBetweenExp)
+}
+
+// ListExp is a list literal such as `("failed", "timeout")`. It is only
+// meaningful as the right hand side of in, not in and between; evaluating it
+// on its own is an error.
+type ListExp struct {
+	Items []Expression
+	Pos   Position
+}
+
+func (e *ListExp) setPos(pos Position) {
+	e.Pos = pos
+}
+
+func (e ListExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e ListExp) Eval(env Env) *Value {
+	return Error(util.MsgError("ListExp", "a list is not a value"))
+}
+
+func NewListExp(items ...Expression) Expression {
+	return &ListExp{Items: items}
+}
+
+// listItems returns the items of a ListExp, or e itself as a one-item list.
+func listItems(e Expression) []Expression {
+	if l, ok := e.(*ListExp); ok {
+		return l.Items
+	}
+	return []Expression{e}
+}
+
+// inList reports whether the value of lh equals any item of rh. As in SQL,
+// the result is unknown when lh is null, or when there is no match but some
+// item is null.
+func inList(lh, rh Expression, env Env) *Value {
+	lv := lh.Eval(env)
+	if lv.HasError() {
+		return Error(lv.Error.With("LH Error"))
+	}
+	if lv.IsNull() {
+		return Nil()
+	}
+	res := False()
+	for i, item := range listItems(rh) {
+		iv := item.Eval(env)
+		if iv.HasError() {
+			return Error(iv.Error.With(fmt.Sprintf("Item %d Error", i)))
+		}
+		eq := coercionOf(env).Equal(lv, iv)
+		if eq.True() {
+			return True()
+		}
+		if eq.IsNull() {
+			res = Nil()
+		}
+	}
+	return res
+}
+
+type InExp struct {
+	BinOpExp
+}
+
+func (e InExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e InExp) Eval(env Env) *Value {
+	return inList(e.Lh, e.Rh, env)
+}
+
+func NewInExp(lh, rh Expression) Expression {
+	return &InExp{
+		BinOpExp{
+			Op: InOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type NotInExp struct {
+	BinOpExp
+}
+
+func (e NotInExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e NotInExp) Eval(env Env) *Value {
+	in := inList(e.Lh, e.Rh, env)
+	if in.HasError() {
+		return in
+	}
+	return in.Not()
+}
+
+func NewNotInExp(lh, rh Expression) Expression {
+	return &NotInExp{
+		BinOpExp{
+			Op: NotInOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+// BetweenExp tests lo <= Lh <= hi, where Rh is the two-item list (lo, hi).
+// In source text it is written `x between lo and hi`.
+type BetweenExp struct {
+	BinOpExp
+}
+
+func (e BetweenExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e BetweenExp) Eval(env Env) *Value {
+	bounds := listItems(e.Rh)
+	if len(bounds) != 2 {
+		return Error(util.MsgError("BetweenExpOperands", "RH must be a list of two bounds"))
+	}
+	v := e.Lh.Eval(env)
+	if v.HasError() {
+		return Error(v.Error.With("LH Error"))
+	}
+	lo := bounds[0].Eval(env)
+	if lo.HasError() {
+		return Error(lo.Error.With("Lower Bound Error"))
+	}
+	hi := bounds[1].Eval(env)
+	if hi.HasError() {
+		return Error(hi.Error.With("Upper Bound Error"))
+	}
+
+	c := coercionOf(env)
+	below := c.Less(v, lo)
+	if below.HasError() {
+		return below
+	}
+	above := c.Less(hi, v)
+	if above.HasError() {
+		return above
+	}
+	return below.Not().And(above.Not())
+}
+
+func NewBetweenExp(lh, rh Expression) Expression {
+	return &BetweenExp{
+		BinOpExp{
+			Op: BetweenOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
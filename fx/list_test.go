@@ -0,0 +1,150 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestInExp_Calc(t *testing.T) {
+	tests := []struct {
+		name    string
+		lh      Expression
+		rh      Expression
+		want    bool
+		wantErr bool
+	}{
+		{name: "text in list", lh: Text("failed"), rh: NewListExp(Text("failed"), Text("timeout")), want: true},
+		{name: "text not in list", lh: Text("ok"), rh: NewListExp(Text("failed"), Text("timeout")), want: false},
+		{name: "number in list", lh: Number(2), rh: NewListExp(Number(1), Number(2)), want: true},
+		{name: "number is not text", lh: Number(1), rh: NewListExp(Text("1")), want: false},
+		{name: "scalar rh", lh: Number(1), rh: Number(1), want: true},
+		{name: "empty list", lh: Number(1), rh: NewListExp(), want: false},
+		{name: "lh error", lh: Error(util.MsgError("test", "error")), rh: NewListExp(Number(1)), wantErr: true},
+		{name: "item error", lh: Number(2), rh: NewListExp(Number(1), Error(util.MsgError("test", "error"))), wantErr: true},
+		{name: "match before item error", lh: Number(1), rh: NewListExp(Number(1), Error(util.MsgError("test", "error"))), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewInExp(tt.lh, tt.rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("InExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("InExp.Calc() = %v, want %v", got, tt.want)
+			}
+
+			got = NewNotInExp(tt.lh, tt.rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("NotInExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() == tt.want {
+				t.Errorf("NotInExp.Calc() = %v, want %v", got, !tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenExp_Calc(t *testing.T) {
+	tests := []struct {
+		name    string
+		lh      Expression
+		rh      Expression
+		want    bool
+		wantErr bool
+	}{
+		{name: "inside", lh: Number(5), rh: NewListExp(Number(1), Number(10)), want: true},
+		{name: "lower bound inclusive", lh: Number(1), rh: NewListExp(Number(1), Number(10)), want: true},
+		{name: "upper bound inclusive", lh: Number(10), rh: NewListExp(Number(1), Number(10)), want: true},
+		{name: "below", lh: Number(0), rh: NewListExp(Number(1), Number(10)), want: false},
+		{name: "above", lh: Number(11), rh: NewListExp(Number(1), Number(10)), want: false},
+		{name: "text", lh: Text("m"), rh: NewListExp(Text("a"), Text("z")), want: true},
+		{name: "mixed types", lh: Number(5), rh: NewListExp(Text("a"), Text("z")), wantErr: true},
+		{name: "one bound", lh: Number(5), rh: NewListExp(Number(1)), wantErr: true},
+		{name: "lh error", lh: Error(util.MsgError("test", "error")), rh: NewListExp(Number(1), Number(2)), wantErr: true},
+		{name: "bound error", lh: Number(1), rh: NewListExp(Number(1), Error(util.MsgError("test", "error"))), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewBetweenExp(tt.lh, tt.rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("BetweenExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("BetweenExp.Calc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListExp_Calc(t *testing.T) {
+	if got := NewListExp(Number(1)).Calc(); !got.HasError() {
+		t.Errorf("ListExp.Calc() = %v, want error", util.JsonStr(got))
+	}
+}
+
+func TestParse_ListOperators(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		env     MapEnv
+		want    *Value
+		wantErr bool
+	}{
+		{name: "in", input: `status in ("failed", "timeout")`, env: MapEnv{"status": "timeout"}, want: True()},
+		{name: "not in", input: `status not in ("failed", "timeout")`, env: MapEnv{"status": "timeout"}, want: False()},
+		{name: "not in spaced", input: "status  not\n in ('failed',)", env: MapEnv{"status": "ok"}, want: True()},
+		{name: "single item list", input: `1 in (1,)`, want: True()},
+		{name: "parenthesised scalar", input: `1 in (1)`, want: True()},
+		{name: "in with expressions", input: `2 * 3 in (1 + 1, 2 + 4)`, want: True()},
+		{name: "not as variable", input: `not == 1`, env: MapEnv{"not": 1}, want: True()},
+		{name: "between", input: `amount between 10 and 20`, env: MapEnv{"amount": 15}, want: True()},
+		{name: "between arithmetic bounds", input: `amount between 5 * 2 and 10 + 10`, env: MapEnv{"amount": 21}, want: False()},
+		{name: "between in logic", input: `x between 1 and 3 && y between 4 and 6`, env: MapEnv{"x": 2, "y": 5}, want: True()},
+		{name: "match", input: `job =~ "^nightly-"`, env: MapEnv{"job": "nightly-reload"}, want: True()},
+		{name: "not match", input: `job !~ "^nightly-"`, env: MapEnv{"job": "nightly-reload"}, want: False()},
+		{name: "not still works", input: `!(1 in (2, 3))`, want: True()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Parse(%q).Eval() error = %v, wantErr %v", tt.input, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestParse_ListErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos Position
+	}{
+		{name: "between without and", input: "x between 1 or 2", wantPos: Position{Line: 1, Column: 13}},
+		{name: "between missing hi", input: "x between 1 and", wantPos: Position{Line: 1, Column: 16}},
+		{name: "unclosed list", input: "x in (1, 2", wantPos: Position{Line: 1, Column: 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) should fail", tt.input)
+			}
+			if pos, _ := err.Result.(Position); pos != tt.wantPos {
+				t.Errorf("Parse(%q) error at %v, want %v (%v)", tt.input, pos, tt.wantPos, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package fx
+
+import "github.com/soderasen-au/go-common/util"
+
+//AndOp Operator = "&&"
+//OrOp  Operator = "||"
+//NotOp Operator = "!"
+
+func init() {
+	RegisterNewBinOpExpCreator(AndOp, NewAndExp)
+	RegisterNewBinOpExpCreator(OrOp, NewOrExp)
+	RegisterNewUnaryOpExpCreator(NotOp, NewNotExp)
+}
+
+// logicOperand evaluates one side of a logical expression and makes sure it
+// is a bool or null, wrapping any failure with side as context.
+func logicOperand(e Expression, env Env, side string) *Value {
+	v := e.Eval(env)
+	if v.HasError() {
+		return Error(v.Error.With(side + " Error"))
+	}
+	if !v.IsBool() && !v.IsNull() {
+		return Error(util.MsgError("LogicExpOperands", side+" is not bool"))
+	}
+	return v
+}
+
+type AndExp struct {
+	BinOpExp
+}
+
+func (e AndExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+// Eval does not evaluate Rh when Lh is false. A null operand is unknown, see
+// Value.And.
+func (e AndExp) Eval(env Env) *Value {
+	lv := logicOperand(e.Lh, env, "LH")
+	if lv.HasError() || lv.False() {
+		return lv
+	}
+	rv := logicOperand(e.Rh, env, "RH")
+	if rv.HasError() {
+		return rv
+	}
+	return lv.And(rv)
+}
+
+func NewAndExp(lh, rh Expression) Expression {
+	return &AndExp{
+		BinOpExp{
+			Op: AndOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type OrExp struct {
+	BinOpExp
+}
+
+func (e OrExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+// Eval does not evaluate Rh when Lh is true. A null operand is unknown, see
+// Value.Or.
+func (e OrExp) Eval(env Env) *Value {
+	lv := logicOperand(e.Lh, env, "LH")
+	if lv.HasError() || lv.True() {
+		return lv
+	}
+	rv := logicOperand(e.Rh, env, "RH")
+	if rv.HasError() {
+		return rv
+	}
+	return lv.Or(rv)
+}
+
+func NewOrExp(lh, rh Expression) Expression {
+	return &OrExp{
+		BinOpExp{
+			Op: OrOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type NotExp struct {
+	UnaryOpExp
+}
+
+func (e NotExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e NotExp) Eval(env Env) *Value {
+	v := logicOperand(e.X, env, "Operand")
+	if v.HasError() {
+		return v
+	}
+	return v.Not()
+}
+
+func NewNotExp(x Expression) Expression {
+	return &NotExp{
+		UnaryOpExp{
+			Op: NotOp,
+			X:  x,
+		},
+	}
+}
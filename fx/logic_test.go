@@ -0,0 +1,174 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// countingExp records how many times it was evaluated.
+type countingExp struct {
+	v     *Value
+	calls int
+}
+
+func (e *countingExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e *countingExp) Eval(env Env) *Value {
+	e.calls++
+	return e.v
+}
+
+func TestAndExp_Calc(t *testing.T) {
+	tests := []struct {
+		name      string
+		lh        *Value
+		rh        *Value
+		want      bool
+		wantErr   bool
+		wantRhRun bool
+	}{
+		{name: "true && true", lh: True(), rh: True(), want: true, wantRhRun: true},
+		{name: "true && false", lh: True(), rh: False(), want: false, wantRhRun: true},
+		{name: "false && true", lh: False(), rh: True(), want: false, wantRhRun: false},
+		{name: "false && error", lh: False(), rh: Error(util.MsgError("test", "error")), want: false, wantRhRun: false},
+		{name: "true && error", lh: True(), rh: Error(util.MsgError("test", "error")), wantErr: true, wantRhRun: true},
+		{name: "error && true", lh: Error(util.MsgError("test", "error")), rh: True(), wantErr: true, wantRhRun: false},
+		{name: "number && true", lh: Number(1), rh: True(), wantErr: true, wantRhRun: false},
+		{name: "true && text", lh: True(), rh: Text("true"), wantErr: true, wantRhRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &countingExp{v: tt.rh}
+			got := NewAndExp(tt.lh, rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("AndExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("AndExp.Calc() = %v, want %v", got, tt.want)
+			}
+			if (rh.calls > 0) != tt.wantRhRun {
+				t.Errorf("AndExp.Calc() evaluated rh %d times, wantRhRun %v", rh.calls, tt.wantRhRun)
+			}
+		})
+	}
+}
+
+func TestOrExp_Calc(t *testing.T) {
+	tests := []struct {
+		name      string
+		lh        *Value
+		rh        *Value
+		want      bool
+		wantErr   bool
+		wantRhRun bool
+	}{
+		{name: "true || false", lh: True(), rh: False(), want: true, wantRhRun: false},
+		{name: "true || error", lh: True(), rh: Error(util.MsgError("test", "error")), want: true, wantRhRun: false},
+		{name: "false || true", lh: False(), rh: True(), want: true, wantRhRun: true},
+		{name: "false || false", lh: False(), rh: False(), want: false, wantRhRun: true},
+		{name: "false || error", lh: False(), rh: Error(util.MsgError("test", "error")), wantErr: true, wantRhRun: true},
+		{name: "error || true", lh: Error(util.MsgError("test", "error")), rh: True(), wantErr: true, wantRhRun: false},
+		{name: "text || true", lh: Text("x"), rh: True(), wantErr: true, wantRhRun: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &countingExp{v: tt.rh}
+			got := NewOrExp(tt.lh, rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("OrExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("OrExp.Calc() = %v, want %v", got, tt.want)
+			}
+			if (rh.calls > 0) != tt.wantRhRun {
+				t.Errorf("OrExp.Calc() evaluated rh %d times, wantRhRun %v", rh.calls, tt.wantRhRun)
+			}
+		})
+	}
+}
+
+func TestNotExp_Calc(t *testing.T) {
+	tests := []struct {
+		name    string
+		x       *Value
+		want    bool
+		wantErr bool
+	}{
+		{name: "!true", x: True(), want: false},
+		{name: "!false", x: False(), want: true},
+		{name: "!number", x: Number(0), wantErr: true},
+		{name: "!error", x: Error(util.MsgError("test", "error")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewNotExp(tt.x).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("NotExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("NotExp.Calc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewUnaryOpExp(t *testing.T) {
+	exp, err := NewUnaryOpExp(NotOp, True())
+	if err != nil {
+		t.Fatalf("NewUnaryOpExp() error = %v", err)
+	}
+	if !exp.Calc().False() {
+		t.Errorf("NewUnaryOpExp(!true).Calc() = %v, want false", exp.Calc())
+	}
+	if _, err := NewUnaryOpExp(Operator("???"), True()); err == nil {
+		t.Error("NewUnaryOpExp() with unknown operator should fail")
+	}
+}
+
+func TestParse_Logic(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		env   MapEnv
+		want  *Value
+	}{
+		{name: "and", input: "1 < 2 && 2 < 3", want: True()},
+		{name: "or", input: "1 > 2 || 2 < 3", want: True()},
+		{name: "and binds tighter than or", input: "true || false && false", want: True()},
+		{name: "parentheses override", input: "(true || false) && false", want: False()},
+		{name: "not", input: "!(1 < 2)", want: False()},
+		{name: "not binds tighter than relation", input: "!true == false", want: True()},
+		{name: "double not", input: "!!true", want: True()},
+		{
+			name:  "filter",
+			input: `amount >= 100 && region ~= "EU"`,
+			env:   MapEnv{"amount": 150, "region": "EU-West"},
+			want:  True(),
+		},
+		{
+			name:  "short-circuit skips undefined variable",
+			input: `amount < 100 && undefined == 1`,
+			env:   MapEnv{"amount": 150},
+			want:  False(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
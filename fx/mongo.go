@@ -0,0 +1,455 @@
+package fx
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+var (
+	mongoCmpOps = map[Operator]string{
+		LessOp:      "$lt",
+		GreaterOp:   "$gt",
+		LessEqOp:    "$lte",
+		GreaterEqOp: "$gte",
+		EqualOp:     "$eq",
+		NotEqOp:     "$ne",
+	}
+	// flippedCmpOps rewrites `lit op field` as `field op' lit`.
+	flippedCmpOps = map[Operator]Operator{
+		LessOp:      GreaterOp,
+		GreaterOp:   LessOp,
+		LessEqOp:    GreaterEqOp,
+		GreaterEqOp: LessEqOp,
+		EqualOp:     EqualOp,
+		NotEqOp:     NotEqOp,
+	}
+	negatedCmpOps = map[Operator]Operator{
+		LessOp:      GreaterEqOp,
+		GreaterOp:   LessEqOp,
+		LessEqOp:    GreaterOp,
+		GreaterEqOp: LessOp,
+		EqualOp:     NotEqOp,
+		NotEqOp:     EqualOp,
+	}
+	mongoExprOps = map[Operator]string{
+		AddOp: "$add",
+		SubOp: "$subtract",
+		MulOp: "$multiply",
+		DivOp: "$divide",
+		ModOp: "$mod",
+		AndOp: "$and",
+		OrOp:  "$or",
+	}
+)
+
+// ToMongoFilter translates e into a MongoDB query filter made of plain maps
+// and slices, ready to be passed to any driver as a bson document.
+//
+// Comparisons of a variable with a literal become field conditions such as
+// {"price": {"$gte": 100}}, and negations are pushed down to them so that,
+// as in fx, a null or missing field never matches: `x != 1` is
+// {"x": {"$nin": [1, null]}} rather than {"x": {"$ne": 1}}. Anything else,
+// such as arithmetic or comparing two fields, falls back to an aggregation
+// expression under $expr, where MongoDB orders null below every value.
+// Comparing with a null literal is an error; use `is null` instead. So is
+// a decimal literal, which plain values cannot hold exactly.
+func ToMongoFilter(e Expression) (map[string]any, *util.Result) {
+	f, err := mongoQuery(e, false)
+	if err != nil {
+		return nil, err.With("ToMongoFilter")
+	}
+	return f, nil
+}
+
+func unwrapExpr(e Expression) Expression {
+	if x, ok := e.(Expr); ok {
+		return unwrapExpr(x.Expression)
+	}
+	return e
+}
+
+// mongoQuery returns the filter matching e, or matching !e if neg is set.
+func mongoQuery(e Expression, neg bool) (map[string]any, *util.Result) {
+	switch x := unwrapExpr(e).(type) {
+	case *VarExp:
+		return map[string]any{x.Name: !neg}, nil
+	case *Value:
+		if x.IsBool() {
+			return map[string]any{"$expr": x.True() != neg}, nil
+		}
+	case BinaryExpression:
+		b := x.BinOp()
+		switch b.Op {
+		case AndOp, OrOp:
+			return mongoLogic(b, neg)
+		case InOp, NotInOp:
+			if f, ok, err := mongoIn(b, neg); ok || err != nil {
+				return f, err
+			}
+		case BetweenOp:
+			if f, ok, err := mongoBetween(b, neg); ok || err != nil {
+				return f, err
+			}
+		case IncludeOp, MatchOp, NotMatchOp:
+			if f, ok, err := mongoRegex(b, neg); ok || err != nil {
+				return f, err
+			}
+		default:
+			if _, ok := mongoCmpOps[b.Op]; ok {
+				if f, ok, err := mongoCmp(b, neg); ok || err != nil {
+					return f, err
+				}
+			}
+		}
+	case UnaryExpression:
+		u := x.UnaryOp()
+		switch u.Op {
+		case NotOp:
+			return mongoQuery(u.X, !neg)
+		case IsNullOp, IsNotNullOp:
+			if v, ok := unwrapExpr(u.X).(*VarExp); ok {
+				if (u.Op == IsNullOp) != neg {
+					return map[string]any{v.Name: nil}, nil
+				}
+				return map[string]any{v.Name: map[string]any{"$ne": nil}}, nil
+			}
+		}
+	}
+
+	exp, err := mongoExpr(e)
+	if err != nil {
+		return nil, err
+	}
+	if neg {
+		exp = map[string]any{"$not": []any{exp}}
+	}
+	return map[string]any{"$expr": exp}, nil
+}
+
+// mongoLogic flattens nested && or || into one $and or $or; negation swaps
+// them by De Morgan's laws.
+func mongoLogic(b BinOpExp, neg bool) (map[string]any, *util.Result) {
+	key := "$and"
+	if (b.Op == OrOp) != neg {
+		key = "$or"
+	}
+	terms := make([]any, 0)
+	for _, t := range logicTerms(b.Op, b.Lh, b.Rh) {
+		f, err := mongoQuery(t, neg)
+		if err != nil {
+			return nil, err.With(key)
+		}
+		terms = append(terms, f)
+	}
+	return map[string]any{key: terms}, nil
+}
+
+func logicTerms(op Operator, es ...Expression) []Expression {
+	terms := make([]Expression, 0, len(es))
+	for _, e := range es {
+		if b, ok := unwrapExpr(e).(BinaryExpression); ok && b.BinOp().Op == op {
+			terms = append(terms, logicTerms(op, b.BinOp().Lh, b.BinOp().Rh)...)
+			continue
+		}
+		terms = append(terms, e)
+	}
+	return terms
+}
+
+// fieldAndLiteral matches `field op literal`, or `literal op field` with
+// flipped set. ok is false for any other shape.
+func fieldAndLiteral(lh, rh Expression) (field string, lit *Value, flipped bool, ok bool) {
+	lh, rh = unwrapExpr(lh), unwrapExpr(rh)
+	if v, isVar := lh.(*VarExp); isVar {
+		if l, isLit := rh.(*Value); isLit {
+			return v.Name, l, false, true
+		}
+	}
+	if v, isVar := rh.(*VarExp); isVar {
+		if l, isLit := lh.(*Value); isLit {
+			return v.Name, l, true, true
+		}
+	}
+	return "", nil, false, false
+}
+
+// mongoLiteral converts a literal Value into the type a driver encodes.
+func mongoLiteral(v *Value) (any, *util.Result) {
+	switch {
+	case v.HasError():
+		return nil, v.Error.With("Literal")
+	case v.IsNull():
+		return nil, nil
+	case v.IsBool():
+		return v.True(), nil
+	case v.IsTime():
+		return *v.Time, nil
+	case v.Decimal != nil:
+		return nil, util.MsgError("Literal", "decimal "+v.Decimal.String()+" has no exact plain value; use a number")
+	case v.IsNumeric:
+		return util.MaybeNil(v.Number), nil
+	}
+	return util.MaybeNil(v.Text), nil
+}
+
+func nullComparisonError(op Operator) *util.Result {
+	return util.MsgError("Mongo", fmt.Sprintf("%s null is always unknown; use is null", op))
+}
+
+func mongoCmp(b BinOpExp, neg bool) (map[string]any, bool, *util.Result) {
+	field, lit, flipped, ok := fieldAndLiteral(b.Lh, b.Rh)
+	if !ok {
+		return nil, false, nil
+	}
+	if lit.IsNull() {
+		return nil, true, nullComparisonError(b.Op)
+	}
+	val, err := mongoLiteral(lit)
+	if err != nil {
+		return nil, true, err
+	}
+	op := b.Op
+	if flipped {
+		op = flippedCmpOps[op]
+	}
+	if neg {
+		op = negatedCmpOps[op]
+	}
+	if op == NotEqOp {
+		return map[string]any{field: map[string]any{"$nin": []any{val, nil}}}, true, nil
+	}
+	return map[string]any{field: map[string]any{mongoCmpOps[op]: val}}, true, nil
+}
+
+// mongoIn handles `field in (literals)`. As in fx, a null item never matches
+// and makes not in unknown for every other value.
+func mongoIn(b BinOpExp, neg bool) (map[string]any, bool, *util.Result) {
+	v, ok := unwrapExpr(b.Lh).(*VarExp)
+	if !ok {
+		return nil, false, nil
+	}
+	vals := make([]any, 0)
+	hasNull := false
+	for _, item := range listItems(b.Rh) {
+		lit, ok := unwrapExpr(item).(*Value)
+		if !ok {
+			return nil, false, nil
+		}
+		if lit.IsNull() {
+			hasNull = true
+			continue
+		}
+		val, err := mongoLiteral(lit)
+		if err != nil {
+			return nil, true, err
+		}
+		vals = append(vals, val)
+	}
+	if (b.Op == NotInOp) == neg {
+		return map[string]any{v.Name: map[string]any{"$in": vals}}, true, nil
+	}
+	if hasNull {
+		return map[string]any{v.Name: map[string]any{"$in": []any{}}}, true, nil
+	}
+	return map[string]any{v.Name: map[string]any{"$nin": append(vals, nil)}}, true, nil
+}
+
+func mongoBetween(b BinOpExp, neg bool) (map[string]any, bool, *util.Result) {
+	v, ok := unwrapExpr(b.Lh).(*VarExp)
+	bounds := listItems(b.Rh)
+	if !ok || len(bounds) != 2 {
+		return nil, false, nil
+	}
+	vals := make([]any, 2)
+	for i, bound := range bounds {
+		lit, ok := unwrapExpr(bound).(*Value)
+		if !ok {
+			return nil, false, nil
+		}
+		if lit.IsNull() {
+			return nil, true, nullComparisonError(b.Op)
+		}
+		val, err := mongoLiteral(lit)
+		if err != nil {
+			return nil, true, err
+		}
+		vals[i] = val
+	}
+	if neg {
+		return map[string]any{"$or": []any{
+			map[string]any{v.Name: map[string]any{"$lt": vals[0]}},
+			map[string]any{v.Name: map[string]any{"$gt": vals[1]}},
+		}}, true, nil
+	}
+	return map[string]any{v.Name: map[string]any{"$gte": vals[0], "$lte": vals[1]}}, true, nil
+}
+
+// mongoRegex handles `field ~= "text"`, `field =~ "re"` and `field !~ "re"`.
+func mongoRegex(b BinOpExp, neg bool) (map[string]any, bool, *util.Result) {
+	v, ok := unwrapExpr(b.Lh).(*VarExp)
+	if !ok {
+		return nil, false, nil
+	}
+	lit, ok := unwrapExpr(b.Rh).(*Value)
+	if !ok || lit.IsNumeric || lit.Text == nil {
+		return nil, false, nil
+	}
+	pattern := *lit.Text
+	if b.Op == IncludeOp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if (b.Op == NotMatchOp) == neg {
+		return map[string]any{v.Name: map[string]any{"$regex": pattern}}, true, nil
+	}
+	return map[string]any{v.Name: map[string]any{
+		"$not": map[string]any{"$regex": pattern},
+		"$ne":  nil,
+	}}, true, nil
+}
+
+// mongoExpr translates e into an aggregation expression.
+func mongoExpr(e Expression) (any, *util.Result) {
+	switch x := unwrapExpr(e).(type) {
+	case nil:
+		return nil, util.MsgError("Mongo", "nil expression")
+	case *Value:
+		val, err := mongoLiteral(x)
+		if err != nil {
+			return nil, err
+		}
+		// text starting with '$' would be read as a field path
+		if s, ok := val.(string); ok && strings.HasPrefix(s, "$") {
+			return map[string]any{"$literal": s}, nil
+		}
+		return val, nil
+	case *VarExp:
+		return "$" + x.Name, nil
+	case *CallExp:
+		return mongoCall(x)
+	case *ListExp:
+		return nil, util.MsgError("Mongo", "a list is only allowed after in, not in and between")
+	case BinaryExpression:
+		return mongoExprBinOp(x.BinOp())
+	case UnaryExpression:
+		return mongoExprUnaryOp(x.UnaryOp())
+	}
+	return nil, util.MsgError("Mongo", fmt.Sprintf("unsupported expression type %T", e))
+}
+
+func mongoExprs(es ...Expression) ([]any, *util.Result) {
+	out := make([]any, len(es))
+	for i, e := range es {
+		v, err := mongoExpr(e)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func mongoCall(e *CallExp) (any, *util.Result) {
+	args, err := mongoExprs(e.Args...)
+	if err != nil {
+		return nil, err.With(e.Name)
+	}
+	switch e.Name {
+	case "lower":
+		return map[string]any{"$toLower": args[0]}, nil
+	case "upper":
+		return map[string]any{"$toUpper": args[0]}, nil
+	case "trim":
+		return map[string]any{"$trim": map[string]any{"input": args[0]}}, nil
+	case "len":
+		return map[string]any{"$strLenCP": args[0]}, nil
+	case "abs":
+		return map[string]any{"$abs": args[0]}, nil
+	case "floor":
+		return map[string]any{"$floor": args[0]}, nil
+	case "ceil":
+		return map[string]any{"$ceil": args[0]}, nil
+	case "coalesce":
+		if len(args) == 0 {
+			return nil, nil
+		}
+		if len(args) == 1 {
+			return args[0], nil
+		}
+		return map[string]any{"$ifNull": args}, nil
+	}
+	return nil, util.MsgError("Mongo", "no MongoDB equivalent for function: "+e.Name)
+}
+
+func mongoExprBinOp(b BinOpExp) (any, *util.Result) {
+	lh, err := mongoExpr(b.Lh)
+	if err != nil {
+		return nil, err.With("LH")
+	}
+	switch b.Op {
+	case InOp, NotInOp:
+		items, err := mongoExprs(listItems(b.Rh)...)
+		if err != nil {
+			return nil, err.With("RH")
+		}
+		in := map[string]any{"$in": []any{lh, items}}
+		if b.Op == NotInOp {
+			return map[string]any{"$not": []any{in}}, nil
+		}
+		return in, nil
+	case BetweenOp:
+		bounds := listItems(b.Rh)
+		if len(bounds) != 2 {
+			return nil, util.MsgError("Mongo", "between needs a list of two bounds")
+		}
+		bv, err := mongoExprs(bounds...)
+		if err != nil {
+			return nil, err.With("Bounds")
+		}
+		return map[string]any{"$and": []any{
+			map[string]any{"$gte": []any{lh, bv[0]}},
+			map[string]any{"$lte": []any{lh, bv[1]}},
+		}}, nil
+	}
+
+	rh, err := mongoExpr(b.Rh)
+	if err != nil {
+		return nil, err.With("RH")
+	}
+	switch b.Op {
+	case IncludeOp:
+		return map[string]any{"$gte": []any{map[string]any{"$indexOfCP": []any{lh, rh}}, 0}}, nil
+	case MatchOp:
+		return map[string]any{"$regexMatch": map[string]any{"input": lh, "regex": rh}}, nil
+	case NotMatchOp:
+		return map[string]any{"$not": []any{map[string]any{"$regexMatch": map[string]any{"input": lh, "regex": rh}}}}, nil
+	}
+	if op, ok := mongoCmpOps[b.Op]; ok {
+		return map[string]any{op: []any{lh, rh}}, nil
+	}
+	if op, ok := mongoExprOps[b.Op]; ok {
+		return map[string]any{op: []any{lh, rh}}, nil
+	}
+	return nil, util.MsgError("Mongo", "no MongoDB equivalent for operator: "+string(b.Op))
+}
+
+func mongoExprUnaryOp(u UnaryOpExp) (any, *util.Result) {
+	x, err := mongoExpr(u.X)
+	if err != nil {
+		return nil, err.With("Operand")
+	}
+	switch u.Op {
+	case NotOp:
+		return map[string]any{"$not": []any{x}}, nil
+	case NegOp:
+		return map[string]any{"$multiply": []any{-1, x}}, nil
+	// a missing field sorts below null, so both are <= null
+	case IsNullOp:
+		return map[string]any{"$lte": []any{x, nil}}, nil
+	case IsNotNullOp:
+		return map[string]any{"$gt": []any{x, nil}}, nil
+	}
+	return nil, util.MsgError("Mongo", "no MongoDB equivalent for operator: "+string(u.Op))
+}
@@ -0,0 +1,142 @@
+package fx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sameJSON reports whether got encodes to the same JSON as the golden text.
+func sameJSON(t *testing.T, got any, golden string) bool {
+	t.Helper()
+	var want any
+	if err := json.Unmarshal([]byte(golden), &want); err != nil {
+		t.Fatalf("invalid golden JSON %s: %v", golden, err)
+	}
+	gb, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	wb, _ := json.Marshal(want)
+	return string(gb) == string(wb)
+}
+
+func TestToMongoFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "less", input: `price < 10`, want: `{"price": {"$lt": 10}}`},
+		{name: "greater", input: `price > 10`, want: `{"price": {"$gt": 10}}`},
+		{name: "less equal", input: `price <= 10`, want: `{"price": {"$lte": 10}}`},
+		{name: "greater equal", input: `price >= 10`, want: `{"price": {"$gte": 10}}`},
+		{name: "equal", input: `name == "bob"`, want: `{"name": {"$eq": "bob"}}`},
+		{name: "not equal excludes null", input: `name != "bob"`, want: `{"name": {"$nin": ["bob", null]}}`},
+		{name: "flipped", input: `10 < price`, want: `{"price": {"$gt": 10}}`},
+		{name: "dotted field", input: `row.price >= 1`, want: `{"row.price": {"$gte": 1}}`},
+		{name: "bool field", input: `active && !deleted`, want: `{"$and": [{"active": true}, {"deleted": false}]}`},
+		{name: "bool literal", input: `active == true`, want: `{"active": {"$eq": true}}`},
+		{name: "and flattened", input: `a > 1 && b < 2 && c == 3`, want: `{"$and": [{"a": {"$gt": 1}}, {"b": {"$lt": 2}}, {"c": {"$eq": 3}}]}`},
+		{name: "or of and", input: `a > 1 || b < 2 && c == 3`, want: `{"$or": [{"a": {"$gt": 1}}, {"$and": [{"b": {"$lt": 2}}, {"c": {"$eq": 3}}]}]}`},
+		{name: "not pushed down", input: `!(a > 1 || b == 2)`, want: `{"$and": [{"a": {"$lte": 1}}, {"b": {"$nin": [2, null]}}]}`},
+		{name: "double not", input: `!!(a < 1)`, want: `{"a": {"$lt": 1}}`},
+		{name: "include", input: `msg ~= "a.b"`, want: `{"msg": {"$regex": "a\\.b"}}`},
+		{name: "match", input: `code =~ "^E[0-9]+"`, want: `{"code": {"$regex": "^E[0-9]+"}}`},
+		{name: "not match", input: `code !~ "^E"`, want: `{"code": {"$not": {"$regex": "^E"}, "$ne": null}}`},
+		{name: "not include", input: `!(msg ~= "x")`, want: `{"msg": {"$not": {"$regex": "x"}, "$ne": null}}`},
+		{name: "in", input: `status in ("failed", "timeout")`, want: `{"status": {"$in": ["failed", "timeout"]}}`},
+		{name: "in drops null", input: `status in ("failed", null)`, want: `{"status": {"$in": ["failed"]}}`},
+		{name: "not in", input: `status not in ("ok",)`, want: `{"status": {"$nin": ["ok", null]}}`},
+		{name: "not in with null", input: `status not in ("ok", null)`, want: `{"status": {"$in": []}}`},
+		{name: "not of not in", input: `!(status not in ("ok",))`, want: `{"status": {"$in": ["ok"]}}`},
+		{name: "between", input: `x between 1 and 10`, want: `{"x": {"$gte": 1, "$lte": 10}}`},
+		{name: "not between", input: `!(x between 1 and 10)`, want: `{"$or": [{"x": {"$lt": 1}}, {"x": {"$gt": 10}}]}`},
+		{name: "is null", input: `x is null`, want: `{"x": null}`},
+		{name: "is not null", input: `x is not null`, want: `{"x": {"$ne": null}}`},
+		{name: "not is null", input: `!(x is null)`, want: `{"x": {"$ne": null}}`},
+		{name: "two fields", input: `a < b`, want: `{"$expr": {"$lt": ["$a", "$b"]}}`},
+		{name: "arithmetic", input: `price * qty - 1 >= 100`, want: `{"$expr": {"$gte": [{"$subtract": [{"$multiply": ["$price", "$qty"]}, 1]}, 100]}}`},
+		{name: "neg and mod", input: `-a % 2 == 1`, want: `{"$expr": {"$eq": [{"$mod": [{"$multiply": [-1, "$a"]}, 2]}, 1]}}`},
+		{name: "divide", input: `a / 2 != 1`, want: `{"$expr": {"$ne": [{"$divide": ["$a", 2]}, 1]}}`},
+		{name: "functions", input: `lower(trim(name)) == "bob"`, want: `{"$expr": {"$eq": [{"$toLower": {"$trim": {"input": "$name"}}}, "bob"]}}`},
+		{name: "more functions", input: `len(a) + abs(b) + floor(c) + ceil(d) > upper(e)`, want: `{"$expr": {"$gt": [{"$add": [{"$add": [{"$add": [{"$strLenCP": "$a"}, {"$abs": "$b"}]}, {"$floor": "$c"}]}, {"$ceil": "$d"}]}, {"$toUpper": "$e"}]}}`},
+		{name: "coalesce", input: `coalesce(a, b, 0) > 1`, want: `{"$expr": {"$gt": [{"$ifNull": ["$a", "$b", 0]}, 1]}}`},
+		{name: "negated expr", input: `!(a < b)`, want: `{"$expr": {"$not": [{"$lt": ["$a", "$b"]}]}}`},
+		{name: "expr logic", input: `(a < b) == (c || d)`, want: `{"$expr": {"$eq": [{"$lt": ["$a", "$b"]}, {"$or": ["$c", "$d"]}]}}`},
+		{name: "expr and", input: `!a == (b && c)`, want: `{"$expr": {"$eq": [{"$not": ["$a"]}, {"$and": ["$b", "$c"]}]}}`},
+		{name: "expr in", input: `lower(s) in ("a", t)`, want: `{"$expr": {"$in": [{"$toLower": "$s"}, ["a", "$t"]]}}`},
+		{name: "expr not in", input: `s not in (t,)`, want: `{"$expr": {"$not": [{"$in": ["$s", ["$t"]]}]}}`},
+		{name: "expr between", input: `a between b and 3`, want: `{"$expr": {"$and": [{"$gte": ["$a", "$b"]}, {"$lte": ["$a", 3]}]}}`},
+		{name: "expr include", input: `a ~= b`, want: `{"$expr": {"$gte": [{"$indexOfCP": ["$a", "$b"]}, 0]}}`},
+		{name: "expr match", input: `lower(a) =~ "^x"`, want: `{"$expr": {"$regexMatch": {"input": {"$toLower": "$a"}, "regex": "^x"}}}`},
+		{name: "expr not match", input: `a !~ b`, want: `{"$expr": {"$not": [{"$regexMatch": {"input": "$a", "regex": "$b"}}]}}`},
+		{name: "expr is null", input: `lower(a) is null || (b + 1) is not null`, want: `{"$or": [{"$expr": {"$lte": [{"$toLower": "$a"}, null]}}, {"$expr": {"$gt": [{"$add": ["$b", 1]}, null]}}]}`},
+		{name: "dollar text literal", input: `a + 1 == "$b"`, want: `{"$expr": {"$eq": [{"$add": ["$a", 1]}, {"$literal": "$b"}]}}`},
+		{name: "literal predicate", input: `true || x > 1`, want: `{"$or": [{"$expr": true}, {"x": {"$gt": 1}}]}`},
+		{name: "compare with null", input: `x == null`, wantErr: true},
+		{name: "between null", input: `x between null and 1`, wantErr: true},
+		{name: "unknown function", input: `startsWith(a, "x")`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got, err := ToMongoFilter(exp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToMongoFilter(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !sameJSON(t, got, tt.want) {
+				b, _ := json.Marshal(got)
+				t.Errorf("ToMongoFilter(%q) = %s, want %s", tt.input, b, tt.want)
+			}
+		})
+	}
+
+	t.Run("decimal literal", func(t *testing.T) {
+		in, err := Parse(`price in (dec("0.1"), 2)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, exp := range []Expression{
+			Expr{NewGreaterEqExp(NewVarExp("price"), decValue("0.1"))},
+			Expr{NewEqualExp(NewAddExp(NewVarExp("price"), decValue("0.1")), Number(1))},
+			Optimize(in),
+		} {
+			if _, err := ToMongoFilter(exp); err == nil || !strings.Contains(err.Error(), "decimal 0.1") {
+				t.Errorf("ToMongoFilter(%s) error = %v, want decimal error", Format(exp), err)
+			}
+		}
+	})
+
+	t.Run("time literal", func(t *testing.T) {
+		when := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		got, err := ToMongoFilter(Expr{NewGreaterEqExp(NewVarExp("at"), Time(when))})
+		if err != nil {
+			t.Fatalf("ToMongoFilter() error = %v", err)
+		}
+		cond, _ := got["at"].(map[string]any)
+		if at, ok := cond["$gte"].(time.Time); !ok || !at.Equal(when) {
+			t.Errorf("ToMongoFilter() = %v, want time.Time bound", got)
+		}
+	})
+
+	t.Run("unsupported nodes", func(t *testing.T) {
+		exps := []Expression{
+			nil,
+			&countingExp{},
+			NewListExp(Number(1)),
+			NewLessExp(NewVarExp("a"), NewListExp(Number(1))),
+		}
+		for _, e := range exps {
+			if _, err := ToMongoFilter(e); err == nil {
+				t.Errorf("ToMongoFilter(%v) error = nil, want error", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package fx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Node is the canonical JSON/YAML form of an expression tree. Exactly one
+// group of fields is set:
+//
+//	{"op": ">=", "args": [...]}          operator, one arg for unary ops
+//	{"value": {...}}                     literal Value
+//	{"var": "price"}                     variable
+//	{"call": "lower", "args": [...]}     function call
+//	{"list": [...]}                      list literal
+type Node struct {
+	Op    Operator `json:"op,omitempty" yaml:"op,omitempty"`
+	Call  string   `json:"call,omitempty" yaml:"call,omitempty"`
+	Args  []*Node  `json:"args,omitempty" yaml:"args,omitempty"`
+	Var   string   `json:"var,omitempty" yaml:"var,omitempty"`
+	Value *Value   `json:"value,omitempty" yaml:"value,omitempty"`
+	List  []*Node  `json:"list,omitempty" yaml:"list,omitempty"`
+}
+
+// emptyListNode is the form of a list node without items, whose list the
+// omitempty of Node.List would drop, so that `x in ()` round-trips.
+type emptyListNode struct {
+	List []*Node `json:"list" yaml:"list"`
+}
+
+// plainNode is Node without its marshalers.
+type plainNode Node
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	var v interface{} = plainNode(n)
+	if n.isEmptyList() {
+		v = emptyListNode{List: n.List}
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func (n Node) MarshalYAML() (interface{}, error) {
+	if n.isEmptyList() {
+		return emptyListNode{List: n.List}, nil
+	}
+	return plainNode(n), nil
+}
+
+func (n Node) isEmptyList() bool {
+	return n.List != nil && len(n.List) == 0 && n.Op == UnkownOp && n.Call == "" && n.Var == "" && n.Value == nil
+}
+
+// ToNode converts an expression tree into its Node form. Operator
+// expressions must embed BinOpExp or UnaryOpExp.
+func ToNode(e Expression) (*Node, *util.Result) {
+	switch x := e.(type) {
+	case nil:
+		return nil, util.MsgError("ToNode", "nil expression")
+	case Expr:
+		return ToNode(x.Expression)
+	case *Value:
+		return &Node{Value: x}, nil
+	case *VarExp:
+		return &Node{Var: x.Name}, nil
+	case *CallExp:
+		args, err := toNodes(x.Args)
+		if err != nil {
+			return nil, err.With("ToNode " + x.Name)
+		}
+		return &Node{Call: x.Name, Args: args}, nil
+	case *ListExp:
+		items, err := toNodes(x.Items)
+		if err != nil {
+			return nil, err.With("ToNode list")
+		}
+		return &Node{List: items}, nil
+	case BinaryExpression:
+		b := x.BinOp()
+		args, err := toNodes([]Expression{b.Lh, b.Rh})
+		if err != nil {
+			return nil, err.With("ToNode " + string(b.Op))
+		}
+		return &Node{Op: b.Op, Args: args}, nil
+	case UnaryExpression:
+		u := x.UnaryOp()
+		args, err := toNodes([]Expression{u.X})
+		if err != nil {
+			return nil, err.With("ToNode " + string(u.Op))
+		}
+		return &Node{Op: u.Op, Args: args}, nil
+	}
+	return nil, util.MsgError("ToNode", fmt.Sprintf("unsupported expression type %T", e))
+}
+
+func toNodes(es []Expression) ([]*Node, *util.Result) {
+	nodes := make([]*Node, len(es))
+	for i, e := range es {
+		n, err := ToNode(e)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// Expression rebuilds the expression tree through the operator and function
+// registries, so a stored tree gets the same checks as parsed source text.
+func (n *Node) Expression() (Expression, *util.Result) {
+	if n == nil {
+		return nil, util.MsgError("NodeExpression", "nil node")
+	}
+	switch {
+	case n.Op != UnkownOp:
+		args, err := fromNodes(n.Args)
+		if err != nil {
+			return nil, err.With("NodeExpression " + string(n.Op))
+		}
+		switch len(args) {
+		case 1:
+			return NewUnaryOpExp(n.Op, args[0])
+		case 2:
+			return NewBinOpExp(n.Op, args[0], args[1])
+		}
+		return nil, util.MsgError("NodeExpression", fmt.Sprintf("%s needs 1 or 2 args, got %d", n.Op, len(args)))
+	case n.Call != "":
+		args, err := fromNodes(n.Args)
+		if err != nil {
+			return nil, err.With("NodeExpression " + n.Call)
+		}
+		return NewCallExp(n.Call, args...)
+	case n.Var != "":
+		return NewVarExp(n.Var), nil
+	case n.Value != nil:
+		return n.Value, nil
+	case n.List != nil:
+		items, err := fromNodes(n.List)
+		if err != nil {
+			return nil, err.With("NodeExpression list")
+		}
+		return NewListExp(items...), nil
+	}
+	return nil, util.MsgError("NodeExpression", "empty node")
+}
+
+func fromNodes(nodes []*Node) ([]Expression, *util.Result) {
+	es := make([]Expression, len(nodes))
+	for i, n := range nodes {
+		e, err := n.Expression()
+		if err != nil {
+			return nil, err
+		}
+		es[i] = e
+	}
+	return es, nil
+}
+
+// Marshal encodes an expression tree as JSON in its Node form. Operators
+// are not HTML-escaped, so stored rules stay readable.
+func Marshal(e Expression) ([]byte, *util.Result) {
+	n, err := ToNode(e)
+	if err != nil {
+		return nil, err.With("Marshal")
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if jerr := enc.Encode(n); jerr != nil {
+		return nil, util.Error("Marshal", jerr)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Unmarshal decodes JSON written by Marshal back into an expression tree.
+func Unmarshal(data []byte) (Expression, *util.Result) {
+	n := &Node{}
+	if err := json.Unmarshal(data, n); err != nil {
+		return nil, util.Error("Unmarshal", err)
+	}
+	e, err := n.Expression()
+	if err != nil {
+		return nil, err.With("Unmarshal")
+	}
+	return e, nil
+}
+
+// Expr holds an expression in a struct that is stored as JSON or YAML, e.g.
+//
+//	type AlertRule struct {
+//		Name   string  `json:"name" yaml:"name"`
+//		Filter fx.Expr `json:"filter" yaml:"filter"`
+//	}
+//
+// A nil Expression is encoded as null.
+type Expr struct {
+	Expression
+}
+
+func (e Expr) MarshalJSON() ([]byte, error) {
+	if e.Expression == nil {
+		return []byte("null"), nil
+	}
+	data, err := Marshal(e.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (e *Expr) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		e.Expression = nil
+		return nil
+	}
+	exp, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	e.Expression = exp
+	return nil
+}
+
+func (e Expr) MarshalYAML() (interface{}, error) {
+	if e.Expression == nil {
+		return nil, nil
+	}
+	n, err := ToNode(e.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (e *Expr) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	n := &Node{}
+	if err := unmarshal(n); err != nil {
+		return err
+	}
+	exp, err := n.Expression()
+	if err != nil {
+		return err
+	}
+	e.Expression = exp
+	return nil
+}
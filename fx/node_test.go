@@ -0,0 +1,217 @@
+package fx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	inputs := []string{
+		`price >= 100`,
+		`status in ("failed", "timeout") && !(retries < 3)`,
+		`-amount * 2 + 1 > 0`,
+		`coalesce(region, "") == "" || region is not null`,
+		`x between 1 and 10`,
+		`code =~ "^E" && msg ~= "disk"`,
+		`f()`,
+		`at < date("2024-03-01T08:15:00Z")`,
+		`x in ()`,
+		`x not in () && y in (1,)`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			exp, err := Parse(input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", input, err)
+			}
+			data, err := Marshal(exp)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", data, err)
+			}
+			if Format(got) != Format(exp) {
+				t.Errorf("round trip = %s, want %s", Format(got), Format(exp))
+			}
+			again, err := Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("Marshal() = %s, want %s", again, data)
+			}
+		})
+	}
+}
+
+func TestMarshal_EmptyList(t *testing.T) {
+	exp := NewInExp(NewVarExp("x"), NewListExp())
+	data, err := Marshal(exp)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"op":"in","args":[{"var":"x"},{"list":[]}]}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	out, yerr := yaml.Marshal(alertRule{Filter: Expr{exp}})
+	if yerr != nil {
+		t.Fatalf("yaml.Marshal() error = %v", yerr)
+	}
+	back := alertRule{}
+	if yerr := yaml.Unmarshal(out, &back); yerr != nil {
+		t.Fatalf("yaml.Unmarshal(%s) error = %v", out, yerr)
+	}
+	if got := Format(back.Filter); got != `x in ()` {
+		t.Errorf("yaml round trip = %s from %s", got, out)
+	}
+}
+
+func TestMarshal_Dual(t *testing.T) {
+	exp := NewEqualExp(NewVarExp("code"), Dual("007"))
+	if got := Format(exp); got != `code == "007"` {
+		t.Errorf("Format() = %s", got)
+	}
+	data, err := Marshal(exp)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	back, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", data, err)
+	}
+	if got := Format(back); got != `code == "007"` {
+		t.Errorf("round trip = %s from %s", got, data)
+	}
+	rh := back.(BinaryExpression).BinOp().Rh.(*Value)
+	if !rh.IsDual() || *rh.Text != "007" || *rh.Number != 7 {
+		t.Errorf("round trip literal = %v, want the dual 007", rh)
+	}
+}
+
+func TestMarshal_Shape(t *testing.T) {
+	exp, err := Parse(`-x >= 1 && lower(s) in ("a",)`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	data, err := Marshal(exp)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"op":"&&","args":[` +
+		`{"op":">=","args":[{"op":"-","args":[{"var":"x"}]},{"value":{"is_numeric":true,"number":1}}]},` +
+		`{"op":"in","args":[{"call":"lower","args":[{"var":"s"}]},{"list":[{"value":{"text":"a","is_numeric":false}}]}]}]}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestMarshal_Errors(t *testing.T) {
+	if _, err := Marshal(nil); err == nil {
+		t.Errorf("Marshal(nil) error = nil, want error")
+	}
+	if _, err := Marshal(&countingExp{}); err == nil {
+		t.Errorf("Marshal(unknown type) error = nil, want error")
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid json", data: `{`},
+		{name: "empty node", data: `{}`},
+		{name: "unknown op", data: `{"op":"<>","args":[{"var":"a"},{"var":"b"}]}`},
+		{name: "op arity", data: `{"op":"<","args":[{"var":"a"}]}`},
+		{name: "unknown function", data: `{"call":"nope","args":[]}`},
+		{name: "function arity", data: `{"call":"lower","args":[]}`},
+		{name: "nested error", data: `{"op":"&&","args":[{"var":"a"},{}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Unmarshal([]byte(tt.data)); err == nil {
+				t.Errorf("Unmarshal(%s) error = nil, want error", tt.data)
+			}
+		})
+	}
+}
+
+type alertRule struct {
+	Name   string `json:"name" yaml:"name"`
+	Filter Expr   `json:"filter" yaml:"filter"`
+}
+
+func TestExpr(t *testing.T) {
+	exp, err := Parse(`severity >= 3 && host =~ "^db-"`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	in := alertRule{Name: "db", Filter: Expr{exp}}
+	env := MapEnv{"severity": 4, "host": "db-01"}
+
+	t.Run("json", func(t *testing.T) {
+		buf, jerr := json.Marshal(in)
+		if jerr != nil {
+			t.Fatalf("json.Marshal() error = %v", jerr)
+		}
+		out := alertRule{}
+		if jerr := json.Unmarshal(buf, &out); jerr != nil {
+			t.Fatalf("json.Unmarshal() error = %v", jerr)
+		}
+		if Format(out.Filter) != Format(exp) {
+			t.Errorf("round trip = %s, want %s", Format(out.Filter), Format(exp))
+		}
+		if !out.Filter.Eval(env).True() {
+			t.Errorf("Eval() = %v, want true", out.Filter.Eval(env))
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		buf, yerr := yaml.Marshal(in)
+		if yerr != nil {
+			t.Fatalf("yaml.Marshal() error = %v", yerr)
+		}
+		if !strings.Contains(string(buf), "op: '&&'") {
+			t.Errorf("yaml.Marshal() = %s, want op nodes", buf)
+		}
+		out := alertRule{}
+		if yerr := yaml.Unmarshal(buf, &out); yerr != nil {
+			t.Fatalf("yaml.Unmarshal() error = %v", yerr)
+		}
+		if Format(out.Filter) != Format(exp) {
+			t.Errorf("round trip = %s, want %s", Format(out.Filter), Format(exp))
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		buf, jerr := json.Marshal(alertRule{Name: "none"})
+		if jerr != nil {
+			t.Fatalf("json.Marshal() error = %v", jerr)
+		}
+		if want := `{"name":"none","filter":null}`; string(buf) != want {
+			t.Errorf("json.Marshal() = %s, want %s", buf, want)
+		}
+		out := alertRule{Filter: Expr{exp}}
+		if jerr := json.Unmarshal(buf, &out); jerr != nil {
+			t.Fatalf("json.Unmarshal() error = %v", jerr)
+		}
+		if out.Filter.Expression != nil {
+			t.Errorf("Filter = %v, want nil", out.Filter.Expression)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		out := alertRule{}
+		if jerr := json.Unmarshal([]byte(`{"filter":{"op":"??"}}`), &out); jerr == nil {
+			t.Errorf("json.Unmarshal() error = nil, want error")
+		}
+		if yerr := yaml.Unmarshal([]byte("filter:\n  call: nope\n"), &out); yerr == nil {
+			t.Errorf("yaml.Unmarshal() error = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package fx
+
+//IsNullOp    Operator = "is null"
+//IsNotNullOp Operator = "is not null"
+
+func init() {
+	RegisterNewUnaryOpExpCreator(IsNullOp, NewIsNullExp)
+	RegisterNewUnaryOpExpCreator(IsNotNullOp, NewIsNotNullExp)
+	postfixOps[IsNullOp] = true
+	postfixOps[IsNotNullOp] = true
+
+	RegisterFunc("coalesce", VariadicArity, fnCoalesce)
+}
+
+type IsNullExp struct {
+	UnaryOpExp
+}
+
+func (e IsNullExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e IsNullExp) Eval(env Env) *Value {
+	v := e.X.Eval(env)
+	if v.HasError() {
+		return Error(v.Error.With("Operand Error"))
+	}
+	return Bool(v.IsNull())
+}
+
+func NewIsNullExp(x Expression) Expression {
+	return &IsNullExp{
+		UnaryOpExp{
+			Op: IsNullOp,
+			X:  x,
+		},
+	}
+}
+
+type IsNotNullExp struct {
+	UnaryOpExp
+}
+
+func (e IsNotNullExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e IsNotNullExp) Eval(env Env) *Value {
+	v := e.X.Eval(env)
+	if v.HasError() {
+		return Error(v.Error.With("Operand Error"))
+	}
+	return Bool(!v.IsNull())
+}
+
+func NewIsNotNullExp(x Expression) Expression {
+	return &IsNotNullExp{
+		UnaryOpExp{
+			Op: IsNotNullOp,
+			X:  x,
+		},
+	}
+}
+
+// coalesce(a, b, ...) returns its first non-null argument, or null.
+func fnCoalesce(args []*Value) *Value {
+	for _, a := range args {
+		if !a.IsNull() {
+			return a
+		}
+	}
+	return Nil()
+}
@@ -0,0 +1,164 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestValue_NullComparisons(t *testing.T) {
+	tests := []struct {
+		name string
+		got  *Value
+		want *Value
+	}{
+		{name: "nil < number", got: Nil().Less(Number(1)), want: Nil()},
+		{name: "text < nil", got: Text("a").Less(Nil()), want: Nil()},
+		{name: "nil < nil", got: Nil().Less(Nil()), want: Nil()},
+		{name: "nil == empty text", got: Nil().Equal(Text("")), want: Nil()},
+		{name: "zero == nil", got: Number(0).Equal(Nil()), want: Nil()},
+		{name: "nil == nil", got: Nil().Equal(Nil()), want: Nil()},
+		{name: "error < nil", got: Error(util.MsgError("test", "error")).Less(Nil()), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want == nil {
+				if !tt.got.HasError() {
+					t.Errorf("got %v, want error", util.JsonStr(tt.got))
+				}
+				return
+			}
+			if !tt.got.Identical(tt.want) {
+				t.Errorf("got %v, want %v", util.JsonStr(tt.got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestValue_ThreeValuedLogic(t *testing.T) {
+	T, F, N := True(), False(), Nil()
+	tests := []struct {
+		name string
+		lh   *Value
+		rh   *Value
+		and  *Value
+		or   *Value
+	}{
+		{name: "true, true", lh: T, rh: T, and: T, or: T},
+		{name: "true, false", lh: T, rh: F, and: F, or: T},
+		{name: "true, null", lh: T, rh: N, and: N, or: T},
+		{name: "false, true", lh: F, rh: T, and: F, or: T},
+		{name: "false, false", lh: F, rh: F, and: F, or: F},
+		{name: "false, null", lh: F, rh: N, and: F, or: N},
+		{name: "null, true", lh: N, rh: T, and: N, or: T},
+		{name: "null, false", lh: N, rh: F, and: F, or: N},
+		{name: "null, null", lh: N, rh: N, and: N, or: N},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lh.And(tt.rh); !got.Identical(tt.and) {
+				t.Errorf("And() = %v, want %v", util.JsonStr(got), util.JsonStr(tt.and))
+			}
+			if got := tt.lh.Or(tt.rh); !got.Identical(tt.or) {
+				t.Errorf("Or() = %v, want %v", util.JsonStr(got), util.JsonStr(tt.or))
+			}
+			if got := NewAndExp(tt.lh, tt.rh).Calc(); !got.Identical(tt.and) {
+				t.Errorf("AndExp.Calc() = %v, want %v", util.JsonStr(got), util.JsonStr(tt.and))
+			}
+			if got := NewOrExp(tt.lh, tt.rh).Calc(); !got.Identical(tt.or) {
+				t.Errorf("OrExp.Calc() = %v, want %v", util.JsonStr(got), util.JsonStr(tt.or))
+			}
+		})
+	}
+
+	t.Run("not", func(t *testing.T) {
+		if got := N.Not(); !got.IsNull() {
+			t.Errorf("Not(null) = %v, want null", util.JsonStr(got))
+		}
+		if got := NewNotExp(N).Calc(); !got.IsNull() {
+			t.Errorf("NotExp(null) = %v, want null", util.JsonStr(got))
+		}
+	})
+}
+
+func TestValue_Identical(t *testing.T) {
+	err := util.MsgError("test", "error")
+	tests := []struct {
+		name string
+		lh   *Value
+		rh   *Value
+		want bool
+	}{
+		{name: "nil, nil", lh: Nil(), rh: Nil(), want: true},
+		{name: "nil, empty text", lh: Nil(), rh: Text(""), want: false},
+		{name: "numbers", lh: Number(1), rh: Number(1), want: true},
+		{name: "different numbers", lh: Number(1), rh: Number(2), want: false},
+		{name: "same error", lh: Error(err), rh: Error(err), want: true},
+		{name: "error, nil", lh: Error(err), rh: Nil(), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.lh.Identical(tt.rh); got != tt.want {
+				t.Errorf("Identical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Null(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		env     MapEnv
+		want    *Value
+		wantErr bool
+	}{
+		{name: "missing is not empty", input: `missing == ""`, env: MapEnv{"missing": nil}, want: Nil()},
+		{name: "is null", input: `missing is null`, env: MapEnv{"missing": nil}, want: True()},
+		{name: "is null on value", input: `x is null`, env: MapEnv{"x": ""}, want: False()},
+		{name: "is not null", input: `x is not null`, env: MapEnv{"x": 0}, want: True()},
+		{name: "is not null on null", input: `x is not null`, env: MapEnv{"x": nil}, want: False()},
+		{name: "is null binds after arithmetic", input: `1 + 1 is not null`, want: True()},
+		{name: "is null in logic", input: `x is null || x > 5`, env: MapEnv{"x": nil}, want: True()},
+		{name: "is null error", input: `x is null`, env: MapEnv{}, wantErr: true},
+		{name: "null literal", input: `null is null`, want: True()},
+		{name: "comparison with null literal", input: `1 == null`, want: Nil()},
+		{name: "unknown and false", input: `x > 5 && false`, env: MapEnv{"x": nil}, want: False()},
+		{name: "unknown or true", input: `x > 5 || true`, env: MapEnv{"x": nil}, want: True()},
+		{name: "not unknown", input: `!(x > 5)`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "not equal unknown", input: `x != 5`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "less or equal unknown", input: `x <= 5`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "in with null lh", input: `x in (1, 2)`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "in with null item and match", input: `1 in (null, 1)`, want: True()},
+		{name: "in with null item no match", input: `3 in (null, 1)`, want: Nil()},
+		{name: "not in with null item", input: `3 not in (null, 1)`, want: Nil()},
+		{name: "between null", input: `x between 1 and 3`, env: MapEnv{"x": nil}, want: Nil()},
+		{name: "between null bound outside", input: `5 between null and 3`, want: False()},
+		{name: "coalesce", input: `coalesce(x, y, 3)`, env: MapEnv{"x": nil, "y": nil}, want: Number(3)},
+		{name: "coalesce first", input: `coalesce(x, 3)`, env: MapEnv{"x": "a"}, want: Text("a")},
+		{name: "coalesce all null", input: `coalesce(null, null)`, want: Nil()},
+		{name: "coalesce none", input: `coalesce()`, want: Nil()},
+		{name: "coalesce for optional field", input: `coalesce(region, "") == ""`, env: MapEnv{"region": nil}, want: True()},
+		{name: "min ignores null", input: `min(null, 3, 2)`, want: Number(2)},
+		{name: "max all null", input: `max(null, null)`, want: Nil()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Eval(tt.env)
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("Parse(%q).Eval() error = %v, wantErr %v", tt.input, got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Eval() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
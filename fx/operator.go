@@ -17,7 +17,31 @@ const (
 	NotEqOp     Operator = "!="
 
 	//Text Ops
-	IncludeOp Operator = "~="
+	IncludeOp  Operator = "~="
+	MatchOp    Operator = "=~"
+	NotMatchOp Operator = "!~"
+
+	//List Ops
+	InOp      Operator = "in"
+	NotInOp   Operator = "not in"
+	BetweenOp Operator = "between"
+
+	//Arithmetic Ops
+	AddOp Operator = "+"
+	SubOp Operator = "-"
+	MulOp Operator = "*"
+	DivOp Operator = "/"
+	ModOp Operator = "%"
+	NegOp Operator = "-" // unary form of SubOp
+
+	//Null Ops, written after their operand: `x is null`
+	IsNullOp    Operator = "is null"
+	IsNotNullOp Operator = "is not null"
+
+	//Logical Ops
+	AndOp Operator = "&&"
+	OrOp  Operator = "||"
+	NotOp Operator = "!"
 )
 
 func ParseOperator(str string) (Operator, *util.Result) {
@@ -36,6 +60,36 @@ func ParseOperator(str string) (Operator, *util.Result) {
 		return NotEqOp, nil
 	case string(IncludeOp):
 		return IncludeOp, nil
+	case string(MatchOp):
+		return MatchOp, nil
+	case string(NotMatchOp):
+		return NotMatchOp, nil
+	case string(InOp):
+		return InOp, nil
+	case string(NotInOp):
+		return NotInOp, nil
+	case string(BetweenOp):
+		return BetweenOp, nil
+	case string(AddOp):
+		return AddOp, nil
+	case string(SubOp):
+		return SubOp, nil
+	case string(MulOp):
+		return MulOp, nil
+	case string(DivOp):
+		return DivOp, nil
+	case string(ModOp):
+		return ModOp, nil
+	case string(IsNullOp):
+		return IsNullOp, nil
+	case string(IsNotNullOp):
+		return IsNotNullOp, nil
+	case string(AndOp):
+		return AndOp, nil
+	case string(OrOp):
+		return OrOp, nil
+	case string(NotOp):
+		return NotOp, nil
 	}
 	return UnkownOp, util.MsgError(str, "Unknown operator")
 }
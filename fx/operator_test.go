@@ -53,6 +53,96 @@ func TestParseOperator(t *testing.T) {
 			want:    IncludeOp,
 			wantErr: false,
 		},
+		{
+			name:    "match",
+			input:   "=~",
+			want:    MatchOp,
+			wantErr: false,
+		},
+		{
+			name:    "not match",
+			input:   "!~",
+			want:    NotMatchOp,
+			wantErr: false,
+		},
+		{
+			name:    "in",
+			input:   "in",
+			want:    InOp,
+			wantErr: false,
+		},
+		{
+			name:    "not in",
+			input:   "not in",
+			want:    NotInOp,
+			wantErr: false,
+		},
+		{
+			name:    "between",
+			input:   "between",
+			want:    BetweenOp,
+			wantErr: false,
+		},
+		{
+			name:    "add",
+			input:   "+",
+			want:    AddOp,
+			wantErr: false,
+		},
+		{
+			name:    "sub",
+			input:   "-",
+			want:    SubOp,
+			wantErr: false,
+		},
+		{
+			name:    "mul",
+			input:   "*",
+			want:    MulOp,
+			wantErr: false,
+		},
+		{
+			name:    "div",
+			input:   "/",
+			want:    DivOp,
+			wantErr: false,
+		},
+		{
+			name:    "mod",
+			input:   "%",
+			want:    ModOp,
+			wantErr: false,
+		},
+		{
+			name:    "is null",
+			input:   "is null",
+			want:    IsNullOp,
+			wantErr: false,
+		},
+		{
+			name:    "is not null",
+			input:   "is not null",
+			want:    IsNotNullOp,
+			wantErr: false,
+		},
+		{
+			name:    "and",
+			input:   "&&",
+			want:    AndOp,
+			wantErr: false,
+		},
+		{
+			name:    "or",
+			input:   "||",
+			want:    OrOp,
+			wantErr: false,
+		},
+		{
+			name:    "not",
+			input:   "!",
+			want:    NotOp,
+			wantErr: false,
+		},
 		{
 			name:    "unknown operator",
 			input:   "???",
@@ -67,7 +157,7 @@ func TestParseOperator(t *testing.T) {
 		},
 		{
 			name:    "invalid operator",
-			input:   "&&",
+			input:   "&",
 			want:    UnkownOp,
 			wantErr: true,
 		},
@@ -101,6 +191,22 @@ func TestOperatorConstants(t *testing.T) {
 		{"GreaterEqOp", GreaterEqOp, ">="},
 		{"NotEqOp", NotEqOp, "!="},
 		{"IncludeOp", IncludeOp, "~="},
+		{"MatchOp", MatchOp, "=~"},
+		{"NotMatchOp", NotMatchOp, "!~"},
+		{"InOp", InOp, "in"},
+		{"NotInOp", NotInOp, "not in"},
+		{"BetweenOp", BetweenOp, "between"},
+		{"AddOp", AddOp, "+"},
+		{"SubOp", SubOp, "-"},
+		{"MulOp", MulOp, "*"},
+		{"DivOp", DivOp, "/"},
+		{"ModOp", ModOp, "%"},
+		{"NegOp", NegOp, "-"},
+		{"IsNullOp", IsNullOp, "is null"},
+		{"IsNotNullOp", IsNotNullOp, "is not null"},
+		{"AndOp", AndOp, "&&"},
+		{"OrOp", OrOp, "||"},
+		{"NotOp", NotOp, "!"},
 	}
 
 	for _, tt := range tests {
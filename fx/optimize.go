@@ -0,0 +1,144 @@
+package fx
+
+// Optimize returns an equivalent tree with constant subtrees evaluated into
+// literals, so `price > 10 * 2` becomes `price > 20`, and with literal bool
+// operands of && and || removed where they do not decide the result:
+// `x && true` becomes `x` and `false && x` becomes `false`. A right-hand
+// `x && false` is kept, since evaluating x may still fail. The
+// simplifications assume logical operands are bools, as Check verifies.
+//
+// Calls to volatile functions such as now(), aggregates and subtrees whose
+// evaluation fails are left as they are, so the failure still happens at evaluation.
+// So are comparisons whose result depends on the Coercion they are
+// evaluated with, such as `"10" > "9"`, and decimal divisions and roundings
+// whose result depends on the DecimalOptions. e itself is not modified.
+func Optimize(e Expression) Expression {
+	return optimize(unwrapExpr(e))
+}
+
+func optimize(e Expression) Expression {
+	switch x := e.(type) {
+	case *CallExp:
+		args := make([]Expression, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = optimize(a)
+		}
+		exp, err := NewCallExp(x.Name, args...)
+		if err != nil {
+			return e
+		}
+		exp = withPos(exp, x.Pos)
+		if def := exp.(*CallExp).def; def.volatile || def.agg != nil || !allConst(args...) {
+			return exp
+		}
+		return fold(exp)
+	case *ListExp:
+		items := make([]Expression, len(x.Items))
+		for i, item := range x.Items {
+			items[i] = optimize(item)
+		}
+		return withPos(NewListExp(items...), x.Pos)
+	case BinaryExpression:
+		b := x.BinOp()
+		lh, rh := optimize(b.Lh), optimize(b.Rh)
+		if s := simplifyLogic(b.Op, lh, rh); s != nil {
+			return s
+		}
+		exp, err := NewBinOpExp(b.Op, lh, rh)
+		if err != nil {
+			return e
+		}
+		exp = withPos(exp, b.Pos)
+		if !allConst(lh, rh) {
+			return exp
+		}
+		return fold(exp)
+	case UnaryExpression:
+		u := x.UnaryOp()
+		operand := optimize(u.X)
+		exp, err := NewUnaryOpExp(u.Op, operand)
+		if err != nil {
+			return e
+		}
+		exp = withPos(exp, u.Pos)
+		if !allConst(operand) {
+			return exp
+		}
+		return fold(exp)
+	}
+	return e
+}
+
+// allConst reports whether every expression is a literal, or a list of
+// literals.
+func allConst(es ...Expression) bool {
+	for _, e := range es {
+		switch x := e.(type) {
+		case *Value:
+		case *ListExp:
+			if !allConst(x.Items...) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// foldCoercions are the policies a constant expression must evaluate the
+// same under to be folded.
+var foldCoercions = []Coercion{CoerceNumeric, CoerceText}
+
+// foldDecimalOptions are the decimal options a constant expression must
+// evaluate to the same digits under to be folded: a decimal result that
+// rounds the same down and up to no digits is exact under any options.
+var foldDecimalOptions = []DecimalOptions{{Rounding: RoundDown}, {Rounding: RoundUp}}
+
+// fold evaluates a constant expression, keeping e if evaluation fails or
+// its result depends on the coercion or the decimal options.
+func fold(e Expression) Expression {
+	v := e.Calc()
+	if v.HasError() {
+		return e
+	}
+	for _, c := range foldCoercions {
+		if !e.Eval(WithCoercion(nil, c)).Identical(v) {
+			return e
+		}
+	}
+	for _, o := range foldDecimalOptions {
+		if w := e.Eval(WithDecimalOptions(nil, o)); !w.Identical(v) || w.String() != v.String() {
+			return e
+		}
+	}
+	return v
+}
+
+// simplifyLogic drops literal operands of && and || that do not change the
+// result; it returns nil if there is nothing to simplify.
+func simplifyLogic(op Operator, lh, rh Expression) Expression {
+	lv, _ := lh.(*Value)
+	rv, _ := rh.(*Value)
+	switch op {
+	case AndOp:
+		switch {
+		case lv != nil && lv.False():
+			return False()
+		case lv != nil && lv.True():
+			return rh
+		case rv != nil && rv.True():
+			return lh
+		}
+	case OrOp:
+		switch {
+		case lv != nil && lv.True():
+			return True()
+		case lv != nil && lv.False():
+			return rh
+		case rv != nil && rv.False():
+			return lh
+		}
+	}
+	return nil
+}
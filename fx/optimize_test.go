@@ -0,0 +1,123 @@
+package fx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "fold arithmetic", input: `price > 10 * 2 + 1`, want: `price > 21`},
+		{name: "fold whole", input: `1 + 2 == 3`, want: `true`},
+		{name: "fold call", input: `name == upper("ab")`, want: `name == "AB"`},
+		{name: "fold list", input: `x in (1 + 1, 3)`, want: `x in (2, 3)`},
+		{name: "fold in", input: `2 in (1 + 1, 3)`, want: `true`},
+		{name: "fold null", input: `coalesce(null, 1) + x`, want: `1 + x`},
+		{name: "and true", input: `x > 1 && true`, want: `x > 1`},
+		{name: "true and", input: `true && x > 1`, want: `x > 1`},
+		{name: "false and", input: `false && x > 1`, want: `false`},
+		{name: "and false kept", input: `x > 1 && false`, want: `x > 1 && false`},
+		{name: "or false", input: `x || false`, want: `x`},
+		{name: "false or", input: `false || x`, want: `x`},
+		{name: "true or", input: `true || x`, want: `true`},
+		{name: "or true kept", input: `x || true`, want: `x || true`},
+		{name: "folded condition", input: `1 < 2 && x`, want: `x`},
+		{name: "nested", input: `(x || 2 > 3) && (y && 1 == 1)`, want: `x && y`},
+		{name: "not folded", input: `!(1 > 2) && x`, want: `x`},
+		{name: "null folded", input: `null is null || x`, want: `true`},
+		{name: "unknown kept", input: `x && null`, want: `x && null`},
+		{name: "error kept", input: `x + 1 / 0`, want: `x + 1 / 0`},
+		{name: "volatile kept", input: `now() > addDays(now(), 0 - 1)`, want: `now() > addDays(now(), -1)`},
+		{name: "neg", input: `-(2 * 3) + x`, want: `-6 + x`},
+		{name: "nothing to do", input: `a < b`, want: `a < b`},
+		{name: "coerced text kept", input: `"10" > "9"`, want: `"10" > "9"`},
+		{name: "coerced mix kept", input: `"7" == 7 || x`, want: `"7" == 7 || x`},
+		{name: "coerced in kept", input: `"007" in (7, 8)`, want: `"007" in (7, 8)`},
+		{name: "same text folded", input: `"a" < "b"`, want: `true`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			before := Format(exp)
+			got := Optimize(exp)
+			if s := Format(got); s != tt.want {
+				t.Errorf("Optimize(%q) = %s, want %s", tt.input, s, tt.want)
+			}
+			if Format(exp) != before {
+				t.Errorf("Optimize(%q) modified its input: %s", tt.input, Format(exp))
+			}
+		})
+	}
+}
+
+func TestOptimize_KeepsPositions(t *testing.T) {
+	exp, err := Parse(`price > 10 * 2`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got := Optimize(exp)
+	if pos := PosOf(got); pos != (Position{Line: 1, Column: 7}) {
+		t.Errorf("PosOf(Optimize()) = %v, want line 1, column 7", pos)
+	}
+	if pos := PosOf(got.(BinaryExpression).BinOp().Lh); pos != (Position{Line: 1, Column: 1}) {
+		t.Errorf("PosOf(lh) = %v, want line 1, column 1", pos)
+	}
+}
+
+func TestOptimize_Eval(t *testing.T) {
+	pinClock(t, time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC))
+	env := MapEnv{"price": 25.0, "qty": 2, "name": "ab", "x": nil}
+	inputs := []string{
+		`price > 10 * 2 && true`,
+		`qty * (1 + 1) == 4 || false`,
+		`upper(name) == upper("ab") && (x is null || false)`,
+		`dateDiff(now(), addDays(today(), 0 - 2)) == 2`,
+		`x > 1 && true`,
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			exp, err := Parse(input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", input, err)
+			}
+			if got, want := Optimize(exp).Eval(env), exp.Eval(env); !got.Identical(want) {
+				t.Errorf("Optimize().Eval() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOptimize_EvalCoercion(t *testing.T) {
+	env := MapEnv{"code": "007", "n": 7}
+	inputs := []string{
+		`"10" > "9"`,
+		`"10" > 9 + 0`,
+		`"007" == 7`,
+		`"007" in (7, "8")`,
+		`"5" between 1 and "10"`,
+		`upper("a") < "B" && code == "007"`,
+		`n == "7" || "1.0" == "1"`,
+	}
+	for _, c := range []Coercion{CoerceStrict, CoerceNumeric, CoerceText} {
+		for _, input := range inputs {
+			t.Run(string(c)+"/"+input, func(t *testing.T) {
+				exp, err := Parse(input)
+				if err != nil {
+					t.Fatalf("Parse(%q) error = %v", input, err)
+				}
+				env := WithCoercion(env, c)
+				if got, want := Optimize(exp).Eval(env), exp.Eval(env); !got.Identical(want) {
+					t.Errorf("Optimize().Eval() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package fx
+
+// evalOptions are the settings an Env carries for the evaluations in it;
+// see WithCoercion and WithDecimalOptions.
+type evalOptions struct {
+	coercion Coercion
+	decimal  DecimalOptions
+}
+
+// withOptions returns env with its options changed by set. A RowSet stays
+// a RowSet, whose rows get the same options.
+func withOptions(env Env, set func(o *evalOptions)) Env {
+	o := evalOptions{coercion: coercionOf(env), decimal: decimalOptionsOf(env)}
+	set(&o)
+	switch e := env.(type) {
+	case optionsEnv:
+		env = e.Env
+	case optionsRowSet:
+		env = e.RowSet
+	}
+	if rs, ok := env.(RowSet); ok {
+		return optionsRowSet{RowSet: rs, o: o}
+	}
+	return optionsEnv{Env: env, o: o}
+}
+
+type optionsEnv struct {
+	Env
+	o evalOptions
+}
+
+func (e optionsEnv) Lookup(name string) (*Value, bool) {
+	if e.Env == nil {
+		return nil, false
+	}
+	return e.Env.Lookup(name)
+}
+
+func (e optionsEnv) Coercion() Coercion {
+	return e.o.coercion
+}
+
+func (e optionsEnv) DecimalOptions() DecimalOptions {
+	return e.o.decimal
+}
+
+type optionsRowSet struct {
+	RowSet
+	o evalOptions
+}
+
+func (e optionsRowSet) Coercion() Coercion {
+	return e.o.coercion
+}
+
+func (e optionsRowSet) DecimalOptions() DecimalOptions {
+	return e.o.decimal
+}
+
+func (e optionsRowSet) Rows() []Env {
+	rows := e.RowSet.Rows()
+	withOpts := make([]Env, len(rows))
+	for i, row := range rows {
+		withOpts[i] = withOptions(row, func(o *evalOptions) { *o = e.o })
+	}
+	return withOpts
+}
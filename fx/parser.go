@@ -0,0 +1,289 @@
+package fx
+
+import (
+	"strconv"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+const (
+	precLowest   = 1
+	precOr       = 2
+	precAnd      = 3
+	precRelation = 4
+	precAdditive = 5
+	precMultiply = 6
+)
+
+// opPrecedences holds the binding power of binary operators; higher binds
+// tighter. Registered operators without an entry bind like relations.
+var opPrecedences = map[Operator]int{
+	LessOp:      precRelation,
+	GreaterOp:   precRelation,
+	EqualOp:     precRelation,
+	LessEqOp:    precRelation,
+	GreaterEqOp: precRelation,
+	NotEqOp:     precRelation,
+	IncludeOp:   precRelation,
+	MatchOp:     precRelation,
+	NotMatchOp:  precRelation,
+	IsNullOp:    precRelation,
+	IsNotNullOp: precRelation,
+	InOp:        precRelation,
+	NotInOp:     precRelation,
+	BetweenOp:   precRelation,
+	AddOp:       precAdditive,
+	SubOp:       precAdditive,
+	MulOp:       precMultiply,
+	DivOp:       precMultiply,
+	ModOp:       precMultiply,
+	AndOp:       precAnd,
+	OrOp:        precOr,
+}
+
+func precedenceOf(op Operator) int {
+	if p, ok := opPrecedences[op]; ok {
+		return p
+	}
+	return precRelation
+}
+
+type parser struct {
+	lex *lexer
+	tok *token
+}
+
+// Parse compiles expression source text such as `amount >= 100` into an
+// Expression tree. An identifier followed by '(' calls a function from the
+// RegisterFunc registry. Other identifiers except true/false/null, and names
+// prefixed with '$', are variables resolved from the Env passed to Eval. Binary and
+// prefix operators are resolved through the creators registered with
+// RegisterNewBinOpExpCreator and RegisterNewUnaryOpExpCreator.
+func Parse(src string) (Expression, *util.Result) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	exp, err := p.parseExpr(precLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return exp, nil
+}
+
+func (p *parser) advance() *util.Result {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() *util.Result {
+	if p.tok.kind == tokEOF {
+		return parseError(p.tok.pos, "unexpected end of input")
+	}
+	return parseError(p.tok.pos, "unexpected %s '%s'", p.tok.kind, p.tok.text)
+}
+
+func (p *parser) expect(kind tokenKind) *util.Result {
+	if p.tok.kind != kind {
+		return parseError(p.tok.pos, "expected %s, got %s", kind, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return p.tok.kind.String()
+	}
+	return p.tok.kind.String() + " '" + p.tok.text + "'"
+}
+
+// parseExpr implements precedence climbing: it parses a primary operand and
+// then folds in every following binary operator that binds at least as
+// tightly as minPrec. All binary operators are left-associative.
+func (p *parser) parseExpr(minPrec int) (Expression, *util.Result) {
+	lh, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp {
+		op := Operator(p.tok.text)
+		prec := precedenceOf(op)
+		if prec < minPrec {
+			break
+		}
+		opTok := p.tok
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if postfixOps[op] {
+			exp, err := NewUnaryOpExp(op, lh)
+			if err != nil {
+				return nil, parseError(opTok.pos, "%s", err.Msg)
+			}
+			lh = withPos(exp, opTok.pos)
+			continue
+		}
+		var rh Expression
+		var err *util.Result
+		if op == BetweenOp {
+			rh, err = p.parseBetweenBounds(prec + 1)
+		} else {
+			rh, err = p.parseExpr(prec + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		exp, err := NewBinOpExp(op, lh, rh)
+		if err != nil {
+			return nil, parseError(opTok.pos, "%s", err.Msg)
+		}
+		lh = withPos(exp, opTok.pos)
+	}
+	return lh, nil
+}
+
+func (p *parser) parsePrimary() (Expression, *util.Result) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, parseError(tok.pos, "invalid number '%s'", tok.text)
+		}
+		return Number(f), p.advance()
+	case tokText:
+		return Text(tok.text), p.advance()
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return True(), p.advance()
+		case "false":
+			return False(), p.advance()
+		case "null":
+			return Nil(), p.advance()
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokLParen {
+			return p.parseCall(tok)
+		}
+		return withPos(NewVarExp(tok.text), tok.pos), nil
+	case tokVar:
+		return withPos(NewVarExp(tok.text), tok.pos), p.advance()
+	case tokOp:
+		if _, ok := unaryOpExpCreators[Operator(tok.text)]; !ok || postfixOps[Operator(tok.text)] {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		exp, err := NewUnaryOpExp(Operator(tok.text), x)
+		if err != nil {
+			return nil, parseError(tok.pos, "%s", err.Msg)
+		}
+		return withPos(exp, tok.pos), nil
+	case tokLParen:
+		return p.parseParen()
+	}
+	return nil, p.unexpected()
+}
+
+// parseCall parses the argument list of a call to the function named by
+// nameTok; the current token is the opening '('.
+func (p *parser) parseCall(nameTok *token) (Expression, *util.Result) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	args := make([]Expression, 0)
+	if p.tok.kind != tokRParen {
+		for {
+			arg, err := p.parseExpr(precLowest)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.tok.kind != tokComma {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.expect(tokRParen); err != nil {
+		return nil, err
+	}
+	exp, err := NewCallExp(nameTok.text, args...)
+	if err != nil {
+		return nil, parseError(nameTok.pos, "%s", err.Msg)
+	}
+	return withPos(exp, nameTok.pos), nil
+}
+
+// parseParen parses a parenthesised expression, or a list literal when the
+// parentheses hold comma-separated items or none: `(1, 2)`, `(1,)` or `()`.
+func (p *parser) parseParen() (Expression, *util.Result) {
+	start := p.tok.pos
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokRParen {
+		return withPos(NewListExp(), start), p.advance()
+	}
+	exp, err := p.parseExpr(precLowest)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokComma {
+		return exp, p.expect(tokRParen)
+	}
+
+	items := []Expression{exp}
+	for p.tok.kind == tokComma {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokRParen {
+			break
+		}
+		item, err := p.parseExpr(precLowest)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return withPos(NewListExp(items...), start), p.expect(tokRParen)
+}
+
+// parseBetweenBounds parses the `lo and hi` part of `x between lo and hi`
+// into a two-item list.
+func (p *parser) parseBetweenBounds(minPrec int) (Expression, *util.Result) {
+	start := p.tok.pos
+	lo, err := p.parseExpr(minPrec)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokIdent || p.tok.text != "and" {
+		return nil, parseError(p.tok.pos, "expected 'and', got %s", p.describe())
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	hi, err := p.parseExpr(minPrec)
+	if err != nil {
+		return nil, err
+	}
+	return withPos(NewListExp(lo, hi), start), nil
+}
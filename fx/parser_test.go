@@ -0,0 +1,147 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestParse_Calc(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *Value
+	}{
+		{name: "number less", input: "5 < 10", want: True()},
+		{name: "number greater", input: "5 > 10", want: False()},
+		{name: "equal", input: "5 == 5", want: True()},
+		{name: "less or equal", input: "5 <= 5", want: True()},
+		{name: "greater or equal", input: "4 >= 5", want: False()},
+		{name: "not equal", input: "4 != 5", want: True()},
+		{name: "include", input: `"hello world" ~= "world"`, want: True()},
+		{name: "single quoted text", input: `'abc' < 'bcd'`, want: True()},
+		{name: "escaped quote", input: `"say \"hi\"" ~= "\"hi\""`, want: True()},
+		{name: "decimal and exponent", input: "1.5e3 == 1500", want: True()},
+		{name: "leading dot", input: ".5 < 1", want: True()},
+		{name: "negative number", input: "-5 < -2", want: True()},
+		{name: "boolean literal", input: "true == true", want: True()},
+		{name: "boolean mismatch", input: "true == false", want: False()},
+		{name: "parentheses", input: "(5 < 10) == true", want: True()},
+		{name: "left associative", input: "1 < 2 == true", want: True()},
+		{name: "nested parentheses", input: "((3 >= 3))", want: True()},
+		{name: "literal only", input: `"abc"`, want: Text("abc")},
+		{name: "number only", input: "42", want: Number(42)},
+		{name: "multi-line", input: "5\n<\n10", want: True()},
+		{name: "empty list", input: "1 in ()", want: False()},
+		{name: "not in empty list", input: "1 not in ()", want: True()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := exp.Calc()
+			if !got.Identical(tt.want) {
+				t.Errorf("Parse(%q).Calc() = %v, want %v", tt.input, util.JsonStr(got), util.JsonStr(tt.want))
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos Position
+	}{
+		{name: "empty input", input: "", wantPos: Position{Line: 1, Column: 1}},
+		{name: "missing rh", input: "5 <", wantPos: Position{Line: 1, Column: 4}},
+		{name: "missing lh", input: "< 5", wantPos: Position{Line: 1, Column: 1}},
+		{name: "unclosed paren", input: "(5 < 10", wantPos: Position{Line: 1, Column: 8}},
+		{name: "extra paren", input: "5 < 10)", wantPos: Position{Line: 1, Column: 7}},
+		{name: "unknown operator", input: "5 <> 10", wantPos: Position{Line: 1, Column: 4}},
+		{name: "dangling dollar", input: "5 < $", wantPos: Position{Line: 1, Column: 5}},
+		{name: "variable with bad name", input: "$1 < 5", wantPos: Position{Line: 1, Column: 1}},
+		{name: "unterminated text", input: `"abc`, wantPos: Position{Line: 1, Column: 1}},
+		{name: "bad escape", input: `"a\qb"`, wantPos: Position{Line: 1, Column: 4}},
+		{name: "bad number", input: "5x < 1", wantPos: Position{Line: 1, Column: 2}},
+		{name: "error on second line", input: "5 <\n  # 10", wantPos: Position{Line: 2, Column: 3}},
+		{name: "trailing operand", input: "5 < 10 11", wantPos: Position{Line: 1, Column: 8}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err == nil {
+				t.Fatalf("Parse(%q) = %v, want error", tt.input, exp)
+			}
+			pos, ok := err.Result.(Position)
+			if !ok {
+				t.Fatalf("Parse(%q) error has no position: %v", tt.input, err)
+			}
+			if pos != tt.wantPos {
+				t.Errorf("Parse(%q) error at %v, want %v (%v)", tt.input, pos, tt.wantPos, err)
+			}
+		})
+	}
+}
+
+func TestParse_CustomOperator(t *testing.T) {
+	startsOp := Operator("^=")
+	RegisterNewBinOpExpCreator(startsOp, func(lh, rh Expression) Expression {
+		return &EqualExp{BinOpExp{Op: startsOp, Lh: lh, Rh: rh}}
+	})
+	defer delete(binOpExpCreators, startsOp)
+
+	exp, err := Parse(`"a" ^= "a"`)
+	if err != nil {
+		t.Fatalf("Parse() with custom operator error = %v", err)
+	}
+	if !exp.Calc().True() {
+		t.Errorf("Parse() with custom operator = %v, want true", exp.Calc())
+	}
+
+	wordOp := Operator("LIKE")
+	RegisterNewBinOpExpCreator(wordOp, NewIncludeExp)
+	defer delete(binOpExpCreators, wordOp)
+
+	exp, err = Parse(`"abc" LIKE "b"`)
+	if err != nil {
+		t.Fatalf("Parse() with word operator error = %v", err)
+	}
+	if !exp.Calc().True() {
+		t.Errorf("Parse() with word operator = %v, want true", exp.Calc())
+	}
+}
+
+func TestParse_Variables(t *testing.T) {
+	exp, err := Parse(`$price >= 100 == row.active`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	rows := []struct {
+		env  MapEnv
+		want *Value
+	}{
+		{env: MapEnv{"price": 150, "row": map[string]any{"active": true}}, want: True()},
+		{env: MapEnv{"price": 50, "row": map[string]any{"active": true}}, want: False()},
+		{env: MapEnv{"price": 50, "row.active": false}, want: True()},
+	}
+	for i, row := range rows {
+		got := exp.Eval(row.env)
+		if !got.Identical(row.want) {
+			t.Errorf("row %d: Eval() = %v, want %v", i, util.JsonStr(got), util.JsonStr(row.want))
+		}
+	}
+
+	exp, err = Parse(`$price >= 100`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := exp.Calc(); !got.HasError() {
+		t.Errorf("Calc() without env = %v, want error", util.JsonStr(got))
+	}
+}
@@ -21,10 +21,14 @@ type LessExp struct {
 }
 
 func (e LessExp) Calc() *Value {
-	return e.Lh.Calc().Less(e.Rh.Calc())
+	return e.Eval(nil)
 }
 
-func NewLessExp(lh, rh *Value) Expression {
+func (e LessExp) Eval(env Env) *Value {
+	return coercionOf(env).Less(e.Lh.Eval(env), e.Rh.Eval(env))
+}
+
+func NewLessExp(lh, rh Expression) Expression {
 	return &LessExp{
 		BinOpExp{
 			Op: LessOp,
@@ -39,10 +43,14 @@ type GreatorExp struct {
 }
 
 func (e GreatorExp) Calc() *Value {
-	return e.Rh.Calc().Less(e.Lh.Calc())
+	return e.Eval(nil)
+}
+
+func (e GreatorExp) Eval(env Env) *Value {
+	return coercionOf(env).Less(e.Rh.Eval(env), e.Lh.Eval(env))
 }
 
-func NewGreatorExp(lh, rh *Value) Expression {
+func NewGreatorExp(lh, rh Expression) Expression {
 	return &GreatorExp{
 		BinOpExp{
 			Op: GreaterOp,
@@ -57,10 +65,14 @@ type EqualExp struct {
 }
 
 func (e EqualExp) Calc() *Value {
-	return e.Lh.Calc().Equal(e.Rh.Calc())
+	return e.Eval(nil)
 }
 
-func NewEqualExp(lh, rh *Value) Expression {
+func (e EqualExp) Eval(env Env) *Value {
+	return coercionOf(env).Equal(e.Lh.Eval(env), e.Rh.Eval(env))
+}
+
+func NewEqualExp(lh, rh Expression) Expression {
 	return &EqualExp{
 		BinOpExp{
 			Op: EqualOp,
@@ -75,15 +87,23 @@ type LessEqExp struct {
 }
 
 func (e LessEqExp) Calc() *Value {
-	lv := e.Lh.Calc()
-	rv := e.Rh.Calc()
+	return e.Eval(nil)
+}
+
+func (e LessEqExp) Eval(env Env) *Value {
+	lv := e.Lh.Eval(env)
+	rv := e.Rh.Eval(env)
 
-	eq := lv.Equal(rv)
-	lt := lv.Less(rv)
+	c := coercionOf(env)
+	eq := c.Equal(lv, rv)
+	if eq.HasError() {
+		return eq
+	}
+	lt := c.Less(lv, rv)
 	return lt.Or(eq)
 }
 
-func NewLessEqExp(lh, rh *Value) Expression {
+func NewLessEqExp(lh, rh Expression) Expression {
 	return &LessEqExp{
 		BinOpExp{
 			Op: LessEqOp,
@@ -98,15 +118,23 @@ type GreaterEqExp struct {
 }
 
 func (e GreaterEqExp) Calc() *Value {
-	lv := e.Lh.Calc()
-	rv := e.Rh.Calc()
+	return e.Eval(nil)
+}
 
-	eq := lv.Equal(rv)
-	gt := rv.Less(lv)
+func (e GreaterEqExp) Eval(env Env) *Value {
+	lv := e.Lh.Eval(env)
+	rv := e.Rh.Eval(env)
+
+	c := coercionOf(env)
+	eq := c.Equal(lv, rv)
+	if eq.HasError() {
+		return eq
+	}
+	gt := c.Less(rv, lv)
 	return gt.Or(eq)
 }
 
-func NewGreaterEqExp(lh, rh *Value) Expression {
+func NewGreaterEqExp(lh, rh Expression) Expression {
 	return &GreaterEqExp{
 		BinOpExp{
 			Op: GreaterEqOp,
@@ -121,14 +149,21 @@ type NotEqExp struct {
 }
 
 func (e NotEqExp) Calc() *Value {
-	lv := e.Lh.Calc()
-	rv := e.Rh.Calc()
+	return e.Eval(nil)
+}
+
+func (e NotEqExp) Eval(env Env) *Value {
+	lv := e.Lh.Eval(env)
+	rv := e.Rh.Eval(env)
 
-	eq := lv.Equal(rv)
+	eq := coercionOf(env).Equal(lv, rv)
+	if eq.HasError() {
+		return eq
+	}
 	return eq.Not()
 }
 
-func NewNotEqExp(lh, rh *Value) Expression {
+func NewNotEqExp(lh, rh Expression) Expression {
 	return &NotEqExp{
 		BinOpExp{
 			Op: NotEqOp,
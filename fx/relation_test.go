@@ -75,6 +75,47 @@ func TestRegisterNewBinOpExpCreator(t *testing.T) {
 	}
 }
 
+func TestRegisterNewBinOpExpCreatorExpression(t *testing.T) {
+	customOp := Operator("CUSTOM_EXP")
+	RegisterNewBinOpExpCreator(customOp, func(lh, rh Expression) Expression {
+		return &LessExp{BinOpExp{Op: customOp, Lh: lh, Rh: rh}}
+	})
+
+	// Verify it was registered
+	exp, err := NewBinOpExp(customOp, Number(1), Number(2))
+	if err != nil {
+		t.Errorf("NewBinOpExp() after registration failed: %v", err)
+	}
+	if exp == nil {
+		t.Error("NewBinOpExp() returned nil after registration")
+	}
+}
+
+func TestRegisterNewBinOpExpCreatorValues(t *testing.T) {
+	startsOp := Operator("^^")
+	RegisterNewBinOpExpCreator(startsOp, func(lh, rh *Value) Expression {
+		return &EqualExp{BinOpExp{Op: startsOp, Lh: lh, Rh: rh}}
+	})
+	defer delete(binOpExpCreators, startsOp)
+
+	exp, err := Parse(`name ^^ "a"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := exp.Eval(MapEnv{"name": "a"}); !got.True() {
+		t.Errorf("Eval() = %v, want true", got)
+	}
+	if got := exp.Eval(MapEnv{"name": "b"}); !got.False() {
+		t.Errorf("Eval() = %v, want false", got)
+	}
+	if got := Format(exp); got != `name ^^ "a"` {
+		t.Errorf("Format() = %s", got)
+	}
+	if got := PosOf(exp); got != (Position{Line: 1, Column: 6}) {
+		t.Errorf("PosOf() = %v", got)
+	}
+}
+
 func TestLessExp_Calc(t *testing.T) {
 	tests := []struct {
 		name string
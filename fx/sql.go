@@ -0,0 +1,404 @@
+package fx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// SQLDialect adapts the SQL generated by ToSQL to a database.
+type SQLDialect struct {
+	// Placeholder returns the bind marker of the n-th (1-based) argument.
+	Placeholder func(n int) string
+	// QuoteIdent quotes one segment of a column name; `row.price` is quoted
+	// segment by segment.
+	QuoteIdent func(name string) string
+	// LikeEscape escapes '%', '_' and itself in LIKE patterns.
+	LikeEscape rune
+	// RegexpOp and NotRegexpOp implement =~ and !~; left empty, the
+	// operators are reported as unsupported.
+	RegexpOp    string
+	NotRegexpOp string
+	// ConcatFunc joins text for + with a function, such as CONCAT(a, b);
+	// left empty, the || operator is used.
+	ConcatFunc string
+}
+
+var (
+	// SQLiteDialect needs a REGEXP function registered with the connection
+	// for =~ and !~.
+	SQLiteDialect = &SQLDialect{
+		Placeholder: func(n int) string { return "?" },
+		QuoteIdent:  quoteIdentWith(`"`),
+		LikeEscape:  '\\',
+		RegexpOp:    "REGEXP",
+		NotRegexpOp: "NOT REGEXP",
+	}
+	// MySQLDialect escapes LIKE patterns with '!' since backslash is itself
+	// an escape in MySQL string literals.
+	MySQLDialect = &SQLDialect{
+		Placeholder: func(n int) string { return "?" },
+		QuoteIdent:  quoteIdentWith("`"),
+		LikeEscape:  '!',
+		RegexpOp:    "REGEXP",
+		NotRegexpOp: "NOT REGEXP",
+		ConcatFunc:  "CONCAT",
+	}
+	PostgresDialect = &SQLDialect{
+		Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		QuoteIdent:  quoteIdentWith(`"`),
+		LikeEscape:  '\\',
+		RegexpOp:    "~",
+		NotRegexpOp: "!~",
+	}
+)
+
+func quoteIdentWith(q string) func(string) string {
+	return func(name string) string {
+		return q + strings.ReplaceAll(name, q, q+q) + q
+	}
+}
+
+// sqlFuncs maps the fx functions that have a portable SQL counterpart.
+var sqlFuncs = map[string]string{
+	"lower":    "LOWER",
+	"upper":    "UPPER",
+	"trim":     "TRIM",
+	"abs":      "ABS",
+	"coalesce": "COALESCE",
+}
+
+const (
+	sqlPrecOr = iota + 1
+	sqlPrecAnd
+	sqlPrecNot
+	sqlPrecRelation
+	sqlPrecAdditive
+	sqlPrecMultiply
+	sqlPrecUnary
+	sqlPrecPrimary
+)
+
+var sqlBinOps = map[Operator]struct {
+	sql  string
+	prec int
+}{
+	LessOp:      {"<", sqlPrecRelation},
+	GreaterOp:   {">", sqlPrecRelation},
+	EqualOp:     {"=", sqlPrecRelation},
+	LessEqOp:    {"<=", sqlPrecRelation},
+	GreaterEqOp: {">=", sqlPrecRelation},
+	NotEqOp:     {"<>", sqlPrecRelation},
+	AddOp:       {"+", sqlPrecAdditive},
+	SubOp:       {"-", sqlPrecAdditive},
+	MulOp:       {"*", sqlPrecMultiply},
+	DivOp:       {"/", sqlPrecMultiply},
+	ModOp:       {"%", sqlPrecMultiply},
+	AndOp:       {"AND", sqlPrecAnd},
+	OrOp:        {"OR", sqlPrecOr},
+}
+
+// ToSQL compiles e into a parameterised WHERE fragment for dialect d.
+// Literals become bind arguments; variables become quoted column names,
+// typed by schema as in Check; a nil schema leaves them AnyType. fx and SQL
+// share three-valued null logic, so filters give the same rows in both
+// places, with two caveats: ~= becomes LIKE, whose case sensitivity depends
+// on the collation, and + is numeric addition unless an operand is text,
+// by its literal, function or schema type.
+//
+// An empty in list becomes 1 = 0, and an empty not in list 1 = 1, since SQL
+// has no empty lists. ~= needs a text literal on the right, since the
+// pattern has to be escaped. + of text and a number, and operators and
+// functions without an SQL counterpart, are errors.
+func ToSQL(e Expression, d *SQLDialect, schema Schema) (string, []any, *util.Result) {
+	c := &sqlCompiler{d: d, types: &checker{schema: schema}}
+	sb := &strings.Builder{}
+	if err := c.compile(sb, e); err != nil {
+		return "", nil, err.With("ToSQL")
+	}
+	return sb.String(), c.args, nil
+}
+
+type sqlCompiler struct {
+	d     *SQLDialect
+	types *checker // infers the types of operands; its issues are ignored
+	args  []any
+}
+
+func (c *sqlCompiler) bind(sb *strings.Builder, arg any) {
+	c.args = append(c.args, arg)
+	sb.WriteString(c.d.Placeholder(len(c.args)))
+}
+
+func (c *sqlCompiler) column(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = c.d.QuoteIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// sqlPrec is the binding power of the SQL generated for e.
+func sqlPrec(e Expression) int {
+	switch x := e.(type) {
+	case Expr:
+		return sqlPrec(x.Expression)
+	case BinaryExpression:
+		if op, ok := sqlBinOps[x.BinOp().Op]; ok {
+			return op.prec
+		}
+		return sqlPrecRelation
+	case UnaryExpression:
+		switch x.UnaryOp().Op {
+		case NotOp:
+			return sqlPrecNot
+		case NegOp:
+			return sqlPrecUnary
+		}
+		return sqlPrecRelation
+	}
+	return sqlPrecPrimary
+}
+
+// operand compiles e, in parentheses if it binds looser than minPrec.
+func (c *sqlCompiler) operand(sb *strings.Builder, e Expression, minPrec int) *util.Result {
+	if sqlPrec(e) >= minPrec {
+		return c.compile(sb, e)
+	}
+	sb.WriteString("(")
+	if err := c.compile(sb, e); err != nil {
+		return err
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+func (c *sqlCompiler) compile(sb *strings.Builder, e Expression) *util.Result {
+	switch x := e.(type) {
+	case nil:
+		return util.MsgError("SQL", "nil expression")
+	case Expr:
+		return c.compile(sb, x.Expression)
+	case *Value:
+		return c.value(sb, x)
+	case *VarExp:
+		sb.WriteString(c.column(x.Name))
+		return nil
+	case *CallExp:
+		return c.call(sb, x)
+	case *ListExp:
+		return util.MsgError("SQL", "a list is only allowed after in, not in and between")
+	case BinaryExpression:
+		return c.binOp(sb, x.BinOp())
+	case UnaryExpression:
+		return c.unaryOp(sb, x.UnaryOp())
+	}
+	return util.MsgError("SQL", fmt.Sprintf("unsupported expression type %T", e))
+}
+
+func (c *sqlCompiler) value(sb *strings.Builder, v *Value) *util.Result {
+	switch {
+	case v.HasError():
+		return v.Error.With("SQL")
+	case v.IsNull():
+		sb.WriteString("NULL")
+	case v.IsBool():
+		c.bind(sb, v.True())
+	case v.IsTime():
+		c.bind(sb, *v.Time)
+	case v.Decimal != nil:
+		// as text, which databases convert to NUMERIC without rounding
+		c.bind(sb, v.Decimal.String())
+	case v.IsNumeric:
+		c.bind(sb, util.MaybeNil(v.Number))
+	default:
+		c.bind(sb, util.MaybeNil(v.Text))
+	}
+	return nil
+}
+
+func (c *sqlCompiler) call(sb *strings.Builder, e *CallExp) *util.Result {
+	name, ok := sqlFuncs[e.Name]
+	if !ok {
+		return util.MsgError("SQL", "no SQL equivalent for function: "+e.Name)
+	}
+	sb.WriteString(name)
+	return c.list(sb, e.Args)
+}
+
+func (c *sqlCompiler) list(sb *strings.Builder, items []Expression) *util.Result {
+	sb.WriteString("(")
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if err := c.compile(sb, item); err != nil {
+			return err
+		}
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+func (c *sqlCompiler) binOp(sb *strings.Builder, b BinOpExp) *util.Result {
+	switch b.Op {
+	case InOp, NotInOp:
+		return c.in(sb, b)
+	case BetweenOp:
+		return c.between(sb, b)
+	case IncludeOp:
+		return c.like(sb, b)
+	case AddOp:
+		if text, err := c.textAdd(b); err != nil || text {
+			if err != nil {
+				return err
+			}
+			return c.concat(sb, b)
+		}
+	case MatchOp, NotMatchOp:
+		op := c.d.RegexpOp
+		if b.Op == NotMatchOp {
+			op = c.d.NotRegexpOp
+		}
+		if op == "" {
+			return util.MsgError("SQL", "no SQL equivalent for operator in this dialect: "+string(b.Op))
+		}
+		return c.infix(sb, b.Lh, op, b.Rh, sqlPrecRelation)
+	}
+	op, ok := sqlBinOps[b.Op]
+	if !ok {
+		return util.MsgError("SQL", "no SQL equivalent for operator: "+string(b.Op))
+	}
+	return c.infix(sb, b.Lh, op.sql, b.Rh, op.prec)
+}
+
+// infix writes `lh op rh`. AND, OR and arithmetic are left-associative;
+// comparisons do not chain in SQL, so a comparison operand is always
+// parenthesised.
+func (c *sqlCompiler) infix(sb *strings.Builder, lh Expression, op string, rh Expression, prec int) *util.Result {
+	lhPrec := prec
+	if prec == sqlPrecRelation {
+		lhPrec++
+	}
+	if err := c.operand(sb, lh, lhPrec); err != nil {
+		return err.With("LH")
+	}
+	sb.WriteString(" " + op + " ")
+	if err := c.operand(sb, rh, prec+1); err != nil {
+		return err.With("RH")
+	}
+	return nil
+}
+
+// textAdd reports whether + joins text, as it does in fx when an operand is
+// text; text and a number can't be added.
+func (c *sqlCompiler) textAdd(b BinOpExp) (bool, *util.Result) {
+	lt, rt := c.types.typeOf(b.Lh), c.types.typeOf(b.Rh)
+	if lt != TextType && rt != TextType {
+		return false, nil
+	}
+	if !accepts(TextType, lt) || !accepts(TextType, rt) {
+		return false, util.MsgError("SQL", fmt.Sprintf("cannot add %s and %s", lt, rt))
+	}
+	return true, nil
+}
+
+// concat joins the text operands of + by the dialect's ConcatFunc or ||.
+func (c *sqlCompiler) concat(sb *strings.Builder, b BinOpExp) *util.Result {
+	if c.d.ConcatFunc != "" {
+		sb.WriteString(c.d.ConcatFunc)
+		return c.list(sb, []Expression{b.Lh, b.Rh})
+	}
+	if err := c.operand(sb, b.Lh, sqlPrecUnary); err != nil {
+		return err.With("LH")
+	}
+	sb.WriteString(" || ")
+	if err := c.operand(sb, b.Rh, sqlPrecUnary); err != nil {
+		return err.With("RH")
+	}
+	return nil
+}
+
+func (c *sqlCompiler) in(sb *strings.Builder, b BinOpExp) *util.Result {
+	items := listItems(b.Rh)
+	if len(items) == 0 {
+		if b.Op == NotInOp {
+			sb.WriteString("1 = 1")
+		} else {
+			sb.WriteString("1 = 0")
+		}
+		return nil
+	}
+	if err := c.operand(sb, b.Lh, sqlPrecRelation+1); err != nil {
+		return err.With("LH")
+	}
+	if b.Op == NotInOp {
+		sb.WriteString(" NOT IN ")
+	} else {
+		sb.WriteString(" IN ")
+	}
+	if err := c.list(sb, items); err != nil {
+		return err.With("RH")
+	}
+	return nil
+}
+
+func (c *sqlCompiler) between(sb *strings.Builder, b BinOpExp) *util.Result {
+	bounds := listItems(b.Rh)
+	if len(bounds) != 2 {
+		return util.MsgError("SQL", "between needs a list of two bounds")
+	}
+	if err := c.operand(sb, b.Lh, sqlPrecRelation+1); err != nil {
+		return err.With("LH")
+	}
+	sb.WriteString(" BETWEEN ")
+	if err := c.operand(sb, bounds[0], sqlPrecRelation+1); err != nil {
+		return err.With("Lower Bound")
+	}
+	sb.WriteString(" AND ")
+	if err := c.operand(sb, bounds[1], sqlPrecRelation+1); err != nil {
+		return err.With("Upper Bound")
+	}
+	return nil
+}
+
+// like compiles `lh ~= "text"` to `lh LIKE '%text%'` with the text escaped.
+func (c *sqlCompiler) like(sb *strings.Builder, b BinOpExp) *util.Result {
+	v, ok := b.Rh.(*Value)
+	if !ok || v.IsNumeric || v.Text == nil {
+		return util.MsgError("SQL", "~= needs a text literal on the right")
+	}
+	if err := c.operand(sb, b.Lh, sqlPrecRelation+1); err != nil {
+		return err.With("LH")
+	}
+	esc := string(c.d.LikeEscape)
+	pattern := strings.NewReplacer(esc, esc+esc, "%", esc+"%", "_", esc+"_").Replace(*v.Text)
+	sb.WriteString(" LIKE ")
+	c.bind(sb, "%"+pattern+"%")
+	sb.WriteString(" ESCAPE '" + strings.ReplaceAll(esc, "'", "''") + "'")
+	return nil
+}
+
+func (c *sqlCompiler) unaryOp(sb *strings.Builder, u UnaryOpExp) *util.Result {
+	switch u.Op {
+	case NotOp:
+		sb.WriteString("NOT ")
+		return c.operand(sb, u.X, sqlPrecNot)
+	case NegOp:
+		sb.WriteString("-")
+		return c.operand(sb, u.X, sqlPrecPrimary)
+	case IsNullOp, IsNotNullOp:
+		if err := c.operand(sb, u.X, sqlPrecRelation+1); err != nil {
+			return err
+		}
+		if u.Op == IsNullOp {
+			sb.WriteString(" IS NULL")
+		} else {
+			sb.WriteString(" IS NOT NULL")
+		}
+		return nil
+	}
+	return util.MsgError("SQL", "no SQL equivalent for operator: "+string(u.Op))
+}
@@ -0,0 +1,140 @@
+package fx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestToSQL(t *testing.T) {
+	when := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		input   string
+		dialect *SQLDialect
+		schema  Schema
+		want    string
+		args    []any
+		wantErr bool
+	}{
+		{name: "less", input: `price < 10`, want: `"price" < ?`, args: []any{10.0}},
+		{name: "greater", input: `price > 10`, want: `"price" > ?`, args: []any{10.0}},
+		{name: "equal", input: `name == "bob"`, want: `"name" = ?`, args: []any{"bob"}},
+		{name: "less equal", input: `price <= 10`, want: `"price" <= ?`, args: []any{10.0}},
+		{name: "greater equal", input: `price >= 10`, want: `"price" >= ?`, args: []any{10.0}},
+		{name: "not equal", input: `price != 10`, want: `"price" <> ?`, args: []any{10.0}},
+		{name: "include", input: `msg ~= "disk"`, want: `"msg" LIKE ? ESCAPE '\'`, args: []any{"%disk%"}},
+		{name: "include escaped", input: `msg ~= "100%_a\\b"`, want: `"msg" LIKE ? ESCAPE '\'`, args: []any{`%100\%\_a\\b%`}},
+		{name: "include mysql", input: `msg ~= "50%!"`, dialect: MySQLDialect, want: "`msg` LIKE ? ESCAPE '!'", args: []any{"%50!%!!%"}},
+		{name: "include needs literal", input: `msg ~= other`, wantErr: true},
+		{name: "match", input: `code =~ "^E"`, want: `"code" REGEXP ?`, args: []any{"^E"}},
+		{name: "not match postgres", input: `code !~ "^E"`, dialect: PostgresDialect, want: `"code" !~ $1`, args: []any{"^E"}},
+		{name: "match unsupported", input: `code =~ "^E"`, dialect: &SQLDialect{Placeholder: SQLiteDialect.Placeholder, QuoteIdent: SQLiteDialect.QuoteIdent}, wantErr: true},
+		{name: "in", input: `status in ("failed", "timeout")`, want: `"status" IN (?, ?)`, args: []any{"failed", "timeout"}},
+		{name: "not in", input: `status not in ("ok",)`, want: `"status" NOT IN (?)`, args: []any{"ok"}},
+		{name: "empty in", input: `status in ()`, want: `1 = 0`},
+		{name: "empty not in", input: `status not in () && a > 1`, want: `1 = 1 AND "a" > ?`, args: []any{1.0}},
+		{name: "not empty in", input: `!(status in ())`, want: `NOT 1 = 0`},
+		{name: "between", input: `x between 1 and 10`, want: `"x" BETWEEN ? AND ?`, args: []any{1.0, 10.0}},
+		{name: "between expressions", input: `x between a - 1 and a + 1`, want: `"x" BETWEEN "a" - ? AND "a" + ?`, args: []any{1.0, 1.0}},
+		{name: "arithmetic", input: `(a + b) * c % 2 - d / 4 > 0`, want: `("a" + "b") * "c" % ? - "d" / ? > ?`, args: []any{2.0, 4.0, 0.0}},
+		{name: "right grouping", input: `a - (b - c) == 0`, want: `"a" - ("b" - "c") = ?`, args: []any{0.0}},
+		{name: "neg", input: `-a < -(b + 1)`, want: `-"a" < -("b" + ?)`, args: []any{1.0}},
+		{name: "double neg", input: `--a == 1`, want: `-(-"a") = ?`, args: []any{1.0}},
+		{name: "is null", input: `a is null || b is not null`, want: `"a" IS NULL OR "b" IS NOT NULL`},
+		{name: "and or", input: `a > 1 && (b < 2 || c == 3)`, want: `"a" > ? AND ("b" < ? OR "c" = ?)`, args: []any{1.0, 2.0, 3.0}},
+		{name: "or and", input: `a > 1 || b < 2 && c == 3`, want: `"a" > ? OR "b" < ? AND "c" = ?`, args: []any{1.0, 2.0, 3.0}},
+		{name: "not", input: `!(a == 1) && !flag`, want: `NOT "a" = ? AND NOT "flag"`, args: []any{1.0}},
+		{name: "not of and", input: `!(a && b)`, want: `NOT ("a" AND "b")`},
+		{name: "chained relation", input: `a < b == true`, want: `("a" < "b") = ?`, args: []any{true}},
+		{name: "null literal", input: `a == null`, want: `"a" = NULL`},
+		{name: "bool literal", input: `active == false`, want: `"active" = ?`, args: []any{false}},
+		{name: "time literal", input: `at >= date("2024-03-01T00:00:00Z")`, wantErr: true},
+		{name: "dotted column", input: `row.price > 1`, want: `"row"."price" > ?`, args: []any{1.0}},
+		{name: "functions", input: `lower(trim(name)) == "bob" && coalesce(region, "x") != "x"`, want: `LOWER(TRIM("name")) = ? AND COALESCE("region", ?) <> ?`, args: []any{"bob", "x", "x"}},
+		{name: "function without sql", input: `len(name) > 3`, wantErr: true},
+		{name: "add numbers", input: `a + b > 1`, schema: Schema{"a": NumberType, "b": NumberType}, want: `"a" + "b" > ?`, args: []any{1.0}},
+		{name: "concat text columns", input: `first + last == "ab"`, schema: Schema{"first": TextType, "last": TextType}, want: `"first" || "last" = ?`, args: []any{"ab"}},
+		{name: "concat literal", input: `name + "!" == "a!"`, want: `"name" || ? = ?`, args: []any{"!", "a!"}},
+		{name: "concat nested", input: `a + b + lower(c) == "x"`, schema: Schema{"a": TextType, "b": AnyType, "c": TextType}, want: `("a" || "b") || LOWER("c") = ?`, args: []any{"x"}},
+		{name: "concat postgres", input: `first + last == "ab"`, dialect: PostgresDialect, schema: Schema{"first": TextType, "last": TextType}, want: `"first" || "last" = $1`, args: []any{"ab"}},
+		{name: "concat mysql", input: `first + last == "ab"`, dialect: MySQLDialect, schema: Schema{"first": TextType, "last": TextType}, want: "CONCAT(`first`, `last`) = ?", args: []any{"ab"}},
+		{name: "add text and number", input: `name + qty == "a1"`, schema: Schema{"name": TextType, "qty": NumberType}, wantErr: true},
+		{name: "postgres placeholders", input: `a == 1 && b in (2, 3)`, dialect: PostgresDialect, want: `"a" = $1 AND "b" IN ($2, $3)`, args: []any{1.0, 2.0, 3.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			d := tt.dialect
+			if d == nil {
+				d = SQLiteDialect
+			}
+			got, args, err := ToSQL(exp, d, tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToSQL(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ToSQL(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+			if len(args) != 0 || len(tt.args) != 0 {
+				if !reflect.DeepEqual(args, tt.args) {
+					t.Errorf("ToSQL(%q) args = %v, want %v", tt.input, args, tt.args)
+				}
+			}
+		})
+	}
+
+	t.Run("time literal", func(t *testing.T) {
+		got, args, err := ToSQL(NewGreaterEqExp(NewVarExp("at"), Time(when)), SQLiteDialect, nil)
+		if err != nil {
+			t.Fatalf("ToSQL() error = %v", err)
+		}
+		if want := `"at" >= ?`; got != want {
+			t.Errorf("ToSQL() = %s, want %s", got, want)
+		}
+		if !reflect.DeepEqual(args, []any{when}) {
+			t.Errorf("ToSQL() args = %v, want %v", args, []any{when})
+		}
+	})
+
+	t.Run("quoted identifiers", func(t *testing.T) {
+		got, _, err := ToSQL(NewIsNullExp(NewVarExp(`a"b`)), SQLiteDialect, nil)
+		if err != nil {
+			t.Fatalf("ToSQL() error = %v", err)
+		}
+		if want := `"a""b" IS NULL`; got != want {
+			t.Errorf("ToSQL() = %s, want %s", got, want)
+		}
+		got, _, err = ToSQL(NewIsNullExp(NewVarExp("a`b")), MySQLDialect, nil)
+		if err != nil {
+			t.Fatalf("ToSQL() error = %v", err)
+		}
+		if want := "`a``b` IS NULL"; got != want {
+			t.Errorf("ToSQL() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		exps := []Expression{
+			nil,
+			NewNotExp(Error(util.MsgError("test", "error"))),
+			NewListExp(Number(1)),
+			&countingExp{},
+			NewBetweenExp(NewVarExp("x"), NewListExp(Number(1))),
+		}
+		for _, e := range exps {
+			if _, _, err := ToSQL(e, SQLiteDialect, nil); err == nil {
+				t.Errorf("ToSQL(%v) error = nil, want error", e)
+			}
+		}
+	})
+}
@@ -1,15 +1,21 @@
 package fx
 
 import (
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/soderasen-au/go-common/util"
 )
 
-//IncludeOp Operator = "~="
+//IncludeOp  Operator = "~="
+//MatchOp    Operator = "=~"
+//NotMatchOp Operator = "!~"
 
 func init() {
 	RegisterNewBinOpExpCreator(IncludeOp, NewIncludeExp)
+	RegisterNewBinOpExpCreator(MatchOp, NewMatchExp)
+	RegisterNewBinOpExpCreator(NotMatchOp, NewNotMatchExp)
 }
 
 type IncludeExp struct {
@@ -17,18 +23,22 @@ type IncludeExp struct {
 }
 
 func (e IncludeExp) Calc() *Value {
-	lv := e.Lh.Calc()
-	rv := e.Rh.Calc()
+	return e.Eval(nil)
+}
+
+func (e IncludeExp) Eval(env Env) *Value {
+	lv := e.Lh.Eval(env)
+	rv := e.Rh.Eval(env)
 	if lv.HasError() {
 		return Error(lv.Error.With("LH Error"))
 	}
 	if rv.HasError() {
 		return Error(rv.Error.With("RH Error"))
 	}
-	if lv.IsNumeric {
+	if lv.IsNumeric && !lv.IsDual() {
 		return Error(util.MsgError("IncludeExpOperands", "LV is numeric"))
 	}
-	if rv.IsNumeric {
+	if rv.IsNumeric && !rv.IsDual() {
 		return Error(util.MsgError("IncludeExpOperands", "RV is numeric"))
 	}
 	if lv.Text == nil {
@@ -40,10 +50,110 @@ func (e IncludeExp) Calc() *Value {
 	return Bool(strings.Contains(*lv.Text, *rv.Text))
 }
 
-func NewIncludeExp(lh, rh *Value) Expression {
+func NewIncludeExp(lh, rh Expression) Expression {
 	return &IncludeExp{
 		BinOpExp{
-			Op: LessOp,
+			Op: IncludeOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+// maxCachedPatterns bounds the compiled-pattern cache; it is cleared when full.
+const maxCachedPatterns = 1024
+
+var (
+	patternsMu sync.RWMutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+// compilePattern compiles a regular expression once and caches it, so that
+// an expression evaluated per row does not recompile its pattern.
+func compilePattern(pattern string) (*regexp.Regexp, *util.Result) {
+	patternsMu.RLock()
+	re, ok := patterns[pattern]
+	patternsMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, util.Error("CompilePattern", err)
+	}
+	patternsMu.Lock()
+	if len(patterns) >= maxCachedPatterns {
+		patterns = map[string]*regexp.Regexp{}
+	}
+	patterns[pattern] = re
+	patternsMu.Unlock()
+	return re, nil
+}
+
+// matchValues reports whether the text lv matches the pattern rv.
+func matchValues(lv, rv *Value) *Value {
+	if lv.HasError() {
+		return Error(lv.Error.With("LH Error"))
+	}
+	if rv.HasError() {
+		return Error(rv.Error.With("RH Error"))
+	}
+	if !lv.isText() {
+		return Error(util.MsgError("MatchExpOperands", "LV is not text"))
+	}
+	if !rv.isText() {
+		return Error(util.MsgError("MatchExpOperands", "RV is not text"))
+	}
+	re, err := compilePattern(*rv.Text)
+	if err != nil {
+		return Error(err)
+	}
+	return Bool(re.MatchString(*lv.Text))
+}
+
+type MatchExp struct {
+	BinOpExp
+}
+
+func (e MatchExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e MatchExp) Eval(env Env) *Value {
+	return matchValues(e.Lh.Eval(env), e.Rh.Eval(env))
+}
+
+func NewMatchExp(lh, rh Expression) Expression {
+	return &MatchExp{
+		BinOpExp{
+			Op: MatchOp,
+			Lh: lh,
+			Rh: rh,
+		},
+	}
+}
+
+type NotMatchExp struct {
+	BinOpExp
+}
+
+func (e NotMatchExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e NotMatchExp) Eval(env Env) *Value {
+	m := matchValues(e.Lh.Eval(env), e.Rh.Eval(env))
+	if m.HasError() {
+		return m
+	}
+	return m.Not()
+}
+
+func NewNotMatchExp(lh, rh Expression) Expression {
+	return &NotMatchExp{
+		BinOpExp{
+			Op: NotMatchOp,
 			Lh: lh,
 			Rh: rh,
 		},
@@ -159,3 +159,92 @@ func TestNewIncludeExp(t *testing.T) {
 		t.Error("NewIncludeExp() Rh not set correctly")
 	}
 }
+
+func TestMatchExp_Calc(t *testing.T) {
+	tests := []struct {
+		name    string
+		lh      *Value
+		rh      *Value
+		want    bool
+		wantErr bool
+	}{
+		{name: "matches", lh: Text("nightly-reload-42"), rh: Text(`^nightly-\w+-\d+$`), want: true},
+		{name: "partial match", lh: Text("job-reload"), rh: Text("reload"), want: true},
+		{name: "no match", lh: Text("hourly"), rh: Text("^nightly"), want: false},
+		{name: "invalid pattern", lh: Text("a"), rh: Text("("), wantErr: true},
+		{name: "numeric lh", lh: Number(1), rh: Text("1"), wantErr: true},
+		{name: "numeric pattern", lh: Text("1"), rh: Number(1), wantErr: true},
+		{name: "left has error", lh: Error(util.MsgError("test", "error")), rh: Text("a"), wantErr: true},
+		{name: "right has error", lh: Text("a"), rh: Error(util.MsgError("test", "error")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMatchExp(tt.lh, tt.rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("MatchExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() != tt.want {
+				t.Errorf("MatchExp.Calc() = %v, want %v", got, tt.want)
+			}
+
+			got = NewNotMatchExp(tt.lh, tt.rh).Calc()
+			if got.HasError() != tt.wantErr {
+				t.Fatalf("NotMatchExp.Calc() error = %v, wantErr %v", got.Error, tt.wantErr)
+			}
+			if !tt.wantErr && got.True() == tt.want {
+				t.Errorf("NotMatchExp.Calc() = %v, want %v", got, !tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePattern_Cache(t *testing.T) {
+	first, err := compilePattern("^cached-[0-9]+$")
+	if err != nil {
+		t.Fatalf("compilePattern() error = %v", err)
+	}
+	second, err := compilePattern("^cached-[0-9]+$")
+	if err != nil {
+		t.Fatalf("compilePattern() error = %v", err)
+	}
+	if first != second {
+		t.Error("compilePattern() should return the cached regexp")
+	}
+}
+
+func TestTextDual(t *testing.T) {
+	row := MapEnv{"code": Dual("007"), "zip": Dual("02134"), "n": 7}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `code ~= "07"`, want: `true`},
+		{input: `"x007" ~= code`, want: `true`},
+		{input: `code =~ "^00"`, want: `true`},
+		{input: `zip !~ "^1"`, want: `true`},
+		{input: `startsWith(zip, "02")`, want: `true`},
+		{input: `lower(code)`, want: `"007"`},
+		{input: `len(zip)`, want: `5`},
+		{input: `len(n)`, want: `error`},
+		{input: `n ~= "7"`, want: `error`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []Coercion{CoerceStrict, CoerceNumeric, CoerceText} {
+				got := e.Eval(WithCoercion(row, c))
+				s := "error"
+				if !got.HasError() {
+					s = Format(got)
+				}
+				if s != tt.want {
+					t.Errorf("%s: Eval() = %s, want %s", c, s, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package fx
+
+import (
+	"fmt"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// RegisterTplFuncs adds two expression functions to util.RenderTpl
+// templates, evaluated against the util.TplVarMap variables:
+//
+//	$eval(expr)            the value of expr, e.g. $eval(count * 2)
+//	$if(expr|then|else)    then if expr is true, else otherwise; else may
+//	                       be left out
+//
+// Variables are compared as by CoerceNumeric, so "10" > "9", and unknown
+// ones are null. A template whose expression does not parse or evaluate is
+// left as is by util.RenderTpl and fails util.RenderTplResult.
+func RegisterTplFuncs() {
+	util.TplExprFuncMap["eval"] = tplEval
+	util.TplExprFuncMap["if"] = tplIf
+}
+
+// tplEnv resolves variables from util.TplVarMap. Values are read with Dual,
+// so numbers can be compared and computed with, and still used as text.
+var tplEnv = WithCoercion(EnvFunc(func(name string) (*Value, bool) {
+	varFunc, ok := util.TplVarMap[name]
+	if !ok {
+		return Nil(), true
+	}
+	return Dual(varFunc(name)), true
+}), CoerceNumeric)
+
+func tplEvalParam(name, expr string) (*Value, *util.Result) {
+	e, err := Parse(expr)
+	if err != nil {
+		return nil, err.With(name)
+	}
+	v := e.Eval(tplEnv)
+	if v.HasError() {
+		return nil, v.Error.With(name)
+	}
+	return v, nil
+}
+
+func tplEval(params []string) (string, *util.Result) {
+	if len(params) != 1 {
+		return "", util.MsgError("eval", fmt.Sprintf("expected 1 parameter, got %d", len(params)))
+	}
+	v, err := tplEvalParam("eval", params[0])
+	if err != nil {
+		return "", err
+	}
+	if v.IsNull() {
+		return "", nil
+	}
+	return *fnText([]*Value{v}).Text, nil
+}
+
+func tplIf(params []string) (string, *util.Result) {
+	if len(params) < 2 || len(params) > 3 {
+		return "", util.MsgError("if", fmt.Sprintf("expected 2 or 3 parameters, got %d", len(params)))
+	}
+	v, err := tplEvalParam("if", params[0])
+	if err != nil {
+		return "", err
+	}
+	if !v.IsBool() && !v.IsNull() {
+		return "", util.MsgError("if", "condition is not bool")
+	}
+	if v.True() {
+		return params[1], nil
+	}
+	if len(params) == 3 {
+		return params[2], nil
+	}
+	return "", nil
+}
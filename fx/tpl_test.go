@@ -0,0 +1,62 @@
+package fx
+
+import (
+	"testing"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+func TestRenderTplExpressions(t *testing.T) {
+	RegisterTplFuncs()
+	vars := map[string]string{"count": "5", "status": "failed", "code": "007", "task": "backup", "total": "10", "limit": "9", "zip": "02134"}
+	util.TplVarMap = util.TplVarMapType{}
+	for name, value := range vars {
+		util.TplVarMap[name] = func(string) string { return value }
+	}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: `$eval(count * 2)`, want: "10"},
+		{input: `$eval(code)`, want: "007"},
+		{input: `$eval(upper(task) + "-" + status)`, want: "BACKUP-failed"},
+		{input: `$eval(dec("10") / 4)`, want: "2.5"},
+		{input: `$eval(null)`, want: ""},
+		{input: `$if(count > 3|many|few)`, want: "many"},
+		{input: `$if(count > 3 && status == "ok"|many|few)`, want: "few"},
+		{input: `$if(count > 9 || status == "failed"|alert)`, want: "alert"},
+		{input: `$if(status == "ok"|alert)`, want: ""},
+		{input: `$if(missing is null|x|y)`, want: "x"},
+		{input: `$if(total > limit|over|under)`, want: "over"},
+		{input: `$if(zip ~= "021"|boston|other)`, want: "boston"},
+		{input: `$eval(upper(zip))`, want: "02134"},
+		{input: `$if(zip == 2134|same|different)`, want: "same"},
+		{input: `$if(status ~= "a|b"|pipe|none)`, want: "none"},
+		{input: `report-$(task)-$if(count > 3|$(status)|ok).log`, want: "report-backup-failed.log"},
+		{input: `$if(lower(task) in ("backup", "sync")|$eval(count + 1)|0)`, want: "6"},
+		{input: `$eval(missing)`, want: ""},
+		{input: `$eval(count +)`, wantErr: true},
+		{input: `$eval(count / 0)`, wantErr: true},
+		{input: `$if(count|a|b)`, wantErr: true},
+		{input: `$if(true)`, wantErr: true},
+		{input: `ok $if(true|$eval(len(count, 1)))`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := util.RenderTplResult(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderTplResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTplResult() = %q, want %q", got, tt.want)
+			}
+			if tt.wantErr {
+				if raw := util.RenderTpl(tt.input); raw != tt.input {
+					t.Errorf("RenderTpl() = %q, want it as is", raw)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package fx
+
+import (
+	"strings"
+
+	"github.com/soderasen-au/go-common/util"
+)
+
+// Trace records the evaluation of one node of an expression tree: its source
+// text, its output and, as Children, the traces of its inputs. The items of
+// an in or between list are direct children of the operator.
+type Trace struct {
+	Expr     string    `json:"expr" yaml:"expr"`
+	Pos      *Position `json:"pos,omitempty" yaml:"pos,omitempty"`
+	Value    *Value    `json:"value,omitempty" yaml:"value,omitempty"` // nil if not evaluated
+	Children []*Trace  `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// Evaluated reports whether the node was evaluated; operands skipped by
+// && and || short-circuiting are not.
+func (t *Trace) Evaluated() bool {
+	return t.Value != nil
+}
+
+// String renders the trace as indented text, one node per line:
+//
+//	price > 100 && region == "EU" => false
+//	  price > 100 => false
+//	    price => 50
+//	    100 => 100
+//	  region == "EU" => not evaluated
+//	    ...
+func (t *Trace) String() string {
+	sb := &strings.Builder{}
+	t.write(sb, 0)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (t *Trace) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(t.Expr)
+	sb.WriteString(" => ")
+	switch {
+	case t.Value == nil:
+		sb.WriteString("not evaluated")
+	case t.Value.HasError():
+		sb.WriteString("error: " + t.Value.Error.GetRootCause().Msg)
+	default:
+		formatValue(sb, t.Value)
+	}
+	sb.WriteString("\n")
+	for _, c := range t.Children {
+		c.write(sb, depth+1)
+	}
+}
+
+// Result wraps the trace in a failed *util.Result, with a non-zero Code, so
+// that it is kept as an error, e.g. in exec.Job.Errors by Task.RecordJob.
+func (t *Trace) Result() *util.Result {
+	return util.NewErrResult("EvalTrace", t)
+}
+
+// EvalWithTrace evaluates e like e.Eval(env) and also returns the trace of
+// every node. Evaluation order and short-circuiting are unchanged.
+func EvalWithTrace(e Expression, env Env) (*Value, *Trace) {
+	exp, t := traced(e)
+	return exp.Eval(env), t
+}
+
+// tracedExp stores the output of the wrapped expression in its trace.
+type tracedExp struct {
+	Expression
+	trace *Trace
+}
+
+func (e *tracedExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e *tracedExp) Eval(env Env) *Value {
+	v := e.Expression.Eval(env)
+	e.trace.Value = v
+	return v
+}
+
+// traced rebuilds e with every node wrapped in a tracedExp. Lists are not
+// wrapped, so operators can still recognise them; their items' traces are
+// returned by tracedList instead.
+func traced(e Expression) (Expression, *Trace) {
+	e = unwrapExpr(e)
+	t := &Trace{Expr: Format(e)}
+	if pos := PosOf(e); pos != (Position{}) {
+		t.Pos = &pos
+	}
+	inner := e
+	switch x := e.(type) {
+	case *CallExp:
+		args := make([]Expression, len(x.Args))
+		children := make([]*Trace, len(x.Args))
+		for i, a := range x.Args {
+			args[i], children[i] = traced(a)
+		}
+		if exp, err := NewCallExp(x.Name, args...); err == nil {
+			inner, t.Children = exp, children
+		}
+	case BinaryExpression:
+		b := x.BinOp()
+		lh, lt := traced(b.Lh)
+		rh, rts := tracedList(b.Rh)
+		if exp, err := NewBinOpExp(b.Op, lh, rh); err == nil {
+			inner, t.Children = exp, append([]*Trace{lt}, rts...)
+		}
+	case UnaryExpression:
+		u := x.UnaryOp()
+		operand, ot := traced(u.X)
+		if exp, err := NewUnaryOpExp(u.Op, operand); err == nil {
+			inner, t.Children = exp, []*Trace{ot}
+		}
+	}
+	return &tracedExp{Expression: inner, trace: t}, t
+}
+
+func tracedList(e Expression) (Expression, []*Trace) {
+	l, ok := unwrapExpr(e).(*ListExp)
+	if !ok {
+		exp, t := traced(e)
+		return exp, []*Trace{t}
+	}
+	items := make([]Expression, len(l.Items))
+	traces := make([]*Trace, len(l.Items))
+	for i, item := range l.Items {
+		items[i], traces[i] = traced(item)
+	}
+	return withPos(NewListExp(items...), l.Pos), traces
+}
@@ -0,0 +1,112 @@
+package fx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEvalWithTrace(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		env   MapEnv
+		want  string
+	}{
+		{
+			name:  "short circuit",
+			input: `price > 100 && region == "EU"`,
+			env:   MapEnv{"price": 50, "region": "EU"},
+			want: `price > 100 && region == "EU" => false
+  price > 100 => false
+    price => 50
+    100 => 100
+  region == "EU" => not evaluated
+    region => not evaluated
+    "EU" => not evaluated`,
+		},
+		{
+			name:  "error",
+			input: `lower(name) ~= "bob" || qty > 1`,
+			env:   MapEnv{"qty": 2},
+			want: `lower(name) ~= "bob" || qty > 1 => error: undefined variable: name
+  lower(name) ~= "bob" => error: undefined variable: name
+    lower(name) => error: undefined variable: name
+      name => error: undefined variable: name
+    "bob" => "bob"
+  qty > 1 => not evaluated
+    qty => not evaluated
+    1 => not evaluated`,
+		},
+		{
+			name:  "lists and nulls",
+			input: `status in ("failed", "timeout") || !(code between 1 and 3) || code is null`,
+			env:   MapEnv{"status": "ok", "code": nil},
+			want: `status in ("failed", "timeout") || !(code between 1 and 3) || code is null => true
+  status in ("failed", "timeout") || !(code between 1 and 3) => null
+    status in ("failed", "timeout") => false
+      status => "ok"
+      "failed" => "failed"
+      "timeout" => "timeout"
+    !(code between 1 and 3) => null
+      code between 1 and 3 => null
+        code => null
+        1 => 1
+        3 => 3
+  code is null => true
+    code => null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got, trace := EvalWithTrace(exp, tt.env)
+			if want := exp.Eval(tt.env); !got.Identical(want) {
+				t.Errorf("EvalWithTrace() = %v, want %v", got, want)
+			}
+			if s := trace.String(); s != tt.want {
+				t.Errorf("Trace.String() =\n%s\nwant\n%s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalWithTrace_JSON(t *testing.T) {
+	exp, err := Parse(`-x < 0`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	v, trace := EvalWithTrace(Expr{exp}, MapEnv{"x": 3})
+	if !v.True() {
+		t.Fatalf("EvalWithTrace() = %v, want true", v)
+	}
+	if !trace.Evaluated() || len(trace.Children) != 2 {
+		t.Fatalf("trace = %v, want an evaluated node with 2 children", trace)
+	}
+
+	if res := trace.Result(); res.Code == 0 {
+		t.Errorf("Result().Code = 0, want an error code")
+	}
+	buf, jerr := json.Marshal(trace.Result())
+	if jerr != nil {
+		t.Fatalf("json.Marshal() error = %v", jerr)
+	}
+	s := string(buf)
+	for _, want := range []string{`"expr":"-x"`, `"pos":{"line":1,"column":4}`, `"expr":"x"`, `"number":-3`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("json = %s, want it to contain %s", s, want)
+		}
+	}
+
+	out := &Trace{}
+	if jerr := json.Unmarshal(buf[strings.Index(s, `"result":`)+len(`"result":`):len(buf)-1], out); jerr != nil {
+		t.Fatalf("json.Unmarshal() error = %v", jerr)
+	}
+	if out.String() != trace.String() {
+		t.Errorf("round trip =\n%s\nwant\n%s", out, trace)
+	}
+}
@@ -2,7 +2,9 @@ package fx
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"time"
 
 	"github.com/soderasen-au/go-common/util"
 )
@@ -12,6 +14,8 @@ type (
 		Text      *string      `json:"text,omitempty" yaml:"text,omitempty"`
 		IsNumeric bool         `json:"is_numeric" yaml:"is_numeric"`
 		Number    *float64     `json:"number,omitempty" yaml:"number,omitempty"`
+		Time      *time.Time   `json:"time,omitempty" yaml:"time,omitempty"`
+		Decimal   *Decimal     `json:"decimal,omitempty" yaml:"decimal,omitempty"` // exact form of Number, if any
 		Error     *util.Result `json:"error,omitempty" yaml:"error,omitempty"`
 	}
 )
@@ -20,8 +24,22 @@ func (v *Value) Calc() *Value {
 	return v
 }
 
+func (v *Value) Eval(env Env) *Value {
+	return v
+}
+
 func (v *Value) IsNil() bool {
-	return v.Text == nil && v.Number == nil && v.Error == nil
+	return v.Text == nil && v.Number == nil && v.Time == nil && v.Error == nil
+}
+
+// IsNull reports whether v is the SQL-like null (unknown) value. Unlike
+// IsNil, a numeric value missing its Number is malformed rather than null.
+func (v *Value) IsNull() bool {
+	return v.IsNil() && !v.IsNumeric
+}
+
+func (v *Value) IsTime() bool {
+	return v.Error == nil && v.Time != nil
 }
 
 func (v *Value) HasError() bool {
@@ -32,6 +50,8 @@ func (v *Value) SetError(err *util.Result) {
 	v.Text = nil
 	v.IsNumeric = false
 	v.Number = nil
+	v.Time = nil
+	v.Decimal = nil
 	v.Error = err
 }
 
@@ -39,6 +59,8 @@ func (v *Value) SetFalse() *Value {
 	v.Text = util.Ptr("false")
 	v.IsNumeric = true
 	v.Number = util.Ptr(0.0)
+	v.Time = nil
+	v.Decimal = nil
 	v.Error = nil
 	return v
 }
@@ -47,6 +69,8 @@ func (v *Value) SetTrue() *Value {
 	v.Text = util.Ptr("true")
 	v.IsNumeric = true
 	v.Number = util.Ptr(1.0)
+	v.Time = nil
+	v.Decimal = nil
 	v.Error = nil
 	return v
 }
@@ -63,10 +87,23 @@ func (v *Value) IsBool() bool {
 	return v.True() || v.False()
 }
 
+// IsDual reports whether v holds both a number and the text it was read
+// from, as made by Dual.
+func (v *Value) IsDual() bool {
+	return v.Error == nil && v.IsNumeric && v.Number != nil && v.Text != nil && !v.IsBool()
+}
+
+// isText reports whether v can be used as text: a text or a dual value.
+func (v *Value) isText() bool {
+	return v.Text != nil && (v.IsDual() || !v.IsNumeric)
+}
+
 func (v *Value) SetNum(n float64) *Value {
 	v.Text = nil
 	v.IsNumeric = true
 	v.Number = util.Ptr(n)
+	v.Time = nil
+	v.Decimal = nil
 	v.Error = nil
 	return v
 }
@@ -75,29 +112,71 @@ func (v *Value) SetText(t string) *Value {
 	v.Text = util.Ptr(t)
 	v.IsNumeric = false
 	v.Number = nil
+	v.Time = nil
+	v.Decimal = nil
+	v.Error = nil
+	return v
+}
+
+func (v *Value) SetTime(t time.Time) *Value {
+	v.Text = nil
+	v.IsNumeric = false
+	v.Number = nil
+	v.Time = util.Ptr(t)
+	v.Decimal = nil
 	v.Error = nil
 	return v
 }
 
+// SetDecimal sets an exact number; Number holds its nearest float.
+func (v *Value) SetDecimal(d *Decimal) *Value {
+	v.SetNum(d.Float64())
+	v.Decimal = d
+	return v
+}
+
+// SetValue sets the text s and, if s is a number, that number too, making v
+// a dual value: "007" keeps its text while it can also be used as 7. The
+// Coercion of an evaluation decides which of the two comparisons use.
 func (v *Value) SetValue(s string) *Value {
-	f, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		v.SetText(s)
-	} else {
-		v.SetNum(f)
+	v.SetText(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		v.IsNumeric = true
+		v.Number = util.Ptr(f)
 	}
 	return v
 }
 
+// Less compares numbers, texts or times. A null operand makes the result
+// unknown, which is returned as Nil(). Dual operands are compared as by
+// CoerceStrict.
 func (lh *Value) Less(rh *Value) *Value {
+	lh, rh = CoerceStrict.coerce(lh, rh)
 	if lh.HasError() {
 		return Error(lh.Error.With("LH Error"))
 	}
 	if rh.HasError() {
 		return Error(rh.Error.With("RH Error"))
 	}
+	if lh.IsNull() || rh.IsNull() {
+		return Nil()
+	}
+
+	if lh.Time != nil || rh.Time != nil {
+		lt, rt, err := timeOperands("TimeValueLess", lh, rh)
+		if err != nil {
+			return err
+		}
+		return Bool(lt.Before(rt))
+	}
 
 	if lh.IsNumeric && rh.IsNumeric {
+		if l, r, ok, err := decimalOperands("DecimalValueLess", lh, rh); ok {
+			if err != nil {
+				return err
+			}
+			return Bool(l.Cmp(r) < 0)
+		}
 		if lh.Number == nil || rh.Number == nil {
 			return Error(util.MsgError("NumberValueLess", "operand Number can't be nil"))
 		}
@@ -112,42 +191,297 @@ func (lh *Value) Less(rh *Value) *Value {
 	return Error(util.MsgError("ValueLess", "operand must be in same type"))
 }
 
+// Equal compares two values of the same type. A null operand makes the
+// result unknown, which is returned as Nil(); use Identical to compare nulls.
+// Dual operands are compared as by CoerceStrict.
 func (lh *Value) Equal(rh *Value) *Value {
+	if lh.HasError() {
+		return Error(lh.Error.With("LH Error"))
+	}
+	if rh.HasError() {
+		return Error(rh.Error.With("RH Error"))
+	}
+	lh, rh = CoerceStrict.coerce(lh, rh)
+	if lh.IsNull() || rh.IsNull() {
+		return Nil()
+	}
+	if lh.Time != nil || rh.Time != nil {
+		lt, rt, err := timeOperands("TimeValueEqual", lh, rh)
+		if err != nil {
+			return False()
+		}
+		return Bool(lt.Equal(rt))
+	}
+	if lh.IsNumeric && rh.IsNumeric && lh.IsBool() == rh.IsBool() {
+		if l, r, ok, err := decimalOperands("DecimalValueEqual", lh, rh); ok {
+			return Bool(err == nil && l.Cmp(r) == 0)
+		}
+	}
 	return Bool(lh.IsNumeric == rh.IsNumeric &&
 		util.MaybeNil(lh.Text) == util.MaybeNil(rh.Text) &&
 		util.MaybeNil(lh.Number) == util.MaybeNil(rh.Number))
 }
 
+// Identical reports whether both values hold the same type and content; unlike
+// Equal, two nulls are identical.
+func (lh *Value) Identical(rh *Value) bool {
+	if lh.IsNil() || rh.IsNil() {
+		return lh.IsNil() && rh.IsNil() && lh.IsNumeric == rh.IsNumeric
+	}
+	if lh.HasError() || rh.HasError() {
+		return util.SameResult(lh.Error, rh.Error)
+	}
+	return lh.Equal(rh).True()
+}
+
+// Or follows three-valued logic: true || unknown is true, false || unknown
+// is unknown.
 func (lh *Value) Or(rh *Value) *Value {
-	if !lh.IsBool() {
+	if !lh.IsBool() && !lh.IsNull() {
 		return Error(util.MsgError("Or", "lh is not bool"))
 	}
-	if !rh.IsBool() {
+	if !rh.IsBool() && !rh.IsNull() {
 		return Error(util.MsgError("Or", "rh is not bool"))
 	}
-	return Bool(lh.True() || rh.True())
+	if lh.True() || rh.True() {
+		return True()
+	}
+	if lh.IsNull() || rh.IsNull() {
+		return Nil()
+	}
+	return False()
 }
 
+// And follows three-valued logic: false && unknown is false, true && unknown
+// is unknown.
 func (lh *Value) And(rh *Value) *Value {
-	if !lh.IsBool() {
-		return Error(util.MsgError("Or", "lh is not bool"))
+	if !lh.IsBool() && !lh.IsNull() {
+		return Error(util.MsgError("And", "lh is not bool"))
 	}
-	if !rh.IsBool() {
-		return Error(util.MsgError("Or", "rh is not bool"))
+	if !rh.IsBool() && !rh.IsNull() {
+		return Error(util.MsgError("And", "rh is not bool"))
 	}
-	return Bool(lh.True() && rh.True())
+	if lh.False() || rh.False() {
+		return False()
+	}
+	if lh.IsNull() || rh.IsNull() {
+		return Nil()
+	}
+	return True()
 }
 
+// Not of unknown is unknown.
 func (lh *Value) Not() *Value {
+	if lh.IsNull() {
+		return Nil()
+	}
 	if !lh.IsBool() {
-		return Error(util.MsgError("Or", "lh is not bool"))
+		return Error(util.MsgError("Not", "lh is not bool"))
 	}
 	return Bool(lh.False())
 }
 
+// numOperands checks that both operands are error-free numbers.
+func numOperands(ctx string, lh, rh *Value) (float64, float64, *Value) {
+	if lh.HasError() {
+		return 0, 0, Error(lh.Error.With("LH Error"))
+	}
+	if rh.HasError() {
+		return 0, 0, Error(rh.Error.With("RH Error"))
+	}
+	if !lh.IsNumeric || lh.Number == nil {
+		return 0, 0, Error(util.MsgError(ctx, "lh is not a number"))
+	}
+	if !rh.IsNumeric || rh.Number == nil {
+		return 0, 0, Error(util.MsgError(ctx, "rh is not a number"))
+	}
+	return *lh.Number, *rh.Number, nil
+}
+
+// numOperand checks that v is an error-free number.
+func numOperand(ctx string, v *Value) (float64, *Value) {
+	if v.HasError() {
+		return 0, Error(v.Error.With("Operand Error"))
+	}
+	if !v.IsNumeric || v.Number == nil {
+		return 0, Error(util.MsgError(ctx, "operand is not a number"))
+	}
+	return *v.Number, nil
+}
+
+// Add sums two numbers, or concatenates two texts.
+func (lh *Value) Add(rh *Value) *Value {
+	if !lh.HasError() && !rh.HasError() && !lh.IsNumeric && !rh.IsNumeric {
+		if lh.Text == nil || rh.Text == nil {
+			return Error(util.MsgError("ValueAdd", "operand Text can't be nil"))
+		}
+		return Text(*lh.Text + *rh.Text)
+	}
+	if l, r, ok, err := decimalOperands("ValueAdd", lh, rh); ok {
+		if err != nil {
+			return err
+		}
+		return Dec(l.Add(r))
+	}
+	l, r, err := numOperands("ValueAdd", lh, rh)
+	if err != nil {
+		return err
+	}
+	return Number(l + r)
+}
+
+func (lh *Value) Sub(rh *Value) *Value {
+	if l, r, ok, err := decimalOperands("ValueSub", lh, rh); ok {
+		if err != nil {
+			return err
+		}
+		return Dec(l.Sub(r))
+	}
+	l, r, err := numOperands("ValueSub", lh, rh)
+	if err != nil {
+		return err
+	}
+	return Number(l - r)
+}
+
+func (lh *Value) Mul(rh *Value) *Value {
+	if l, r, ok, err := decimalOperands("ValueMul", lh, rh); ok {
+		if err != nil {
+			return err
+		}
+		return Dec(l.Mul(r))
+	}
+	l, r, err := numOperands("ValueMul", lh, rh)
+	if err != nil {
+		return err
+	}
+	return Number(l * r)
+}
+
+// Div gives a decimal quotient 16 fractional digits, rounded half up, then
+// drops trailing zeros beyond the operands' scale; see WithDecimalOptions
+// for other settings in expressions.
+func (lh *Value) Div(rh *Value) *Value {
+	return lh.div(rh, defaultDecimalOptions)
+}
+
+func (lh *Value) div(rh *Value, o DecimalOptions) *Value {
+	if l, r, ok, err := decimalOperands("ValueDiv", lh, rh); ok {
+		if err != nil {
+			return err
+		}
+		if r.Sign() == 0 {
+			return Error(util.MsgError("ValueDiv", "division by zero"))
+		}
+		if o.DivisionScale < 0 || o.DivisionScale > MaxDecimalScale {
+			return Error(util.MsgError("ValueDiv", fmt.Sprintf("division scale must be between 0 and %d", MaxDecimalScale)))
+		}
+		return Dec(l.Quo(r, o.DivisionScale, o.Rounding).trimZeros(util.Max(l.scale, r.scale)))
+	}
+	l, r, err := numOperands("ValueDiv", lh, rh)
+	if err != nil {
+		return err
+	}
+	if r == 0 {
+		return Error(util.MsgError("ValueDiv", "division by zero"))
+	}
+	return Number(l / r)
+}
+
+func (lh *Value) Mod(rh *Value) *Value {
+	if l, r, ok, err := decimalOperands("ValueMod", lh, rh); ok {
+		if err != nil {
+			return err
+		}
+		if r.Sign() == 0 {
+			return Error(util.MsgError("ValueMod", "division by zero"))
+		}
+		return Dec(l.Rem(r))
+	}
+	l, r, err := numOperands("ValueMod", lh, rh)
+	if err != nil {
+		return err
+	}
+	if r == 0 {
+		return Error(util.MsgError("ValueMod", "division by zero"))
+	}
+	return Number(math.Mod(l, r))
+}
+
+func (v *Value) Neg() *Value {
+	if v.Decimal != nil {
+		return Dec(v.Decimal.Neg())
+	}
+	n, err := numOperand("ValueNeg", v)
+	if err != nil {
+		return err
+	}
+	return Number(-n)
+}
+
+func (v *Value) Abs() *Value {
+	if v.Decimal != nil {
+		return Dec(v.Decimal.Abs())
+	}
+	n, err := numOperand("ValueAbs", v)
+	if err != nil {
+		return err
+	}
+	return Number(math.Abs(n))
+}
+
+// Round rounds half away from zero to the given number of decimal places.
+func (v *Value) Round(places int) *Value {
+	return v.round(places, defaultDecimalOptions)
+}
+
+// round rounds a decimal by o.Rounding instead.
+func (v *Value) round(places int, o DecimalOptions) *Value {
+	if v.Decimal != nil {
+		if places < -MaxDecimalScale || places > MaxDecimalScale {
+			return Error(util.MsgError("ValueRound", fmt.Sprintf("places must be between -%d and %d", MaxDecimalScale, MaxDecimalScale)))
+		}
+		return Dec(v.Decimal.Round(places, o.Rounding))
+	}
+	n, err := numOperand("ValueRound", v)
+	if err != nil {
+		return err
+	}
+	p := math.Pow(10, float64(places))
+	return Number(math.Round(n*p) / p)
+}
+
+func (v *Value) Floor() *Value {
+	if v.Decimal != nil {
+		return Dec(v.Decimal.Round(0, RoundFloor))
+	}
+	n, err := numOperand("ValueFloor", v)
+	if err != nil {
+		return err
+	}
+	return Number(math.Floor(n))
+}
+
+func (v *Value) Ceil() *Value {
+	if v.Decimal != nil {
+		return Dec(v.Decimal.Round(0, RoundCeiling))
+	}
+	n, err := numOperand("ValueCeil", v)
+	if err != nil {
+		return err
+	}
+	return Number(math.Ceil(n))
+}
+
 func (v *Value) String() string {
 	if v.IsNil() {
 		return "<nil>"
+	} else if v.Time != nil {
+		return v.Time.Format(time.RFC3339Nano)
+	} else if v.Decimal != nil {
+		return v.Decimal.String()
+	} else if v.IsDual() {
+		return *v.Text
 	} else if v.IsNumeric {
 		return fmt.Sprintf("%f", *v.Number)
 	}
@@ -184,6 +518,12 @@ func Text(s string) *Value {
 	return &v
 }
 
+func Time(t time.Time) *Value {
+	v := Value{}
+	v.SetTime(t)
+	return &v
+}
+
 func Error(err *util.Result) *Value {
 	v := Value{}
 	v.SetError(err)
@@ -200,6 +540,14 @@ func Bool(b bool) *Value {
 	return &v
 }
 
+func Dec(d *Decimal) *Value {
+	v := Value{}
+	v.SetDecimal(d)
+	return &v
+}
+
+// Dual reads str as text that is also a number if it parses as one; see
+// SetValue.
 func Dual(str string) *Value {
 	v := Value{}
 	v.SetValue(str)
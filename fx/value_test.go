@@ -1,6 +1,7 @@
 package fx
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/soderasen-au/go-common/util"
@@ -658,8 +659,8 @@ func TestValue_Less_ErrorCases(t *testing.T) {
 		lh := &Value{IsNumeric: false, Text: nil}
 		rh := Text("hello")
 		result := lh.Less(rh)
-		if !result.HasError() {
-			t.Error("Less() should return error for nil text")
+		if !result.IsNull() {
+			t.Error("Less() should return null for nil text")
 		}
 	})
 
@@ -667,8 +668,30 @@ func TestValue_Less_ErrorCases(t *testing.T) {
 		lh := Text("hello")
 		rh := &Value{IsNumeric: false, Text: nil}
 		result := lh.Less(rh)
-		if !result.HasError() {
-			t.Error("Less() should return error for nil text")
+		if !result.IsNull() {
+			t.Error("Less() should return null for nil text")
 		}
 	})
 }
+
+func TestValue_Equal_ErrorCases(t *testing.T) {
+	err := Error(util.MsgError("test", "operand error"))
+	tests := []struct {
+		name   string
+		lh, rh *Value
+		want   string
+	}{
+		{name: "left has error", lh: err, rh: Number(5), want: "LH Error"},
+		{name: "right has error", lh: Number(5), rh: err, want: "RH Error"},
+		{name: "both have errors", lh: err, rh: err, want: "LH Error"},
+		{name: "error and null", lh: Nil(), rh: err, want: "RH Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lh.Equal(tt.rh)
+			if !got.HasError() || !strings.Contains(got.Error.Error(), tt.want) {
+				t.Errorf("Equal() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package fx
+
+import "github.com/soderasen-au/go-common/util"
+
+// VarExp references a variable that is resolved from the Env at evaluation
+// time. In source text it is written as `$price` or as a bare, possibly
+// dotted, identifier such as `row.price`.
+type VarExp struct {
+	Name string
+	Pos  Position
+}
+
+func (e *VarExp) setPos(pos Position) {
+	e.Pos = pos
+}
+
+func (e VarExp) Calc() *Value {
+	return e.Eval(nil)
+}
+
+func (e VarExp) Eval(env Env) *Value {
+	if env == nil {
+		return Error(util.MsgError("VarExp", "no environment to resolve variable: "+e.Name))
+	}
+	v, ok := env.Lookup(e.Name)
+	if !ok {
+		return Error(util.MsgError("VarExp", "undefined variable: "+e.Name))
+	}
+	if v == nil {
+		return Nil()
+	}
+	return v
+}
+
+func NewVarExp(name string) Expression {
+	return &VarExp{Name: name}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/russellhaering/goxmldsig v1.5.0
 	golang.org/x/oauth2 v0.32.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v3 v3.0.1
 	software.sslmate.com/src/go-pkcs12 v0.6.0
 )
 
@@ -19,6 +19,12 @@ type (
 
 	// TplFuncMapType defines a map where keys are function names, and values are functions processing string params to return a string result.
 	TplFuncMapType map[string]TplFuncMapFunc
+
+	// TplExprFuncMapFunc defines a function type that processes a slice of expressions as parameter list and returns a single string as function result, or fails.
+	TplExprFuncMapFunc func([]string) (string, *Result)
+
+	// TplExprFuncMapType defines a map where keys are function names, and values are functions processing expression params.
+	TplExprFuncMapType map[string]TplExprFuncMapFunc
 )
 
 var TplFuncMap = TplFuncMapType{
@@ -39,45 +45,209 @@ var TplFuncMap = TplFuncMapType{
 
 var TplVarMap = TplVarMapType{}
 
+// TplExprFuncMap holds the template functions taking expressions, such as
+// the $if and $eval of fx.RegisterTplFuncs, by name.
+var TplExprFuncMap = TplExprFuncMapType{}
+
+// tplPattern matches `$func(...)` or `$(var)`
+var tplPattern = regexp.MustCompile(`^\$(\w+)?\(([^)]*)\)`)
+
+// RenderTpl replaces `$func(a|b)` with the result of the TplFuncMap function
+// func and `$(var)` with the value of the TplVarMap variable var. Their
+// parameters end at the first `)` and are split at every `|`.
+//
+// A TplExprFuncMap function takes expressions instead: its parameters may
+// contain parentheses, quoted text and further templates, which are
+// rendered first, and a `|` only separates them outside quotes and
+// parentheses and when not doubled, so `$if(a || b|yes|no)` has three.
+//
+// Unknown functions and variables, and expression functions that fail, are
+// left as they are; RenderTplResult reports such failures instead.
 func RenderTpl(input string) string {
-	// Regex pattern to match `$func(...)` or `$(var)`
-	pattern := `\$(\w+)?\(([^)]*)\)`
-	re := regexp.MustCompile(pattern)
-
-	// Replace each match
-	result := re.ReplaceAllStringFunc(input, func(match string) string {
-		matches := re.FindStringSubmatch(match)
-		if len(matches) < 3 {
-			return match // No valid match found
-		}
+	out, _ := renderTpl(input)
+	return out
+}
 
-		funcName := matches[1]                  // Function name or variable (can be empty)
-		params := strings.TrimSpace(matches[2]) // Extract parameters
+// RenderTplResult is RenderTpl failing with the error of the first
+// expression function that fails.
+func RenderTplResult(input string) (string, *Result) {
+	out, res := renderTpl(input)
+	if res != nil {
+		return "", res.With("RenderTpl")
+	}
+	return out, nil
+}
 
-		// Case 1: Variable replacement (e.g., `$(var)`)
-		if funcName == "" {
-			if varFunc, exists := TplVarMap[params]; exists {
-				return varFunc(params) // Replace with variable value
+// renderTpl renders input as RenderTpl does, also returning the first
+// failure of an expression function.
+func renderTpl(input string) (string, *Result) {
+	sb := strings.Builder{}
+	var first *Result
+	for i := 0; i < len(input); {
+		if input[i] != '$' {
+			sb.WriteByte(input[i])
+			i++
+			continue
+		}
+		if out, end, res, ok := renderTplExpr(input, i); ok {
+			if first == nil {
+				first = res
 			}
-			return match // Variable not found, return as is
+			sb.WriteString(out)
+			i = end
+			continue
 		}
+		if m := tplPattern.FindStringSubmatch(input[i:]); m != nil {
+			sb.WriteString(renderTplCall(m[0], m[1], m[2]))
+			i += len(m[0])
+			continue
+		}
+		sb.WriteByte(input[i])
+		i++
+	}
+	return sb.String(), first
+}
+
+func renderTplCall(match, funcName, params string) string {
+	params = strings.TrimSpace(params) // Extract parameters
+
+	// Case 1: Variable replacement (e.g., `$(var)`)
+	if funcName == "" {
+		if varFunc, exists := TplVarMap[params]; exists {
+			return varFunc(params) // Replace with variable value
+		}
+		return match // Variable not found, return as is
+	}
+
+	// Case 2: Function replacement (e.g., `$func(param1|param2)`)
+	paramList := []string{}
+	if params != "" {
+		paramList = strings.Split(params, "|")
+		for i, param := range paramList {
+			paramList[i] = strings.TrimSpace(param) // Trim spaces
+		}
+	}
+
+	// Check if function exists
+	if function, exists := TplFuncMap[funcName]; exists {
+		return function(paramList) // Call function with parameters
+	}
+
+	return match // Function not found, return original match
+}
 
-		// Case 2: Function replacement (e.g., `$func(param1, param2)`)
-		paramList := []string{}
-		if params != "" {
-			paramList = strings.Split(params, "|")
-			for i, param := range paramList {
-				paramList[i] = strings.TrimSpace(param) // Trim spaces
+// renderTplExpr renders the TplExprFuncMap call at input[i:], if there is
+// one, returning where it ends. A call failing, or with a parameter
+// failing, is left as is and returns the failure.
+func renderTplExpr(input string, i int) (out string, end int, res *Result, ok bool) {
+	name, body, end, ok := scanTpl(input, i)
+	if !ok {
+		return "", 0, nil, false
+	}
+	function, exists := TplExprFuncMap[name]
+	if !exists {
+		return "", 0, nil, false
+	}
+	match := input[i:end]
+	paramList := []string{}
+	if body = strings.TrimSpace(body); body != "" {
+		paramList = splitTplParams(body)
+		for k, param := range paramList {
+			if paramList[k], res = renderTpl(strings.TrimSpace(param)); res != nil {
+				return match, end, res, true
 			}
 		}
+	}
+	if out, res = function(paramList); res != nil {
+		return match, end, res.With(match), true
+	}
+	return out, end, nil, true
+}
+
+// scanTpl matches `$name(body)` at input[i:], returning the end
+// of the match.
+func scanTpl(input string, i int) (name, body string, end int, ok bool) {
+	if input[i] != '$' {
+		return "", "", 0, false
+	}
+	j := i + 1
+	for j < len(input) && isTplWordChar(input[j]) {
+		j++
+	}
+	if j >= len(input) || input[j] != '(' {
+		return "", "", 0, false
+	}
+	k := closingParen(input, j)
+	if k < 0 {
+		return "", "", 0, false
+	}
+	return input[i+1 : j], input[j+1 : k], k + 1, true
+}
 
-		// Check if function exists
-		if function, exists := TplFuncMap[funcName]; exists {
-			return function(paramList) // Call function with parameters
+func isTplWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// closingParen returns the index of the ')' matching the '(' at open, or -1.
+func closingParen(s string, open int) int {
+	depth := 0
+	for k := open; k < len(s); k++ {
+		switch s[k] {
+		case '"', '\'':
+			if e := closingQuote(s, k); e > 0 {
+				k = e
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return k
+			}
 		}
+	}
+	return -1
+}
 
-		return match // Function not found, return original match
-	})
+// closingQuote returns the index of the quote closing the one at open,
+// skipping backslash escapes, or -1 if there is none; an unclosed quote such
+// as the apostrophe in "It's" is then just a character.
+func closingQuote(s string, open int) int {
+	for k := open + 1; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+		case s[open]:
+			return k
+		}
+	}
+	return -1
+}
 
-	return result
+// splitTplParams splits body at each top-level single `|`.
+func splitTplParams(body string) []string {
+	var params []string
+	depth, start := 0, 0
+	for k := 0; k < len(body); k++ {
+		switch body[k] {
+		case '"', '\'':
+			if e := closingQuote(body, k); e > 0 {
+				k = e
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if k+1 < len(body) && body[k+1] == '|' {
+				k++
+				continue
+			}
+			if depth == 0 {
+				params = append(params, body[start:k])
+				start = k + 1
+			}
+		}
+	}
+	return append(params, body[start:])
 }
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,6 +34,18 @@ func TestRender(t *testing.T) {
 		return yesterday.Format(fmt)
 	}
 
+	TplFuncMap["join"] = func(params []string) string {
+		return strings.Join(params, "+")
+	}
+	TplExprFuncMap["ejoin"] = func(params []string) (string, *Result) {
+		for _, p := range params {
+			if p == "fail" {
+				return "", MsgError("ejoin", "failed")
+			}
+		}
+		return strings.Join(params, ","), nil
+	}
+
 	tests := []struct {
 		name     string
 		input    string
@@ -88,6 +101,61 @@ func TestRender(t *testing.T) {
 			input:    "Today is $today() and var is $(var1)",
 			expected: "Today is 2023-10-01 and var is value1",
 		},
+		{
+			name:     "Parameters end at the first )",
+			input:    `$join(f(a|b)|"x|)"|c)`,
+			expected: `f(a+b|"x|)"|c)`,
+		},
+		{
+			name:     "Every | splits parameters",
+			input:    `$join(a || b|" x | y ")`,
+			expected: `a++b+" x+y "`,
+		},
+		{
+			name:     "Parameters are not rendered",
+			input:    "$join($(var1)|$today(2006))",
+			expected: "$(var1|2023)",
+		},
+		{
+			name:     "Variable name is not rendered",
+			input:    "$($name())",
+			expected: "$($name())",
+		},
+		{
+			name:     "Apostrophe in a parameter",
+			input:    "$join(It's|ok)",
+			expected: "It's+ok",
+		},
+		{
+			name:     "Unbalanced parentheses",
+			input:    "$join(a(b) and $(var1)",
+			expected: "a(b and value1",
+		},
+		{
+			name:     "Expression parameters with nested parentheses, quotes and ||",
+			input:    `$ejoin(f(a|b)|"x|)"|a || b|'c)')`,
+			expected: `f(a|b),"x|)",a || b,'c)'`,
+		},
+		{
+			name:     "Nested templates in expression parameters",
+			input:    "$ejoin($(var1)|$today(2006)|$join(a|b))",
+			expected: "value1,2023,a+b",
+		},
+		{
+			name:     "Unclosed quote is a character",
+			input:    "$ejoin(It's|ok)",
+			expected: "It's,ok",
+		},
+		{
+			name:     "Failing expression function",
+			input:    "a $ejoin(x|fail) b",
+			expected: "a $ejoin(x|fail) b",
+		},
+		{
+			name:     "Dollar without a call",
+			input:    "costs $5 (or more) $",
+			expected: "costs $5 (or more) $",
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,3 +167,26 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderTplResult(t *testing.T) {
+	TplVarMap = TplVarMapType{"var1": func(string) string { return "value1" }}
+	TplExprFuncMap["ejoin"] = func(params []string) (string, *Result) {
+		if len(params) > 0 && params[0] == "fail" {
+			return "", MsgError("ejoin", "failed")
+		}
+		return strings.Join(params, ","), nil
+	}
+
+	got, res := RenderTplResult("$(var1): $ejoin(a|$ejoin(b|c))")
+	if res != nil || got != "value1: a,b,c" {
+		t.Errorf("RenderTplResult() = %q, %v", got, res)
+	}
+	for _, input := range []string{"$ejoin(fail)", "x $ejoin(a|$ejoin(fail)) y"} {
+		if _, res := RenderTplResult(input); res == nil || !strings.Contains(res.Error(), "failed") {
+			t.Errorf("RenderTplResult(%q) error = %v, want failed", input, res)
+		}
+		if got := RenderTpl(input); got != input {
+			t.Errorf("RenderTpl(%q) = %q, want it as is", input, got)
+		}
+	}
+}